@@ -0,0 +1,114 @@
+package adaptive
+
+import (
+	"math"
+	"time"
+)
+
+// Sample 是一个滚动窗口内的测量结果，算法根据它调整并发限制。
+// Sample is the measurement of a rolling window, the algorithm adjusts the concurrency limit by it.
+type Sample struct {
+	RTT      time.Duration // 窗口内执行的平均往返时间 Average round trip time of the executions in the window
+	MinRTT   time.Duration // 观察到的最小往返时间，即无负载时的往返时间 Minimum round trip time observed, i.e. the round trip time without load
+	InFlight int           // 窗口内的最大并发数 Maximum number of executions in flight in the window
+	Count    uint64        // 窗口内的执行数量 Number of executions in the window
+	Drops    uint64        // 窗口内失败的执行数量 Number of failed executions in the window
+}
+
+// Algorithm 是调整并发限制的算法。
+// Algorithm is the algorithm adjusting the concurrency limit.
+type Algorithm interface {
+	// Update 根据当前的限制和测量结果返回新的限制，结果会被限制在配置的范围内。
+	// Update returns the new limit by the current limit and the sample, the result is clamped into the configured range.
+	Update(limit float64, sample Sample) float64
+}
+
+// AIMD 是加性增、乘性减的算法，有失败时按比例减小限制，并发接近限制时加一。
+// AIMD is the additive increase, multiplicative decrease algorithm, it shrinks the limit by a ratio on failures, and adds one when the concurrency is close to the limit.
+type AIMD struct {
+	Increase float64 // 每个窗口增加的值 Value added per window
+	Backoff  float64 // 有失败时乘以的比例 Ratio multiplied on failures
+}
+
+// NewAIMD 返回一个增加 1、回退 0.9 的 AIMD 算法。
+// NewAIMD returns an AIMD algorithm which increases by 1 and backs off by 0.9.
+func NewAIMD() *AIMD {
+	return &AIMD{Increase: 1, Backoff: 0.9}
+}
+
+// Update 返回新的限制。
+// Update returns the new limit.
+func (a *AIMD) Update(limit float64, sample Sample) float64 {
+	if sample.Drops > 0 {
+		return limit * a.Backoff
+	}
+
+	// 只有并发接近限制时才增加，避免空闲时限制无限增长。
+	// Only increase when the concurrency is close to the limit, so the limit does not grow without bound while idle.
+	if float64(sample.InFlight)*2 >= limit {
+		return limit + a.Increase
+	}
+	return limit
+}
+
+// Vegas 是 TCP Vegas 风格的算法，根据往返时间估计排队的数量，排队少时增加限制，排队多或有失败时减小限制。
+// Vegas is a TCP Vegas style algorithm, it estimates the queue size by the round trip times, increases the limit when the queue is short and decreases it when the queue is long or on failures.
+type Vegas struct {
+	Alpha float64 // 排队数量低于它时增加限制 The limit increases when the queue size is below it
+	Beta  float64 // 排队数量高于它时减小限制 The limit decreases when the queue size is above it
+}
+
+// NewVegas 返回一个 alpha 为 3、beta 为 6 的 Vegas 算法。
+// NewVegas returns a Vegas algorithm with alpha 3 and beta 6.
+func NewVegas() *Vegas {
+	return &Vegas{Alpha: 3, Beta: 6}
+}
+
+// Update 返回新的限制。
+// Update returns the new limit.
+func (v *Vegas) Update(limit float64, sample Sample) float64 {
+	if sample.RTT <= 0 || sample.MinRTT <= 0 {
+		return limit
+	}
+
+	step := math.Max(1, math.Log10(limit))
+	queue := limit * (1 - float64(sample.MinRTT)/float64(sample.RTT))
+	switch {
+	case sample.Drops > 0 || queue > v.Beta:
+		return limit - step
+	case queue < v.Alpha:
+		return limit + step
+	default:
+		return limit
+	}
+}
+
+// Gradient 是梯度算法，按无负载往返时间与当前往返时间的比值缩放限制，并留出 sqrt(limit) 的排队余量。
+// Gradient is the gradient algorithm, it scales the limit by the ratio of the round trip time without load to the current one, and leaves sqrt(limit) for queueing.
+type Gradient struct {
+	Tolerance float64 // 容忍的往返时间增长倍数 Tolerated growth factor of the round trip time
+	Smoothing float64 // 新限制的权重 Weight of the new limit
+}
+
+// NewGradient 返回一个容忍 1.5 倍、平滑系数 0.2 的梯度算法。
+// NewGradient returns a gradient algorithm tolerating 1.5 times with smoothing 0.2.
+func NewGradient() *Gradient {
+	return &Gradient{Tolerance: 1.5, Smoothing: 0.2}
+}
+
+// Update 返回新的限制。
+// Update returns the new limit.
+func (g *Gradient) Update(limit float64, sample Sample) float64 {
+	if sample.RTT <= 0 || sample.MinRTT <= 0 {
+		return limit
+	}
+
+	// 梯度限制在 [0.5, 1.0]，单个窗口最多把限制减半。
+	// The gradient is clamped into [0.5, 1.0], a single window at most halves the limit.
+	gradient := math.Max(0.5, math.Min(1, g.Tolerance*float64(sample.MinRTT)/float64(sample.RTT)))
+	if sample.Drops > 0 {
+		gradient = 0.5
+	}
+	next := limit*gradient + math.Sqrt(limit)
+	return limit*(1-g.Smoothing) + next*g.Smoothing
+}
@@ -0,0 +1,213 @@
+package adaptive
+
+import (
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	rw "github.com/shengyanli1982/tripwire/internal/rolling"
+	"github.com/shengyanli1982/tripwire/internal/utils"
+)
+
+const (
+	// DefaultInitialLimit 是初始并发限制的默认值。
+	// DefaultInitialLimit is the default value of the initial concurrency limit.
+	DefaultInitialLimit = 20
+
+	// DefaultMinLimit 是并发限制下限的默认值。
+	// DefaultMinLimit is the default value of the lower bound of the concurrency limit.
+	DefaultMinLimit = 1
+
+	// DefaultMaxLimit 是并发限制上限的默认值。
+	// DefaultMaxLimit is the default value of the upper bound of the concurrency limit.
+	DefaultMaxLimit = 1000
+
+	// DefaultWindow 是滚动窗口时长的默认值，限制每个窗口最多调整一次。
+	// DefaultWindow is the default duration of the rolling window, the limit is adjusted at most once per window.
+	DefaultWindow = time.Second
+
+	// DefaultSlotInterval 是滚动窗口插槽间隔的默认值。
+	// DefaultSlotInterval is the default slot interval of the rolling window.
+	DefaultSlotInterval = 100 * time.Millisecond
+
+	// DefaultMinRTTWindows 是最小往返时间的默认有效窗口数，之后它被替换为这些窗口内观察到的最小值。
+	// DefaultMinRTTWindows is the default number of windows the minimum round trip time lasts, after which it is replaced by the minimum observed in these windows.
+	DefaultMinRTTWindows = 10
+)
+
+// Callback 是并发限制器的回调。
+// Callback is the callback of the concurrency limiter.
+type Callback interface {
+	// OnLimitChange 在并发限制发生变化时被调用。
+	// OnLimitChange is called when the concurrency limit changes.
+	OnLimitChange(from, to int)
+}
+
+// emptyCallback 是并发限制器的空回调。
+// emptyCallback is the empty callback for the concurrency limiter.
+type emptyCallback struct{}
+
+// OnLimitChange 是在并发限制发生变化时被调用的空操作。
+// OnLimitChange is nop called when the concurrency limit changes.
+func (emptyCallback) OnLimitChange(from, to int) {}
+
+// NewEmptyCallback 返回一个空回调。
+// NewEmptyCallback returns an empty callback.
+func NewEmptyCallback() Callback {
+	return emptyCallback{}
+}
+
+// Config 是并发限制器的配置。
+// Config is the configuration for the concurrency limiter.
+type Config struct {
+	algorithm     Algorithm
+	initialLimit  int
+	minLimit      int
+	maxLimit      int
+	window        time.Duration
+	slotInterval  time.Duration
+	minRTTWindows int
+	clock         com.Clock
+	callback      Callback
+}
+
+// NewConfig 返回并发限制器的新配置，默认使用 AIMD 算法。
+// NewConfig returns a new configuration for the concurrency limiter, the AIMD algorithm is used by default.
+func NewConfig() *Config {
+	return &Config{
+		algorithm:     NewAIMD(),
+		initialLimit:  DefaultInitialLimit,
+		minLimit:      DefaultMinLimit,
+		maxLimit:      DefaultMaxLimit,
+		window:        DefaultWindow,
+		slotInterval:  DefaultSlotInterval,
+		minRTTWindows: DefaultMinRTTWindows,
+		clock:         utils.SystemClock{},
+		callback:      NewEmptyCallback(),
+	}
+}
+
+// DefaultConfig 返回并发限制器的默认配置。
+// DefaultConfig returns the default configuration for the concurrency limiter.
+func DefaultConfig() *Config {
+	return NewConfig()
+}
+
+// WithAlgorithm 设置调整并发限制的算法。
+// WithAlgorithm sets the algorithm adjusting the concurrency limit.
+func (c *Config) WithAlgorithm(algorithm Algorithm) *Config {
+	c.algorithm = algorithm
+	return c
+}
+
+// WithInitialLimit 设置初始并发限制。
+// WithInitialLimit sets the initial concurrency limit.
+func (c *Config) WithInitialLimit(limit int) *Config {
+	c.initialLimit = limit
+	return c
+}
+
+// WithMinLimit 设置并发限制的下限。
+// WithMinLimit sets the lower bound of the concurrency limit.
+func (c *Config) WithMinLimit(limit int) *Config {
+	c.minLimit = limit
+	return c
+}
+
+// WithMaxLimit 设置并发限制的上限。
+// WithMaxLimit sets the upper bound of the concurrency limit.
+func (c *Config) WithMaxLimit(limit int) *Config {
+	c.maxLimit = limit
+	return c
+}
+
+// WithWindow 设置测量往返时间和失败的滚动窗口时长，它必须是插槽间隔的整数倍。
+// WithWindow sets the duration of the rolling window measuring round trip times and failures, it must be a multiple of the slot interval.
+func (c *Config) WithWindow(window time.Duration) *Config {
+	c.window = window
+	return c
+}
+
+// WithSlotInterval 设置滚动窗口的插槽间隔。
+// WithSlotInterval sets the slot interval of the rolling window.
+func (c *Config) WithSlotInterval(interval time.Duration) *Config {
+	c.slotInterval = interval
+	return c
+}
+
+// WithMinRTTWindows 设置最小往返时间的有效窗口数。每经过这么多窗口，最小往返时间被替换为这些窗口内观察到的最小值，使它能跟上依赖变慢后的新基线。
+// WithMinRTTWindows sets the number of windows the minimum round trip time lasts. Every so many windows, the minimum round trip time is replaced by the minimum observed in these windows, so it follows the new baseline after the dependency slows down.
+func (c *Config) WithMinRTTWindows(windows int) *Config {
+	c.minRTTWindows = windows
+	return c
+}
+
+// WithClock 设置并发限制器使用的时钟。
+// WithClock sets the clock used by the concurrency limiter.
+func (c *Config) WithClock(clock com.Clock) *Config {
+	c.clock = clock
+	return c
+}
+
+// WithCallback 设置并发限制器的回调。
+// WithCallback sets the callback of the concurrency limiter.
+func (c *Config) WithCallback(callback Callback) *Config {
+	c.callback = callback
+	return c
+}
+
+// Validate 检查滚动窗口的时长和插槽间隔，无效时返回错误。
+// Validate checks the duration and the slot interval of the rolling window, returns an error if they are invalid.
+func (c *Config) Validate() error {
+	return rw.ValidateWindow(c.window, c.slotInterval)
+}
+
+// newRollingWindow 返回配置的滚动窗口，窗口已经由 isConfigValid 检查过，无效时使用默认窗口。
+// newRollingWindow returns the configured rolling window, the window has been checked by isConfigValid, the default window is used if it is invalid.
+func newRollingWindow(conf *Config) *rw.RollingWindow {
+	rwin, err := rw.NewRollingWindowWithInterval(conf.window, conf.slotInterval, conf.clock)
+	if err != nil {
+		rwin, _ = rw.NewRollingWindowWithInterval(DefaultWindow, DefaultSlotInterval, conf.clock)
+	}
+	return rwin
+}
+
+// isConfigValid 检查配置是否有效。
+// isConfigValid checks if the configuration is valid.
+func isConfigValid(conf *Config) *Config {
+	if conf != nil {
+		if conf.algorithm == nil {
+			conf.algorithm = NewAIMD()
+		}
+		if conf.minLimit <= 0 {
+			conf.minLimit = DefaultMinLimit
+		}
+		if conf.maxLimit < conf.minLimit {
+			conf.maxLimit = DefaultMaxLimit
+			if conf.maxLimit < conf.minLimit {
+				conf.maxLimit = conf.minLimit
+			}
+		}
+		if conf.initialLimit < conf.minLimit {
+			conf.initialLimit = conf.minLimit
+		}
+		if conf.initialLimit > conf.maxLimit {
+			conf.initialLimit = conf.maxLimit
+		}
+		if conf.Validate() != nil {
+			conf.window, conf.slotInterval = DefaultWindow, DefaultSlotInterval
+		}
+		if conf.minRTTWindows <= 0 {
+			conf.minRTTWindows = DefaultMinRTTWindows
+		}
+		if conf.clock == nil {
+			conf.clock = utils.SystemClock{}
+		}
+		if conf.callback == nil {
+			conf.callback = NewEmptyCallback()
+		}
+	} else {
+		conf = DefaultConfig()
+	}
+
+	return conf
+}
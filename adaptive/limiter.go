@@ -0,0 +1,312 @@
+package adaptive
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+	rw "github.com/shengyanli1982/tripwire/internal/rolling"
+)
+
+// Limiter 是自适应的并发限制器，在滚动窗口中测量往返时间和失败，并用可替换的算法调整并发限制。
+// 并发数达到限制时拒绝执行并返回 ErrorLimitExceeded。
+// Limiter is an adaptive concurrency limiter, it measures round trip times and failures in a rolling window, and adjusts the concurrency limit with a pluggable algorithm.
+// Executions are rejected with ErrorLimitExceeded when the concurrency reaches the limit.
+type Limiter struct {
+	config   *Config           // 并发限制器的配置 Config of the concurrency limiter
+	rtt      *rw.RollingWindow // 往返时间的滚动窗口，单位为秒 Rolling window of round trip times in seconds
+	drops    *rw.RollingWindow // 失败的滚动窗口 Rolling window of failures
+	lock     sync.Mutex        // 保护下面字段的锁 Lock protecting the fields below
+	limit    float64           // 当前的并发限制 Current concurrency limit
+	inFlight int               // 正在执行的数量 Number of executions in flight
+	peak     int               // 本窗口内的最大并发数 Maximum concurrency in this window
+	minRTT   time.Duration     // 当前使用的最小往返时间 Minimum round trip time in use
+	nextRTT  time.Duration     // 本轮观察到的最小往返时间，轮换时替换 minRTT Minimum round trip time observed in this round, replaces minRTT on rotation
+	windows  int               // 本轮已经调整的窗口数 Number of windows adjusted in this round
+	updateAt time.Time         // 上次调整限制的时间 Time of the last limit adjustment
+	stopped  bool              // 是否已经停止 Whether it is stopped
+	once     sync.Once         // 用于确保只停止一次 The sync.Once to ensure stopping only once
+}
+
+// NewLimiter 返回一个新的并发限制器，滚动窗口无效时使用默认窗口。
+// NewLimiter returns a new concurrency limiter, the default window is used if the rolling window is invalid.
+func NewLimiter(conf *Config) *Limiter {
+	conf = isConfigValid(conf)
+	return &Limiter{
+		config:   conf,
+		rtt:      newRollingWindow(conf),
+		drops:    newRollingWindow(conf),
+		limit:    float64(conf.initialLimit),
+		updateAt: conf.clock.Now(),
+	}
+}
+
+// Stop 停止并发限制器。
+// Stop stops the concurrency limiter.
+func (l *Limiter) Stop() {
+	l.once.Do(func() {
+		l.lock.Lock()
+		l.stopped = true
+		l.lock.Unlock()
+		l.rtt.Stop()
+		l.drops.Stop()
+	})
+}
+
+// Limit 返回当前的并发限制。
+// Limit returns the current concurrency limit.
+func (l *Limiter) Limit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return int(l.limit)
+}
+
+// InFlight 返回正在执行的数量。
+// InFlight returns the number of executions in flight.
+func (l *Limiter) InFlight() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.inFlight
+}
+
+// acquire 获取一个执行名额，并发数达到限制时返回 ErrorLimitExceeded。
+// acquire acquires an execution slot, returns ErrorLimitExceeded when the concurrency reaches the limit.
+func (l *Limiter) acquire() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.stopped {
+		return com.ErrorRollingWindowStopped
+	}
+	if l.inFlight >= int(l.limit) {
+		return com.ErrorLimitExceeded
+	}
+	l.inFlight++
+	if l.inFlight > l.peak {
+		l.peak = l.inFlight
+	}
+	return nil
+}
+
+// release 释放一个执行名额，不记录结果。
+// release releases an execution slot without recording the result.
+func (l *Limiter) release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.inFlight--
+}
+
+// record 释放一个执行名额并记录它的往返时间和结果，每个窗口最多调整一次并发限制。
+// record releases an execution slot and records its round trip time and result, the concurrency limit is adjusted at most once per window.
+func (l *Limiter) record(rtt time.Duration, dropped bool) {
+	drop := 0.0
+	if dropped {
+		drop = 1
+	}
+	_ = l.rtt.Add(rtt.Seconds())
+	_ = l.drops.Add(drop)
+
+	l.lock.Lock()
+	l.inFlight--
+	if rtt > 0 && (l.minRTT == 0 || rtt < l.minRTT) {
+		l.minRTT = rtt
+	}
+	if rtt > 0 && (l.nextRTT == 0 || rtt < l.nextRTT) {
+		l.nextRTT = rtt
+	}
+
+	// 距离上次调整还不到一个窗口。
+	// Less than one window since the last adjustment.
+	now := l.config.clock.Now()
+	if now.Sub(l.updateAt) < l.config.window {
+		l.lock.Unlock()
+		return
+	}
+
+	avg, count, _ := l.rtt.Avg()
+	drops, _, _ := l.drops.Sum()
+	sample := Sample{
+		RTT:      time.Duration(avg * float64(time.Second)),
+		MinRTT:   l.minRTT,
+		InFlight: l.peak,
+		Count:    count,
+		Drops:    uint64(drops),
+	}
+
+	from := int(l.limit)
+	l.limit = math.Max(float64(l.config.minLimit), math.Min(float64(l.config.maxLimit), l.config.algorithm.Update(l.limit, sample)))
+	to := int(l.limit)
+	l.peak = l.inFlight
+	l.updateAt = now
+
+	// 每隔若干窗口用本轮观察到的最小值替换最小往返时间，使它在依赖变慢后不会一直停在旧的基线上。
+	// Every few windows the minimum round trip time is replaced by the minimum observed in this round, so it does not stay at the old baseline after the dependency slows down.
+	if l.windows++; l.windows >= l.config.minRTTWindows {
+		l.minRTT, l.nextRTT, l.windows = l.nextRTT, 0, 0
+	}
+	l.lock.Unlock()
+
+	// 在锁外调用回调。
+	// Call the callback outside the lock.
+	if from != to {
+		l.config.callback.OnLimitChange(from, to)
+	}
+}
+
+// limiterNotifier 记录执行开始的时间，标记结果时把往返时间交给并发限制器。
+// limiterNotifier records the start time of the execution, and hands the round trip time to the concurrency limiter when the result is marked.
+type limiterNotifier struct {
+	limiter *Limiter
+	start   time.Time
+	once    sync.Once
+}
+
+// MarkSuccess 标记执行成功。
+// MarkSuccess marks the execution as successful.
+func (n *limiterNotifier) MarkSuccess() {
+	n.once.Do(func() {
+		n.limiter.record(n.limiter.config.clock.Since(n.start), false)
+	})
+}
+
+// MarkFailure 标记执行失败。
+// MarkFailure marks the execution as failed.
+func (n *limiterNotifier) MarkFailure(reason error) {
+	n.once.Do(func() {
+		n.limiter.record(n.limiter.config.clock.Since(n.start), true)
+	})
+}
+
+// Release 释放执行名额，不记录结果。
+// Release releases the execution slot without recording the result.
+func (n *limiterNotifier) Release() {
+	n.once.Do(n.limiter.release)
+}
+
+// Allow 获取一个执行名额，返回的 Notifier 必须被调用一次，否则执行名额不会被释放。
+// Allow acquires an execution slot, the returned Notifier must be called once, otherwise the execution slot is not released.
+func (l *Limiter) Allow() (com.Notifier, error) {
+	if err := l.acquire(); err != nil {
+		return nil, err
+	}
+	return &limiterNotifier{limiter: l, start: l.config.clock.Now()}, nil
+}
+
+// do 获取执行名额后执行函数。
+// do executes the function after acquiring an execution slot.
+func (l *Limiter) do(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 并发限制器拒绝执行，执行回退函数或返回错误。
+	// The concurrency limiter rejects the execution, execute the fallback function or return the error.
+	notifier, err := l.Allow()
+	if err != nil {
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+
+	// 执行函数
+	// Execute the function
+	err = fn()
+
+	// 如果错误可接受，标记执行成功，否则标记执行失败并返回错误。
+	// If the error is acceptable, mark the execution as successful, otherwise mark the execution as failed and return the error.
+	if acceptable(err) {
+		notifier.MarkSuccess()
+		return nil
+	}
+	notifier.MarkFailure(err)
+	return err
+}
+
+// Do 执行函数并返回错误。
+// Do executes the function and returns the error.
+func (l *Limiter) Do(fn com.HandleFunc) error {
+	return l.do(fn, nil, cb.DefaultAcceptableFunc)
+}
+
+// DoWithAcceptable 使用给定的可接受函数执行函数并返回错误。
+// DoWithAcceptable executes the function with the given acceptable function and returns the error.
+func (l *Limiter) DoWithAcceptable(fn com.HandleFunc, acceptable com.AcceptableFunc) error {
+	return l.do(fn, nil, acceptable)
+}
+
+// DoWithFallback 使用给定的回退函数执行函数并返回错误。
+// DoWithFallback executes the function with the given fallback function and returns the error.
+func (l *Limiter) DoWithFallback(fn com.HandleFunc, fallback com.FallbackFunc) error {
+	return l.do(fn, fallback, cb.DefaultAcceptableFunc)
+}
+
+// DoWithFallbackAcceptable 使用给定的回退和可接受函数执行函数并返回错误。
+// DoWithFallbackAcceptable executes the function with the given fallback and acceptable functions and returns the error.
+func (l *Limiter) DoWithFallbackAcceptable(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return l.do(fn, fallback, acceptable)
+}
+
+// doCtx 获取执行名额后使用上下文执行函数。
+// doCtx executes the function with the context after acquiring an execution slot.
+func (l *Limiter) doCtx(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 如果上下文已经结束，快速失败，不计入统计。
+	// If the context is already done, fail fast without recording the statistics.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 并发限制器拒绝执行，执行回退函数或返回错误。
+	// The concurrency limiter rejects the execution, execute the fallback function or return the error.
+	notifier, err := l.Allow()
+	if err != nil {
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+
+	// 执行函数
+	// Execute the function
+	err = fn(ctx)
+
+	// 调用方取消了上下文，不计入成功或失败，只释放执行名额。
+	// The caller canceled the context, it is counted as neither success nor failure, only the execution slot is released.
+	if errors.Is(err, context.Canceled) || (err != nil && errors.Is(ctx.Err(), context.Canceled)) {
+		notifier.(*limiterNotifier).Release()
+		return err
+	}
+
+	// 如果错误可接受，标记执行成功，否则标记执行失败并返回错误。
+	// If the error is acceptable, mark the execution as successful, otherwise mark the execution as failed and return the error.
+	if acceptable(err) {
+		notifier.MarkSuccess()
+		return nil
+	}
+	notifier.MarkFailure(err)
+	return err
+}
+
+// DoCtx 使用上下文执行函数并返回错误。
+// DoCtx executes the function with the context and returns the error.
+func (l *Limiter) DoCtx(ctx context.Context, fn com.HandleCtxFunc) error {
+	return l.doCtx(ctx, fn, nil, cb.DefaultAcceptableFunc)
+}
+
+// DoCtxWithAcceptable 使用上下文和给定的可接受函数执行函数并返回错误。
+// DoCtxWithAcceptable executes the function with the context and the given acceptable function and returns the error.
+func (l *Limiter) DoCtxWithAcceptable(ctx context.Context, fn com.HandleCtxFunc, acceptable com.AcceptableFunc) error {
+	return l.doCtx(ctx, fn, nil, acceptable)
+}
+
+// DoCtxWithFallback 使用上下文和给定的回退函数执行函数并返回错误。
+// DoCtxWithFallback executes the function with the context and the given fallback function and returns the error.
+func (l *Limiter) DoCtxWithFallback(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc) error {
+	return l.doCtx(ctx, fn, fallback, cb.DefaultAcceptableFunc)
+}
+
+// DoCtxWithFallbackAcceptable 使用上下文和给定的回退和可接受函数执行函数并返回错误。
+// DoCtxWithFallbackAcceptable executes the function with the context and the given fallback and acceptable functions and returns the error.
+func (l *Limiter) DoCtxWithFallbackAcceptable(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return l.doCtx(ctx, fn, fallback, acceptable)
+}
@@ -0,0 +1,139 @@
+package adaptive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/tripwiretest"
+	"github.com/stretchr/testify/assert"
+)
+
+type limitCallback struct {
+	changes [][2]int
+}
+
+func (c *limitCallback) OnLimitChange(from, to int) {
+	c.changes = append(c.changes, [2]int{from, to})
+}
+
+// call 执行一次耗时 rtt 的调用。
+func call(l *Limiter, clock *tripwiretest.FakeClock, rtt time.Duration, err error) error {
+	return l.Do(func() error {
+		clock.Advance(rtt)
+		return err
+	})
+}
+
+func TestLimiter_Exceeded(t *testing.T) {
+	limiter := NewLimiter(NewConfig().WithInitialLimit(2))
+	defer limiter.Stop()
+
+	first, err := limiter.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	_, err = limiter.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 2, limiter.InFlight(), "Unexpected in flight count")
+
+	// Test case 1: The limit is reached
+	err = limiter.DoWithFallback(func() error { return nil }, func(err error) error {
+		assert.ErrorIs(t, err, com.ErrorLimitExceeded, "Unexpected error")
+		return nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+
+	// Test case 2: A slot is released
+	first.MarkSuccess()
+	first.MarkSuccess()
+	assert.Equal(t, 1, limiter.InFlight(), "Unexpected in flight count")
+	err = limiter.DoCtx(context.Background(), func(ctx context.Context) error { return nil })
+	assert.NoError(t, err, "Unexpected error")
+}
+
+func TestLimiter_AIMD(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	clock := tripwiretest.NewFakeClock(time.Time{})
+	callback := &limitCallback{}
+	limiter := NewLimiter(NewConfig().WithInitialLimit(2).WithClock(clock).WithCallback(callback))
+	defer limiter.Stop()
+
+	// Test case 1: The concurrency is close to the limit, the limit increases once per window
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, call(limiter, clock, 60*time.Millisecond, nil), "Unexpected error")
+	}
+	assert.Equal(t, 3, limiter.Limit(), "Unexpected limit")
+
+	// Test case 2: Failures back off the limit
+	for i := 0; i < 20; i++ {
+		assert.ErrorIs(t, call(limiter, clock, 60*time.Millisecond, execError), execError, "Unexpected error")
+	}
+	assert.Equal(t, 2, limiter.Limit(), "Unexpected limit")
+	assert.Equal(t, [][2]int{{2, 3}, {3, 2}}, callback.changes, "Unexpected limit changes")
+}
+
+func TestLimiter_MinRTTRecovers(t *testing.T) {
+	clock := tripwiretest.NewFakeClock(time.Time{})
+	limiter := NewLimiter(NewConfig().WithAlgorithm(NewGradient()).WithClock(clock).WithMinRTTWindows(5))
+	defer limiter.Stop()
+
+	// The latency steps up, the gradient shrinks the limit against the old minimum
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, call(limiter, clock, 10*time.Millisecond, nil), "Unexpected error")
+	}
+	for i := 0; i < 40; i++ {
+		assert.NoError(t, call(limiter, clock, 100*time.Millisecond, nil), "Unexpected error")
+	}
+	low := limiter.Limit()
+	assert.Less(t, low, DefaultInitialLimit, "Expected decrease")
+
+	// The minimum ages out and follows the new baseline, so the limit recovers
+	for i := 0; i < 200; i++ {
+		assert.NoError(t, call(limiter, clock, 100*time.Millisecond, nil), "Unexpected error")
+	}
+	assert.Greater(t, limiter.Limit(), low, "Expected recovery")
+}
+
+func TestLimiter_Bounds(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	clock := tripwiretest.NewFakeClock(time.Time{})
+	limiter := NewLimiter(NewConfig().WithInitialLimit(3).WithMinLimit(2).WithClock(clock))
+	defer limiter.Stop()
+
+	// The limit never drops below the lower bound
+	for i := 0; i < 100; i++ {
+		_ = call(limiter, clock, 100*time.Millisecond, execError)
+	}
+	assert.Equal(t, 2, limiter.Limit(), "Unexpected limit")
+
+	// An invalid window falls back to the default window
+	conf := NewConfig().WithWindow(time.Second).WithSlotInterval(300 * time.Millisecond)
+	assert.Error(t, conf.Validate(), "Expected error")
+	assert.NotPanics(t, func() {
+		limiter := NewLimiter(conf)
+		defer limiter.Stop()
+		assert.NoError(t, limiter.Do(func() error { return nil }), "Unexpected error")
+	}, "Unexpected panic")
+}
+
+func TestAlgorithm_Update(t *testing.T) {
+	idle := Sample{RTT: 10 * time.Millisecond, MinRTT: 10 * time.Millisecond, InFlight: 10, Count: 100}
+	queued := Sample{RTT: 40 * time.Millisecond, MinRTT: 10 * time.Millisecond, InFlight: 10, Count: 100}
+
+	// Vegas increases without queueing and decreases with queueing
+	vegas := NewVegas()
+	assert.Greater(t, vegas.Update(10, idle), 10.0, "Expected increase")
+	assert.Less(t, vegas.Update(10, queued), 10.0, "Expected decrease")
+
+	// Gradient grows by the queue allowance without queueing and shrinks with queueing
+	gradient := NewGradient()
+	assert.Greater(t, gradient.Update(100, idle), 100.0, "Expected increase")
+	assert.Less(t, gradient.Update(100, queued), 100.0, "Expected decrease")
+
+	// No round trip time, no change
+	assert.Equal(t, 10.0, vegas.Update(10, Sample{}), "Unexpected limit")
+	assert.Equal(t, 10.0, gradient.Update(10, Sample{}), "Unexpected limit")
+}
@@ -0,0 +1,301 @@
+package tripwire
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// 定义默认的重试常量值
+// Define the default retry constant values
+const (
+	// DefaultRetryAttempts 是最大尝试次数的默认值 (包含第一次执行)。
+	// DefaultRetryAttempts is the default value of max attempts (including the first execution).
+	DefaultRetryAttempts = 3
+
+	// DefaultRetryInitialInterval 是初始退避间隔的默认值。
+	// DefaultRetryInitialInterval is the default value of the initial backoff interval.
+	DefaultRetryInitialInterval = 100 * time.Millisecond
+
+	// DefaultRetryMaxInterval 是最大退避间隔的默认值。
+	// DefaultRetryMaxInterval is the default value of the max backoff interval.
+	DefaultRetryMaxInterval = 10 * time.Second
+
+	// DefaultRetryMultiplier 是退避间隔倍数的默认值。
+	// DefaultRetryMultiplier is the default value of the backoff interval multiplier.
+	DefaultRetryMultiplier = 2.0
+)
+
+// Jitter 是退避间隔的抖动策略。
+// Jitter is the jitter strategy of the backoff interval.
+type Jitter int
+
+const (
+	// JitterNone 不使用抖动，严格按照指数退避。
+	// JitterNone uses no jitter, strictly exponential backoff.
+	JitterNone Jitter = iota
+
+	// JitterFull 在 [0, 退避间隔) 之间随机选择。
+	// JitterFull picks randomly in [0, backoff interval).
+	JitterFull
+
+	// JitterEqual 使用一半的退避间隔，再加上 [0, 退避间隔/2) 之间的随机值。
+	// JitterEqual uses half of the backoff interval plus a random value in [0, backoff interval/2).
+	JitterEqual
+
+	// JitterDecorrelated 在 [初始间隔, 上一次间隔*3) 之间随机选择。
+	// JitterDecorrelated picks randomly in [initial interval, previous interval*3).
+	JitterDecorrelated
+)
+
+// DefaultRetryableFunc 是默认的可重试函数，熔断器、隔舱、限流器或并发限制器拒绝、已停止的错误不会被重试。
+// 调用方的上下文被取消或超过截止时间时也不会重试，单次执行的超时 TimeoutError 仍然会被重试。
+// DefaultRetryableFunc is the default retryable function, errors of a rejecting or stopped breaker, bulkhead, rate limiter or concurrency limiter are not retried.
+// Neither is a canceled or expired context of the caller, a TimeoutError of a single execution is still retried.
+func DefaultRetryableFunc(err error) bool {
+	if errors.Is(err, context.Canceled) || (errors.Is(err, context.DeadlineExceeded) && !com.IsTimeout(err)) {
+		return false
+	}
+	return !errors.Is(err, com.ErrorServiceUnavailable) && !errors.Is(err, com.ErrorRollingWindowStopped) &&
+		!errors.Is(err, com.ErrorBulkheadFull) && !errors.Is(err, com.ErrorBulkheadStopped) &&
+		!errors.Is(err, com.ErrorRateLimited) && !errors.Is(err, com.ErrorRateLimiterStopped) &&
+		!errors.Is(err, com.ErrorLimitExceeded)
+}
+
+// RetryConfig 是退避重试的配置。
+// RetryConfig is the configuration for the backoff retry.
+type RetryConfig struct {
+	attempts   int
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     Jitter
+	retryable  com.AcceptableFunc
+	budget     *RetryBudget
+}
+
+// NewRetryConfig 返回退避重试的新配置。
+// NewRetryConfig returns a new configuration for the backoff retry.
+func NewRetryConfig() *RetryConfig {
+	return &RetryConfig{
+		attempts:   DefaultRetryAttempts,
+		initial:    DefaultRetryInitialInterval,
+		max:        DefaultRetryMaxInterval,
+		multiplier: DefaultRetryMultiplier,
+		jitter:     JitterFull,
+		retryable:  DefaultRetryableFunc,
+	}
+}
+
+// DefaultRetryConfig 返回退避重试的默认配置。
+// DefaultRetryConfig returns the default configuration for the backoff retry.
+func DefaultRetryConfig() *RetryConfig {
+	return NewRetryConfig()
+}
+
+// WithAttempts 设置最大尝试次数 (包含第一次执行)。
+// WithAttempts sets the max attempts (including the first execution).
+func (c *RetryConfig) WithAttempts(attempts int) *RetryConfig {
+	c.attempts = attempts
+	return c
+}
+
+// WithInitialInterval 设置初始退避间隔。
+// WithInitialInterval sets the initial backoff interval.
+func (c *RetryConfig) WithInitialInterval(interval time.Duration) *RetryConfig {
+	c.initial = interval
+	return c
+}
+
+// WithMaxInterval 设置最大退避间隔。
+// WithMaxInterval sets the max backoff interval.
+func (c *RetryConfig) WithMaxInterval(interval time.Duration) *RetryConfig {
+	c.max = interval
+	return c
+}
+
+// WithMultiplier 设置退避间隔倍数。
+// WithMultiplier sets the backoff interval multiplier.
+func (c *RetryConfig) WithMultiplier(multiplier float64) *RetryConfig {
+	c.multiplier = multiplier
+	return c
+}
+
+// WithJitter 设置抖动策略。
+// WithJitter sets the jitter strategy.
+func (c *RetryConfig) WithJitter(jitter Jitter) *RetryConfig {
+	c.jitter = jitter
+	return c
+}
+
+// WithRetryable 设置判断错误是否可以重试的函数。
+// WithRetryable sets the function that decides whether an error can be retried.
+func (c *RetryConfig) WithRetryable(retryable com.AcceptableFunc) *RetryConfig {
+	c.retryable = retryable
+	return c
+}
+
+// WithBudget 设置重试预算，每次额外尝试前都会先从预算中取出一次重试。
+// WithBudget sets the retry budget, every extra attempt takes one retry from the budget first.
+func (c *RetryConfig) WithBudget(budget *RetryBudget) *RetryConfig {
+	c.budget = budget
+	return c
+}
+
+// isRetryConfigValid 检查配置是否有效，如果无效则使用默认值。
+// isRetryConfigValid checks if the configuration is valid, uses default values if invalid.
+func isRetryConfigValid(conf *RetryConfig) *RetryConfig {
+	if conf != nil {
+		if conf.attempts <= 0 {
+			conf.attempts = DefaultRetryAttempts
+		}
+		if conf.initial <= 0 {
+			conf.initial = DefaultRetryInitialInterval
+		}
+		if conf.max < conf.initial {
+			conf.max = DefaultRetryMaxInterval
+			if conf.max < conf.initial {
+				conf.max = conf.initial
+			}
+		}
+		if conf.multiplier < 1 {
+			conf.multiplier = DefaultRetryMultiplier
+		}
+		if conf.jitter < JitterNone || conf.jitter > JitterDecorrelated {
+			conf.jitter = JitterFull
+		}
+		if conf.retryable == nil {
+			conf.retryable = DefaultRetryableFunc
+		}
+	} else {
+		conf = DefaultRetryConfig()
+	}
+
+	return conf
+}
+
+// backoffRetry 是使用指数退避的重试实现。
+// backoffRetry is a retry implementation with exponential backoff.
+type backoffRetry struct {
+	config *RetryConfig
+	rand   *rand.Rand
+	lock   sync.Mutex
+}
+
+// NewBackoffRetry 函数创建并返回一个新的指数退避重试实例
+// The NewBackoffRetry function creates and returns a new instance of exponential backoff retry
+func NewBackoffRetry(conf *RetryConfig) com.Retry {
+	conf = isRetryConfigValid(conf)
+	return &backoffRetry{
+		config: conf,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// float64 返回 [0, 1) 之间的随机浮点数
+// float64 returns a random float64 in [0, 1)
+func (r *backoffRetry) float64() float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.rand.Float64()
+}
+
+// backoff 计算第 attempt 次失败后的等待时间，prev 是上一次的等待时间
+// backoff calculates the wait time after the attempt-th failure, prev is the previous wait time
+func (r *backoffRetry) backoff(attempt int, prev time.Duration) time.Duration {
+	conf := r.config
+
+	// 计算指数退避间隔，不超过最大间隔
+	// Calculate the exponential backoff interval, no more than the max interval
+	base := math.Min(float64(conf.initial)*math.Pow(conf.multiplier, float64(attempt-1)), float64(conf.max))
+
+	var delay float64
+	switch conf.jitter {
+	case JitterFull:
+		delay = r.float64() * base
+	case JitterEqual:
+		delay = base/2 + r.float64()*base/2
+	case JitterDecorrelated:
+		upper := math.Min(float64(prev)*3, float64(conf.max))
+		delay = float64(conf.initial) + r.float64()*math.Max(0, upper-float64(conf.initial))
+	default:
+		delay = base
+	}
+
+	return time.Duration(delay)
+}
+
+// TryOnConflictVal 方法执行给定的函数，失败时按照指数退避重试，并返回结果
+// The TryOnConflictVal method executes the given function, retries with exponential backoff on failure, and returns the result
+func (r *backoffRetry) TryOnConflictVal(fn com.RetryableFunc) com.RetryResult {
+	return r.TryOnConflictValCtx(context.Background(), fn)
+}
+
+// TryOnConflictValCtx 方法执行给定的函数，失败时按照指数退避重试，上下文结束时停止等待并返回上下文的错误
+// The TryOnConflictValCtx method executes the given function, retries with exponential backoff on failure, stops waiting and returns the error of the context when the context is done
+func (r *backoffRetry) TryOnConflictValCtx(ctx context.Context, fn com.RetryableFunc) com.RetryResult {
+	re := result{}
+	prev := r.config.initial
+
+	// 记录第一次执行到重试预算
+	// Record the first attempt to the retry budget
+	if r.config.budget != nil {
+		r.config.budget.RecordAttempt()
+	}
+
+	for attempt := 1; ; attempt++ {
+		// 执行函数
+		// Execute the function
+		re.count++
+		re.data, re.tryError = fn()
+
+		// 执行成功，直接返回
+		// The execution succeeded, return directly
+		if re.tryError == nil {
+			return &re
+		}
+
+		// 记录本次执行的错误
+		// Record the error of this execution
+		re.errs = append(re.errs, re.tryError)
+
+		// 达到最大尝试次数或错误不可重试，返回结果
+		// Return the result when the max attempts is reached or the error is not retryable
+		if attempt >= r.config.attempts || !r.config.retryable(re.tryError) {
+			return &re
+		}
+
+		// 重试预算耗尽，返回结果
+		// Return the result when the retry budget is exhausted
+		if r.config.budget != nil && !r.config.budget.TryRetry() {
+			return &re
+		}
+
+		// 等待退避间隔后重试，上下文结束时立即返回
+		// Wait for the backoff interval and retry, return immediately when the context is done
+		prev = r.backoff(attempt, prev)
+		if err := wait(ctx, prev); err != nil {
+			re.tryError = err
+			return &re
+		}
+	}
+}
+
+// wait 等待指定的时间，上下文先结束时返回上下文的错误
+// wait waits for the given duration, returns the error of the context if it is done first
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
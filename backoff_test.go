@@ -0,0 +1,197 @@
+package tripwire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffRetry_TryOnConflictVal(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	retry := NewBackoffRetry(NewRetryConfig().WithAttempts(3).WithInitialInterval(time.Millisecond).WithJitter(JitterNone))
+
+	// Test case 1: Successful execution on the first attempt
+	result := retry.TryOnConflictVal(func() (any, error) {
+		return "ok", nil
+	})
+	assert.True(t, result.IsSuccess(), "Expected success")
+	assert.Equal(t, "ok", result.Data(), "Unexpected data")
+	assert.Equal(t, int64(1), result.Count(), "Unexpected count")
+	assert.Empty(t, result.ExecErrors(), "Unexpected errors")
+
+	// Test case 2: Successful execution after failures
+	count := 0
+	result = retry.TryOnConflictVal(func() (any, error) {
+		count++
+		if count < 3 {
+			return nil, execError
+		}
+		return count, nil
+	})
+	assert.True(t, result.IsSuccess(), "Expected success")
+	assert.Equal(t, 3, result.Data(), "Unexpected data")
+	assert.Equal(t, int64(3), result.Count(), "Unexpected count")
+	assert.Equal(t, []error{execError, execError}, result.ExecErrors(), "Unexpected errors")
+
+	// Test case 3: Failed execution after max attempts
+	result = retry.TryOnConflictVal(func() (any, error) {
+		return nil, execError
+	})
+	assert.False(t, result.IsSuccess(), "Expected failure")
+	assert.ErrorIs(t, result.TryError(), execError, "Unexpected error")
+	assert.Equal(t, int64(3), result.Count(), "Unexpected count")
+	assert.Len(t, result.ExecErrors(), 3, "Unexpected errors")
+	assert.ErrorIs(t, result.FirstExecError(), execError, "Unexpected error")
+	assert.ErrorIs(t, result.LastExecError(), execError, "Unexpected error")
+}
+
+func TestBackoffRetry_Retryable(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	// Test case 1: ErrorServiceUnavailable is not retried by default
+	retry := NewBackoffRetry(NewRetryConfig().WithAttempts(5).WithInitialInterval(time.Millisecond))
+	result := retry.TryOnConflictVal(func() (any, error) {
+		return nil, com.ErrorServiceUnavailable
+	})
+	assert.ErrorIs(t, result.TryError(), com.ErrorServiceUnavailable, "Unexpected error")
+	assert.Equal(t, int64(1), result.Count(), "Unexpected count")
+
+	// Test case 2: Custom retryable function
+	retry = NewBackoffRetry(NewRetryConfig().WithAttempts(5).WithInitialInterval(time.Millisecond).WithRetryable(func(err error) bool {
+		return !errors.Is(err, execError)
+	}))
+	result = retry.TryOnConflictVal(func() (any, error) {
+		return nil, execError
+	})
+	assert.ErrorIs(t, result.TryError(), execError, "Unexpected error")
+	assert.Equal(t, int64(1), result.Count(), "Unexpected count")
+}
+
+func TestBackoffRetry_RateLimited(t *testing.T) {
+	limiter := ratelimit.NewRateLimiter(ratelimit.NewConfig().WithRate(1).WithBurst(1))
+	retry := NewBackoffRetry(NewRetryConfig().WithAttempts(5).WithInitialInterval(time.Millisecond))
+	breaker := New(NewConfig().WithBreaker(limiter).WithRetry(retry))
+
+	// The first call takes the only token
+	calls := 0
+	fn := func() error {
+		calls++
+		return nil
+	}
+	assert.NoError(t, breaker.Do(fn), "Unexpected error")
+
+	// Test case 1: A rate limited call is not retried
+	result := retry.TryOnConflictVal(func() (any, error) {
+		return nil, limiter.Do(fn)
+	})
+	assert.ErrorIs(t, result.TryError(), com.ErrorRateLimited, "Unexpected error")
+	assert.Equal(t, int64(1), result.Count(), "Unexpected count")
+
+	// Test case 2: A stopped rate limiter is not retried
+	breaker.Stop()
+	result = retry.TryOnConflictVal(func() (any, error) {
+		return nil, limiter.Do(fn)
+	})
+	assert.ErrorIs(t, result.TryError(), com.ErrorRateLimiterStopped, "Unexpected error")
+	assert.Equal(t, int64(1), result.Count(), "Unexpected count")
+	assert.Equal(t, 1, calls, "Unexpected calls")
+}
+
+func TestBackoffRetry_Context(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	// Test case 1: Errors of the caller's context are not retried, a timeout of one execution is
+	assert.False(t, DefaultRetryableFunc(context.Canceled), "Unexpected retryable")
+	assert.False(t, DefaultRetryableFunc(fmt.Errorf("call: %w", context.DeadlineExceeded)), "Unexpected retryable")
+	assert.True(t, DefaultRetryableFunc(&com.TimeoutError{Timeout: time.Second}), "Expected retryable")
+
+	// Test case 2: The end of the context interrupts the backoff wait
+	retry := NewBackoffRetry(NewRetryConfig().WithAttempts(3).WithInitialInterval(time.Hour).WithJitter(JitterNone))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := retry.(com.RetryCtx).TryOnConflictValCtx(ctx, func() (any, error) {
+		return nil, execError
+	})
+	assert.Less(t, time.Since(start), time.Second, "Unexpected wait")
+	assert.ErrorIs(t, result.TryError(), context.DeadlineExceeded, "Unexpected error")
+	assert.Equal(t, int64(1), result.Count(), "Unexpected count")
+	assert.Equal(t, []error{execError}, result.ExecErrors(), "Unexpected errors")
+
+	// Test case 3: The circuit breaker passes the context to the retry
+	breaker := New(NewConfig().WithRetry(retry))
+	defer breaker.Stop()
+	ctx, cancel = context.WithCancel(context.Background())
+	err := breaker.DoCtx(ctx, func(ctx context.Context) error {
+		cancel()
+		return execError
+	})
+	assert.ErrorIs(t, err, context.Canceled, "Unexpected error")
+}
+
+func TestBackoffRetry_Backoff(t *testing.T) {
+	initial := 10 * time.Millisecond
+	max := 50 * time.Millisecond
+	conf := NewRetryConfig().WithInitialInterval(initial).WithMaxInterval(max).WithMultiplier(2)
+
+	// JitterNone: strictly exponential, capped by the max interval
+	retry := NewBackoffRetry(conf.WithJitter(JitterNone)).(*backoffRetry)
+	assert.Equal(t, 10*time.Millisecond, retry.backoff(1, initial), "Unexpected backoff")
+	assert.Equal(t, 20*time.Millisecond, retry.backoff(2, initial), "Unexpected backoff")
+	assert.Equal(t, 40*time.Millisecond, retry.backoff(3, initial), "Unexpected backoff")
+	assert.Equal(t, 50*time.Millisecond, retry.backoff(4, initial), "Unexpected backoff")
+
+	for i := 0; i < 100; i++ {
+		// JitterFull: [0, base)
+		retry = NewBackoffRetry(conf.WithJitter(JitterFull)).(*backoffRetry)
+		d := retry.backoff(2, initial)
+		assert.GreaterOrEqual(t, d, time.Duration(0), "Unexpected backoff")
+		assert.Less(t, d, 20*time.Millisecond, "Unexpected backoff")
+
+		// JitterEqual: [base/2, base)
+		retry = NewBackoffRetry(conf.WithJitter(JitterEqual)).(*backoffRetry)
+		d = retry.backoff(2, initial)
+		assert.GreaterOrEqual(t, d, 10*time.Millisecond, "Unexpected backoff")
+		assert.Less(t, d, 20*time.Millisecond, "Unexpected backoff")
+
+		// JitterDecorrelated: [initial, min(prev*3, max))
+		retry = NewBackoffRetry(conf.WithJitter(JitterDecorrelated)).(*backoffRetry)
+		d = retry.backoff(2, 20*time.Millisecond)
+		assert.GreaterOrEqual(t, d, initial, "Unexpected backoff")
+		assert.Less(t, d, max, "Unexpected backoff")
+	}
+}
+
+func TestBackoffRetry_WithCircuitBreaker(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	retry := NewBackoffRetry(NewRetryConfig().WithAttempts(3).WithInitialInterval(time.Millisecond))
+	breaker := New(NewConfig().WithRetry(retry))
+	defer breaker.Stop()
+
+	// Retry until success
+	count := 0
+	err := breaker.Do(func() error {
+		count++
+		if count < 2 {
+			return execError
+		}
+		return nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 2, count, "Unexpected count")
+
+	// Typed value is returned through the retry result
+	value, err := Execute(breaker, func() (int, error) {
+		return 42, nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 42, value, "Unexpected value")
+}
@@ -1,7 +1,9 @@
 package tripwire
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	com "github.com/shengyanli1982/tripwire/common"
 )
@@ -31,6 +33,15 @@ func (c *CircuitBreaker) Stop() {
 	})
 }
 
+// retry 通过重试机制执行函数，重试机制实现了 RetryCtx 时，上下文结束会中断退避等待
+// retry executes the function through the retry mechanism, the end of the context interrupts the backoff wait if the retry mechanism implements RetryCtx
+func (c *CircuitBreaker) retry(ctx context.Context, fn com.RetryableFunc) com.RetryResult {
+	if r, ok := c.config.retry.(com.RetryCtx); ok {
+		return r.TryOnConflictValCtx(ctx, fn)
+	}
+	return c.config.retry.TryOnConflictVal(fn)
+}
+
 // Allow 方法手动操作熔断器是否允许请求通过 (纯手动，不建议使用)
 // The Allow method manually operates whether the circuit breaker allows requests to pass through (pure manual, not recommended)
 func (c *CircuitBreaker) Allow() (com.Notifier, error) {
@@ -43,7 +54,7 @@ func (c *CircuitBreaker) Allow() (com.Notifier, error) {
 // DoWithFallbackAcceptable executes the function with fallback and acceptable functions
 func (c *CircuitBreaker) DoWithFallbackAcceptable(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
 	result := c.config.retry.TryOnConflictVal(func() (any, error) {
-		return nil, c.config.breaker.DoWithFallbackAcceptable(fn, fallback, acceptable)
+		return nil, c.config.breaker.DoWithFallbackAcceptable(withTimeout(fn, c.config.timeout), fallback, acceptable)
 	})
 	return result.TryError()
 }
@@ -52,7 +63,7 @@ func (c *CircuitBreaker) DoWithFallbackAcceptable(fn com.HandleFunc, fallback co
 // DoWithFallback executes the function with fallback function
 func (c *CircuitBreaker) DoWithFallback(fn com.HandleFunc, fallback com.FallbackFunc) error {
 	result := c.config.retry.TryOnConflictVal(func() (any, error) {
-		return nil, c.config.breaker.DoWithFallback(fn, fallback)
+		return nil, c.config.breaker.DoWithFallback(withTimeout(fn, c.config.timeout), fallback)
 	})
 	return result.TryError()
 }
@@ -61,7 +72,7 @@ func (c *CircuitBreaker) DoWithFallback(fn com.HandleFunc, fallback com.Fallback
 // DoWithAcceptable executes the function with acceptable function
 func (c *CircuitBreaker) DoWithAcceptable(fn com.HandleFunc, acceptable com.AcceptableFunc) error {
 	result := c.config.retry.TryOnConflictVal(func() (any, error) {
-		return nil, c.config.breaker.DoWithAcceptable(fn, acceptable)
+		return nil, c.config.breaker.DoWithAcceptable(withTimeout(fn, c.config.timeout), acceptable)
 	})
 	return result.TryError()
 }
@@ -70,7 +81,94 @@ func (c *CircuitBreaker) DoWithAcceptable(fn com.HandleFunc, acceptable com.Acce
 // Do executes the function
 func (c *CircuitBreaker) Do(fn com.HandleFunc) error {
 	result := c.config.retry.TryOnConflictVal(func() (any, error) {
-		return nil, c.config.breaker.Do(fn)
+		return nil, c.config.breaker.Do(withTimeout(fn, c.config.timeout))
+	})
+	return result.TryError()
+}
+
+// DoCtxWithFallbackAcceptable 使用上下文、回退和可接受函数执行函数
+// DoCtxWithFallbackAcceptable executes the function with the context, fallback and acceptable functions
+func (c *CircuitBreaker) DoCtxWithFallbackAcceptable(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	result := c.retry(ctx, func() (any, error) {
+		return nil, c.config.breaker.DoCtxWithFallbackAcceptable(ctx, withTimeoutCtx(fn, c.config.timeout), fallback, acceptable)
+	})
+	return result.TryError()
+}
+
+// DoCtxWithFallback 使用上下文和回退函数执行函数
+// DoCtxWithFallback executes the function with the context and fallback function
+func (c *CircuitBreaker) DoCtxWithFallback(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc) error {
+	result := c.retry(ctx, func() (any, error) {
+		return nil, c.config.breaker.DoCtxWithFallback(ctx, withTimeoutCtx(fn, c.config.timeout), fallback)
+	})
+	return result.TryError()
+}
+
+// DoCtxWithAcceptable 使用上下文和可接受函数执行函数
+// DoCtxWithAcceptable executes the function with the context and acceptable function
+func (c *CircuitBreaker) DoCtxWithAcceptable(ctx context.Context, fn com.HandleCtxFunc, acceptable com.AcceptableFunc) error {
+	result := c.retry(ctx, func() (any, error) {
+		return nil, c.config.breaker.DoCtxWithAcceptable(ctx, withTimeoutCtx(fn, c.config.timeout), acceptable)
+	})
+	return result.TryError()
+}
+
+// DoCtx 使用上下文执行函数
+// DoCtx executes the function with the context
+func (c *CircuitBreaker) DoCtx(ctx context.Context, fn com.HandleCtxFunc) error {
+	result := c.retry(ctx, func() (any, error) {
+		return nil, c.config.breaker.DoCtx(ctx, withTimeoutCtx(fn, c.config.timeout))
 	})
 	return result.TryError()
 }
+
+// DoWithTimeout 使用指定的超时时间执行函数，覆盖配置中的超时时间
+// DoWithTimeout executes the function with the given timeout, which overrides the timeout in the configuration
+func (c *CircuitBreaker) DoWithTimeout(fn com.HandleFunc, timeout time.Duration) error {
+	result := c.config.retry.TryOnConflictVal(func() (any, error) {
+		return nil, c.config.breaker.Do(withTimeout(fn, timeout))
+	})
+	return result.TryError()
+}
+
+// DoCtxWithTimeout 使用上下文和指定的超时时间执行函数，覆盖配置中的超时时间
+// DoCtxWithTimeout executes the function with the context and the given timeout, which overrides the timeout in the configuration
+func (c *CircuitBreaker) DoCtxWithTimeout(ctx context.Context, fn com.HandleCtxFunc, timeout time.Duration) error {
+	result := c.retry(ctx, func() (any, error) {
+		return nil, c.config.breaker.DoCtx(ctx, withTimeoutCtx(fn, timeout))
+	})
+	return result.TryError()
+}
+
+// ForceOpen 强制打开熔断器，拒绝所有执行，expiry 为 0 时一直有效，直到被清除。熔断器不支持手动覆盖时返回错误
+// ForceOpen forces the breaker open and rejects all executions, it stays until cleared if expiry is 0. Returns an error if the breaker does not support manual overrides
+func (c *CircuitBreaker) ForceOpen(expiry time.Duration) error {
+	o, ok := c.config.breaker.(com.Overrider)
+	if !ok {
+		return com.ErrorOverrideUnsupported
+	}
+	o.ForceOpen(expiry)
+	return nil
+}
+
+// ForceClose 强制关闭熔断器，允许所有执行，expiry 为 0 时一直有效，直到被清除。熔断器不支持手动覆盖时返回错误
+// ForceClose forces the breaker closed and allows all executions, it stays until cleared if expiry is 0. Returns an error if the breaker does not support manual overrides
+func (c *CircuitBreaker) ForceClose(expiry time.Duration) error {
+	o, ok := c.config.breaker.(com.Overrider)
+	if !ok {
+		return com.ErrorOverrideUnsupported
+	}
+	o.ForceClose(expiry)
+	return nil
+}
+
+// ClearOverride 清除手动覆盖，熔断器恢复自动判断。熔断器不支持手动覆盖时返回错误
+// ClearOverride clears the manual override, the breaker decides automatically again. Returns an error if the breaker does not support manual overrides
+func (c *CircuitBreaker) ClearOverride() error {
+	o, ok := c.config.breaker.(com.Overrider)
+	if !ok {
+		return com.ErrorOverrideUnsupported
+	}
+	o.ClearOverride()
+	return nil
+}
@@ -1,11 +1,15 @@
 package tripwire
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
 	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/ratelimit"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -359,3 +363,204 @@ func TestCircuitBreaker_ParallelInFailure(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestCircuitBreaker_DoCtx(t *testing.T) {
+	var (
+		execError = errors.New("execution error")
+		fbError   = errors.New("fallback error")
+	)
+
+	breaker := New(nil)
+	defer breaker.Stop()
+
+	// Test case 1: Successful execution
+	err := breaker.DoCtx(context.Background(), func(ctx context.Context) error {
+		return nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+
+	// Test case 2: Failed execution with acceptable result
+	err = breaker.DoCtxWithAcceptable(context.Background(), func(ctx context.Context) error {
+		return execError
+	}, func(err error) bool {
+		return err == execError
+	})
+	assert.NoError(t, err, "Unexpected error")
+
+	// Test case 3: Failed execution with fallback
+	err = breaker.DoCtxWithFallback(context.Background(), func(ctx context.Context) error {
+		return execError
+	}, func(err error) error {
+		return fbError
+	})
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+
+	// Test case 4: Context is already done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = breaker.DoCtxWithFallbackAcceptable(ctx, func(ctx context.Context) error {
+		return nil
+	}, func(err error) error {
+		return fbError
+	}, func(err error) bool {
+		return err == nil
+	})
+	assert.ErrorIs(t, err, context.Canceled, "Unexpected error")
+}
+
+type failureCallback struct {
+	cb.Callback
+	lock    sync.Mutex
+	reasons []error
+}
+
+func (c *failureCallback) OnFailure(opterr, reason error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.reasons = append(c.reasons, reason)
+}
+
+func (c *failureCallback) failures() []error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]error(nil), c.reasons...)
+}
+
+func TestCircuitBreaker_Timeout(t *testing.T) {
+	callback := &failureCallback{Callback: cb.NewEmptyCallback()}
+	breaker := New(NewConfig().WithTimeout(20 * time.Millisecond).WithBreaker(cb.NewGoogleBreaker(cb.NewConfig().WithCallback(callback))))
+	defer breaker.Stop()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	// Test case 1: Fast execution is not affected
+	err := breaker.Do(func() error {
+		return nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+
+	// Test case 2: Hung execution is abandoned and counted as a timeout failure
+	start := time.Now()
+	err = breaker.Do(func() error {
+		<-release
+		return nil
+	})
+	assert.True(t, com.IsTimeout(err), "Expected timeout error")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Unexpected error")
+	assert.Less(t, time.Since(start), time.Second, "Execution not abandoned")
+	assert.Len(t, callback.failures(), 1, "Unexpected failure count")
+	assert.True(t, com.IsTimeout(callback.failures()[0]), "Expected timeout failure")
+
+	// Test case 3: Context-aware handler observes the cancellation
+	stopped := make(chan struct{})
+	err = breaker.DoCtx(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	})
+	assert.True(t, com.IsTimeout(err), "Expected timeout error")
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Handler did not observe the cancellation")
+	}
+
+	// Test case 4: Caller cancellation is not reported as a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(5*time.Millisecond, cancel)
+	err = breaker.DoCtx(ctx, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled, "Unexpected error")
+	assert.False(t, com.IsTimeout(err), "Unexpected timeout error")
+	assert.Len(t, callback.failures(), 2, "Unexpected failure count")
+}
+
+func TestCircuitBreaker_DoWithTimeout(t *testing.T) {
+	breaker := New(nil)
+	defer breaker.Stop()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	// Test case 1: Per-call timeout without a configured timeout
+	err := breaker.DoWithTimeout(func() error {
+		<-release
+		return nil
+	}, 10*time.Millisecond)
+	assert.True(t, com.IsTimeout(err), "Expected timeout error")
+
+	var te *com.TimeoutError
+	assert.True(t, errors.As(err, &te), "Expected timeout error")
+	assert.Equal(t, 10*time.Millisecond, te.Timeout, "Unexpected timeout")
+
+	// Test case 2: Per-call timeout with the context
+	err = breaker.DoCtxWithTimeout(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, 10*time.Millisecond)
+	assert.True(t, com.IsTimeout(err), "Expected timeout error")
+
+	// Test case 3: Errors within the timeout are returned unchanged
+	execError := errors.New("execution error")
+	err = breaker.DoWithTimeout(func() error {
+		return execError
+	}, time.Second)
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+	assert.False(t, com.IsTimeout(err), "Unexpected timeout error")
+
+	// Test case 4: Panics are raised in the caller's goroutine with the original stack
+	assert.PanicsWithError(t, "panic: boom", func() {
+		_ = breaker.DoWithTimeout(func() error {
+			panic("boom")
+		}, time.Second)
+	}, "Expected panic")
+}
+
+func TestCircuitBreaker_PanicRecovery(t *testing.T) {
+	callback := &failureCallback{Callback: cb.NewEmptyCallback()}
+	breaker := New(NewConfig().WithTimeout(time.Second).WithBreaker(cb.NewGoogleBreaker(cb.NewConfig().WithCallback(callback).WithPanicRecovery(true))))
+	defer breaker.Stop()
+
+	// Test case 1: Panic in the timed goroutine is recovered and counted as a failure
+	var fallbackErr error
+	err := breaker.DoWithFallback(func() error {
+		panic("boom")
+	}, func(err error) error {
+		fallbackErr = err
+		return nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+
+	var pe *com.PanicError
+	assert.True(t, errors.As(fallbackErr, &pe), "Expected panic error")
+	assert.Equal(t, "boom", pe.Value, "Unexpected panic value")
+	assert.Contains(t, string(pe.Stack), "TestCircuitBreaker_PanicRecovery", "Expected stack of the panicking goroutine")
+	assert.Len(t, callback.failures(), 1, "Unexpected failure count")
+}
+
+func TestCircuitBreaker_Override(t *testing.T) {
+	breaker := New(nil)
+	defer breaker.Stop()
+
+	// Test case 1: The default GoogleBreaker supports overrides
+	assert.NoError(t, breaker.ForceOpen(0), "Unexpected error")
+	err := breaker.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorForcedOpen, "Unexpected error")
+	assert.NoError(t, breaker.ClearOverride(), "Unexpected error")
+	err = breaker.Do(func() error { return nil })
+	assert.NoError(t, err, "Unexpected error")
+
+	// Test case 2: A breaker without overrides reports it
+	plain := New(NewConfig().WithBreaker(ratelimit.NewRateLimiter(nil)))
+	defer plain.Stop()
+	assert.ErrorIs(t, plain.ForceClose(time.Second), com.ErrorOverrideUnsupported, "Unexpected error")
+
+	// Test case 3: The ThreeStateBreaker supports overrides
+	three := New(NewConfig().WithBreaker(cb.NewThreeStateBreaker(nil)))
+	defer three.Stop()
+	assert.NoError(t, three.ForceOpen(0), "Unexpected error")
+	assert.ErrorIs(t, three.Do(func() error { return nil }), com.ErrorForcedOpen, "Unexpected error")
+}
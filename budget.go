@@ -0,0 +1,187 @@
+package tripwire
+
+import (
+	"math"
+	"sync"
+
+	rw "github.com/shengyanli1982/tripwire/internal/rolling"
+)
+
+// 定义默认的重试预算常量值
+// Define the default retry budget constant values
+const (
+	// DefaultBudgetRatio 是重试次数占第一次执行次数比例的默认值。
+	// DefaultBudgetRatio is the default value of the ratio of retries to first attempts.
+	DefaultBudgetRatio = 0.1
+
+	// DefaultBudgetMinRetries 是窗口内始终允许的最少重试次数的默认值。
+	// DefaultBudgetMinRetries is the default value of the min retries always allowed in the window.
+	DefaultBudgetMinRetries = 10
+
+	// DefaultBudgetWindow 是预算统计窗口的默认值 (秒)。
+	// DefaultBudgetWindow is the default value of the budget statistics window (seconds).
+	DefaultBudgetWindow = 10
+)
+
+// BudgetConfig 是重试预算的配置。
+// BudgetConfig is the configuration for the retry budget.
+type BudgetConfig struct {
+	ratio      float64
+	minRetries int
+	window     int
+}
+
+// NewBudgetConfig 返回重试预算的新配置。
+// NewBudgetConfig returns a new configuration for the retry budget.
+func NewBudgetConfig() *BudgetConfig {
+	return &BudgetConfig{
+		ratio:      DefaultBudgetRatio,
+		minRetries: DefaultBudgetMinRetries,
+		window:     DefaultBudgetWindow,
+	}
+}
+
+// DefaultBudgetConfig 返回重试预算的默认配置。
+// DefaultBudgetConfig returns the default configuration for the retry budget.
+func DefaultBudgetConfig() *BudgetConfig {
+	return NewBudgetConfig()
+}
+
+// WithRatio 设置重试次数占第一次执行次数的比例。
+// WithRatio sets the ratio of retries to first attempts.
+func (c *BudgetConfig) WithRatio(ratio float64) *BudgetConfig {
+	c.ratio = ratio
+	return c
+}
+
+// WithMinRetries 设置窗口内始终允许的最少重试次数。
+// WithMinRetries sets the min retries always allowed in the window.
+func (c *BudgetConfig) WithMinRetries(retries int) *BudgetConfig {
+	c.minRetries = retries
+	return c
+}
+
+// WithWindow 设置预算统计窗口 (秒)。
+// WithWindow sets the budget statistics window (seconds).
+func (c *BudgetConfig) WithWindow(window int) *BudgetConfig {
+	c.window = window
+	return c
+}
+
+// isBudgetConfigValid 检查配置是否有效，如果无效则使用默认值。
+// isBudgetConfigValid checks if the configuration is valid, uses default values if invalid.
+func isBudgetConfigValid(conf *BudgetConfig) *BudgetConfig {
+	if conf != nil {
+		if conf.ratio < 0 {
+			conf.ratio = DefaultBudgetRatio
+		}
+		if conf.minRetries < 0 {
+			conf.minRetries = DefaultBudgetMinRetries
+		}
+		if conf.window <= 0 {
+			conf.window = DefaultBudgetWindow
+		}
+	} else {
+		conf = DefaultBudgetConfig()
+	}
+
+	return conf
+}
+
+// BudgetStats 是重试预算的状态快照。
+// BudgetStats is a snapshot of the retry budget state.
+type BudgetStats struct {
+	// Requests 是窗口内第一次执行的次数。
+	// Requests is the number of first attempts in the window.
+	Requests uint64
+
+	// Retries 是窗口内重试的次数。
+	// Retries is the number of retries in the window.
+	Retries uint64
+
+	// Available 是窗口内还可以使用的重试次数。
+	// Available is the number of retries still available in the window.
+	Available float64
+}
+
+// RetryBudget 是在多次调用之间共享的重试预算，限制重试的放大效应。
+// RetryBudget is a retry budget shared across calls to cap retry amplification.
+type RetryBudget struct {
+	config   *BudgetConfig
+	requests *rw.RollingWindow // 第一次执行的滚动窗口 Rolling window of first attempts
+	retries  *rw.RollingWindow // 重试的滚动窗口 Rolling window of retries
+	lock     sync.Mutex
+	once     sync.Once
+}
+
+// NewRetryBudget 函数创建并返回一个新的重试预算实例
+// The NewRetryBudget function creates and returns a new instance of retry budget
+func NewRetryBudget(conf *BudgetConfig) *RetryBudget {
+	conf = isBudgetConfigValid(conf)
+	return &RetryBudget{
+		config:   conf,
+		requests: rw.NewRollingWindow(conf.window),
+		retries:  rw.NewRollingWindow(conf.window),
+	}
+}
+
+// Stop 停止重试预算
+// Stop stops the retry budget
+func (b *RetryBudget) Stop() {
+	b.once.Do(func() {
+		b.requests.Stop()
+		b.retries.Stop()
+	})
+}
+
+// RecordAttempt 记录一次第一次执行
+// RecordAttempt records a first attempt
+func (b *RetryBudget) RecordAttempt() {
+	_ = b.requests.Add(1)
+}
+
+// available 返回窗口内还可以使用的重试次数，调用方必须持有锁
+// available returns the number of retries still available in the window, the caller must hold the lock
+func (b *RetryBudget) available() (uint64, uint64, float64) {
+	_, requests, err := b.requests.Sum()
+	if err != nil {
+		return 0, 0, 0
+	}
+	_, retries, err := b.retries.Sum()
+	if err != nil {
+		return 0, 0, 0
+	}
+	tokens := float64(b.config.minRetries) + b.config.ratio*float64(requests) - float64(retries)
+	return requests, retries, math.Max(0, tokens)
+}
+
+// TryRetry 尝试从预算中取出一次重试，成功返回 true
+// TryRetry tries to take one retry from the budget, returns true on success
+func (b *RetryBudget) TryRetry() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// 预算不足，拒绝重试
+	// Not enough budget, reject the retry
+	if _, _, tokens := b.available(); tokens < 1 {
+		return false
+	}
+
+	// 记录一次重试
+	// Record a retry
+	return b.retries.Add(1) == nil
+}
+
+// Stats 返回重试预算的状态快照
+// Stats returns a snapshot of the retry budget state
+func (b *RetryBudget) Stats() BudgetStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	requests, retries, tokens := b.available()
+	return BudgetStats{
+		Requests:  requests,
+		Retries:   retries,
+		Available: tokens,
+	}
+}
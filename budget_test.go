@@ -0,0 +1,72 @@
+package tripwire
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget_TryRetry(t *testing.T) {
+	budget := NewRetryBudget(NewBudgetConfig().WithRatio(0.5).WithMinRetries(2))
+	defer budget.Stop()
+
+	// Only min retries are available without requests
+	assert.True(t, budget.TryRetry(), "Expected retry")
+	assert.True(t, budget.TryRetry(), "Expected retry")
+	assert.False(t, budget.TryRetry(), "Unexpected retry")
+
+	// Every 2 first attempts add 1 retry
+	for i := 0; i < 4; i++ {
+		budget.RecordAttempt()
+	}
+	assert.True(t, budget.TryRetry(), "Expected retry")
+	assert.True(t, budget.TryRetry(), "Expected retry")
+	assert.False(t, budget.TryRetry(), "Unexpected retry")
+
+	// Check the stats
+	stats := budget.Stats()
+	assert.Equal(t, uint64(4), stats.Requests, "Unexpected requests")
+	assert.Equal(t, uint64(4), stats.Retries, "Unexpected retries")
+	assert.Equal(t, float64(0), stats.Available, "Unexpected available")
+}
+
+func TestRetryBudget_Stop(t *testing.T) {
+	budget := NewRetryBudget(nil)
+	budget.Stop()
+
+	// No retries after stop
+	assert.False(t, budget.TryRetry(), "Unexpected retry")
+	assert.Equal(t, BudgetStats{}, budget.Stats(), "Unexpected stats")
+}
+
+func TestBackoffRetry_WithBudget(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	budget := NewRetryBudget(NewBudgetConfig().WithRatio(0).WithMinRetries(3))
+	defer budget.Stop()
+
+	// Two retries share the same budget
+	conf := NewRetryConfig().WithAttempts(5).WithInitialInterval(time.Millisecond).WithBudget(budget)
+	retry1 := NewBackoffRetry(conf)
+	retry2 := NewBackoffRetry(conf)
+
+	// The first call uses 3 retries, 4 attempts in total
+	result := retry1.TryOnConflictVal(func() (any, error) {
+		return nil, execError
+	})
+	assert.ErrorIs(t, result.TryError(), execError, "Unexpected error")
+	assert.Equal(t, int64(4), result.Count(), "Unexpected count")
+
+	// The budget is exhausted, the second call only runs once
+	result = retry2.TryOnConflictVal(func() (any, error) {
+		return nil, execError
+	})
+	assert.ErrorIs(t, result.TryError(), execError, "Unexpected error")
+	assert.Equal(t, int64(1), result.Count(), "Unexpected count")
+
+	stats := budget.Stats()
+	assert.Equal(t, uint64(2), stats.Requests, "Unexpected requests")
+	assert.Equal(t, uint64(3), stats.Retries, "Unexpected retries")
+}
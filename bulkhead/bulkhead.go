@@ -0,0 +1,275 @@
+package bulkhead
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// isRejected 检查错误是否是隔舱拒绝执行的错误。
+// isRejected checks if the error is an error of the bulkhead rejecting the execution.
+func isRejected(err error) bool {
+	return err == com.ErrorBulkheadFull || err == com.ErrorBulkheadStopped
+}
+
+// Bulkhead 是一个限制并发执行数量的隔舱，防止一个缓慢的依赖耗尽所有的协程。
+// 隔舱已满时，执行可以在有界的队列中等待空闲名额，否则被拒绝并返回 ErrorBulkheadFull。
+// Bulkhead is a bulkhead that limits the number of concurrent executions, preventing one slow dependency from consuming every goroutine.
+// When the bulkhead is full, an execution can wait for a free slot in a bounded queue, otherwise it is rejected with ErrorBulkheadFull.
+type Bulkhead struct {
+	config  *Config       // 隔舱的配置 Config of the bulkhead
+	slots   chan struct{} // 执行名额 Execution slots
+	waiting int64         // 正在队列中等待的数量 Number of executions waiting in the queue
+	stopCh  chan struct{} // 停止信号 Stop signal
+	once    sync.Once     // 用于确保只停止一次 The sync.Once to ensure stopping only once
+}
+
+// NewBulkhead 返回一个新的隔舱。
+// NewBulkhead returns a new bulkhead.
+func NewBulkhead(conf *Config) *Bulkhead {
+	conf = isConfigValid(conf)
+	return &Bulkhead{
+		config: conf,
+		slots:  make(chan struct{}, conf.maxConcurrent),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Stop 停止隔舱，正在等待的执行被拒绝，内部的熔断器也被停止。
+// Stop stops the bulkhead, the waiting executions are rejected, and the inner breaker is stopped too.
+func (b *Bulkhead) Stop() {
+	b.once.Do(func() {
+		close(b.stopCh)
+		if b.config.breaker != nil {
+			b.config.breaker.Stop()
+		}
+	})
+}
+
+// InFlight 返回正在执行的数量。
+// InFlight returns the number of executions in flight.
+func (b *Bulkhead) InFlight() int {
+	return len(b.slots)
+}
+
+// Waiting 返回正在队列中等待的数量。
+// Waiting returns the number of executions waiting in the queue.
+func (b *Bulkhead) Waiting() int {
+	return int(atomic.LoadInt64(&b.waiting))
+}
+
+// acquire 获取一个执行名额，隔舱已满时在队列中等待，直到有空闲名额、等待超时、上下文结束或隔舱停止。
+// acquire acquires an execution slot, waits in the queue when the bulkhead is full, until a slot is free, the wait times out, the context is done or the bulkhead is stopped.
+func (b *Bulkhead) acquire(ctx context.Context) error {
+	select {
+	case <-b.stopCh:
+		return com.ErrorBulkheadStopped
+	default:
+	}
+
+	// 有空闲名额，直接获取。
+	// A slot is free, acquire it directly.
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	// 等待队列已满，拒绝执行。
+	// The wait queue is full, reject the execution.
+	if atomic.AddInt64(&b.waiting, 1) > int64(b.config.maxQueue) {
+		atomic.AddInt64(&b.waiting, -1)
+		return com.ErrorBulkheadFull
+	}
+	defer atomic.AddInt64(&b.waiting, -1)
+
+	// 没有设置最长等待时间时，timeout 为 nil，永远不会触发。
+	// Without the maximum wait time, timeout is nil and never fires.
+	var timeout <-chan time.Time
+	if b.config.maxWait > 0 {
+		timer := time.NewTimer(b.config.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return com.ErrorBulkheadFull
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.stopCh:
+		return com.ErrorBulkheadStopped
+	}
+}
+
+// release 释放一个执行名额。
+// release releases an execution slot.
+func (b *Bulkhead) release() {
+	<-b.slots
+}
+
+// bulkheadNotifier 在标记执行结果时释放执行名额，并通知内部的熔断器。
+// bulkheadNotifier releases the execution slot when the result is marked, and notifies the inner breaker.
+type bulkheadNotifier struct {
+	bulkhead *Bulkhead
+	inner    com.Notifier
+	once     sync.Once
+}
+
+// MarkSuccess 标记执行成功。
+// MarkSuccess marks the execution as successful.
+func (n *bulkheadNotifier) MarkSuccess() {
+	n.once.Do(func() {
+		if n.inner != nil {
+			n.inner.MarkSuccess()
+		}
+		n.bulkhead.release()
+	})
+}
+
+// MarkFailure 标记执行失败。
+// MarkFailure marks the execution as failed.
+func (n *bulkheadNotifier) MarkFailure(reason error) {
+	n.once.Do(func() {
+		if n.inner != nil {
+			n.inner.MarkFailure(reason)
+		}
+		n.bulkhead.release()
+	})
+}
+
+// Release 释放执行名额，并释放内部熔断器的资源，不记录结果。
+// Release releases the execution slot and the resources of the inner breaker, without recording the result.
+func (n *bulkheadNotifier) Release() {
+	n.once.Do(func() {
+		if r, ok := n.inner.(com.Releaser); ok {
+			r.Release()
+		}
+		n.bulkhead.release()
+	})
+}
+
+// Allow 获取一个执行名额，并检查内部的熔断器是否允许执行。
+// 返回的 Notifier 必须被调用一次，否则执行名额不会被释放。
+// Allow acquires an execution slot, and checks if the inner breaker allows the execution.
+// The returned Notifier must be called once, otherwise the execution slot is not released.
+func (b *Bulkhead) Allow() (com.Notifier, error) {
+	if err := b.acquire(context.Background()); err != nil {
+		return nil, err
+	}
+
+	n := &bulkheadNotifier{bulkhead: b}
+	if b.config.breaker != nil {
+		inner, err := b.config.breaker.Allow()
+		if err != nil {
+			b.release()
+			return nil, err
+		}
+		n.inner = inner
+	}
+	return n, nil
+}
+
+// do 获取执行名额后执行函数，有内部的熔断器时由它执行。
+// do executes the function after acquiring an execution slot, by the inner breaker if there is one.
+func (b *Bulkhead) do(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 隔舱拒绝执行，执行回退函数或返回错误。
+	// The bulkhead rejects the execution, execute the fallback function or return the error.
+	if err := b.acquire(context.Background()); err != nil {
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+	defer b.release()
+
+	if b.config.breaker != nil {
+		return b.config.breaker.DoWithFallbackAcceptable(fn, fallback, acceptable)
+	}
+	if err := fn(); !acceptable(err) {
+		return err
+	}
+	return nil
+}
+
+// Do 执行函数并返回错误。
+// Do executes the function and returns the error.
+func (b *Bulkhead) Do(fn com.HandleFunc) error {
+	return b.do(fn, nil, cb.DefaultAcceptableFunc)
+}
+
+// DoWithAcceptable 使用给定的可接受函数执行函数并返回错误。
+// DoWithAcceptable executes the function with the given acceptable function and returns the error.
+func (b *Bulkhead) DoWithAcceptable(fn com.HandleFunc, acceptable com.AcceptableFunc) error {
+	return b.do(fn, nil, acceptable)
+}
+
+// DoWithFallback 使用给定的回退函数执行函数并返回错误。
+// DoWithFallback executes the function with the given fallback function and returns the error.
+func (b *Bulkhead) DoWithFallback(fn com.HandleFunc, fallback com.FallbackFunc) error {
+	return b.do(fn, fallback, cb.DefaultAcceptableFunc)
+}
+
+// DoWithFallbackAcceptable 使用给定的回退和可接受函数执行函数并返回错误。
+// DoWithFallbackAcceptable executes the function with the given fallback and acceptable functions and returns the error.
+func (b *Bulkhead) DoWithFallbackAcceptable(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return b.do(fn, fallback, acceptable)
+}
+
+// doCtx 获取执行名额后使用上下文执行函数，在队列中等待时上下文结束会直接返回上下文的错误。
+// doCtx executes the function with the context after acquiring an execution slot, the error of the context is returned directly if it is done while waiting in the queue.
+func (b *Bulkhead) doCtx(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 如果上下文已经结束，快速失败。
+	// If the context is already done, fail fast.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 隔舱拒绝执行，执行回退函数或返回错误。
+	// The bulkhead rejects the execution, execute the fallback function or return the error.
+	if err := b.acquire(ctx); err != nil {
+		if fallback != nil && isRejected(err) {
+			return fallback(err)
+		}
+		return err
+	}
+	defer b.release()
+
+	if b.config.breaker != nil {
+		return b.config.breaker.DoCtxWithFallbackAcceptable(ctx, fn, fallback, acceptable)
+	}
+	if err := fn(ctx); !acceptable(err) {
+		return err
+	}
+	return nil
+}
+
+// DoCtx 使用上下文执行函数并返回错误。
+// DoCtx executes the function with the context and returns the error.
+func (b *Bulkhead) DoCtx(ctx context.Context, fn com.HandleCtxFunc) error {
+	return b.doCtx(ctx, fn, nil, cb.DefaultAcceptableFunc)
+}
+
+// DoCtxWithAcceptable 使用上下文和给定的可接受函数执行函数并返回错误。
+// DoCtxWithAcceptable executes the function with the context and the given acceptable function and returns the error.
+func (b *Bulkhead) DoCtxWithAcceptable(ctx context.Context, fn com.HandleCtxFunc, acceptable com.AcceptableFunc) error {
+	return b.doCtx(ctx, fn, nil, acceptable)
+}
+
+// DoCtxWithFallback 使用上下文和给定的回退函数执行函数并返回错误。
+// DoCtxWithFallback executes the function with the context and the given fallback function and returns the error.
+func (b *Bulkhead) DoCtxWithFallback(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc) error {
+	return b.doCtx(ctx, fn, fallback, cb.DefaultAcceptableFunc)
+}
+
+// DoCtxWithFallbackAcceptable 使用上下文和给定的回退和可接受函数执行函数并返回错误。
+// DoCtxWithFallbackAcceptable executes the function with the context and the given fallback and acceptable functions and returns the error.
+func (b *Bulkhead) DoCtxWithFallbackAcceptable(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return b.doCtx(ctx, fn, fallback, acceptable)
+}
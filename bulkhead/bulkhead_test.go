@@ -0,0 +1,160 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// occupy 占满隔舱的名额，返回释放的函数。
+func occupy(t *testing.T, b *Bulkhead, n int) func() {
+	release := make(chan struct{})
+	var started, done sync.WaitGroup
+	for i := 0; i < n; i++ {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			err := b.Do(func() error {
+				started.Done()
+				<-release
+				return nil
+			})
+			assert.NoError(t, err, "Unexpected error")
+		}()
+	}
+	started.Wait()
+	return func() {
+		close(release)
+		done.Wait()
+	}
+}
+
+func TestBulkhead_Full(t *testing.T) {
+	b := NewBulkhead(NewConfig().WithMaxConcurrent(2))
+	defer b.Stop()
+
+	release := occupy(t, b, 2)
+	assert.Equal(t, 2, b.InFlight(), "Unexpected in flight count")
+
+	// Test case 1: Rejected without a queue
+	err := b.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorBulkheadFull, "Unexpected error")
+
+	// Test case 2: Fallback receives the bulkhead full error
+	err = b.DoWithFallback(func() error { return nil }, func(err error) error {
+		assert.ErrorIs(t, err, com.ErrorBulkheadFull, "Unexpected error")
+		return nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+
+	release()
+	assert.Equal(t, 0, b.InFlight(), "Unexpected in flight count")
+
+	// Test case 3: Accepted after the slots are released
+	err = b.Do(func() error { return nil })
+	assert.NoError(t, err, "Unexpected error")
+}
+
+func TestBulkhead_Queue(t *testing.T) {
+	b := NewBulkhead(NewConfig().WithMaxConcurrent(1).WithMaxQueue(1).WithMaxWait(20 * time.Millisecond))
+	defer b.Stop()
+
+	// Test case 1: Waiting caller times out
+	release := occupy(t, b, 1)
+	start := time.Now()
+	err := b.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorBulkheadFull, "Unexpected error")
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond, "Caller did not wait")
+
+	// Test case 2: Waiting caller gets the released slot
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Do(func() error { return nil })
+	}()
+	for b.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Test case 3: The queue is full
+	err = b.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorBulkheadFull, "Unexpected error")
+
+	release()
+	assert.NoError(t, <-done, "Unexpected error")
+}
+
+func TestBulkhead_DoCtx(t *testing.T) {
+	b := NewBulkhead(NewConfig().WithMaxConcurrent(1).WithMaxQueue(1))
+	defer b.Stop()
+
+	release := occupy(t, b, 1)
+	defer release()
+
+	// Test case 1: Context ends while waiting, the fallback is not called
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := b.DoCtxWithFallback(ctx, func(ctx context.Context) error { return nil }, func(err error) error {
+		t.Fatal("Unexpected fallback")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Unexpected error")
+
+	// Test case 2: Stop rejects the waiting caller
+	done := make(chan error, 1)
+	go func() {
+		done <- b.DoCtx(context.Background(), func(ctx context.Context) error { return nil })
+	}()
+	for b.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	b.Stop()
+	assert.ErrorIs(t, <-done, com.ErrorBulkheadStopped, "Unexpected error")
+}
+
+func TestBulkhead_WithBreaker(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	breaker := cb.NewThreeStateBreaker(cb.NewConfig().WithMinRequests(1).WithFailureThreshold(0.5))
+	b := NewBulkhead(NewConfig().WithMaxConcurrent(1).WithBreaker(breaker))
+	defer b.Stop()
+
+	// Test case 1: Failures are recorded by the inner breaker
+	err := b.Do(func() error { return execError })
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+	assert.Equal(t, cb.StateOpen, breaker.State(), "State mismatch")
+
+	// Test case 2: The inner breaker rejects, the slot is released
+	err = b.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+	assert.Equal(t, 0, b.InFlight(), "Unexpected in flight count")
+
+	// Test case 3: Allow releases the slot when the inner breaker rejects
+	notifier, err := b.Allow()
+	assert.Nil(t, notifier, "Unexpected notifier")
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+	assert.Equal(t, 0, b.InFlight(), "Unexpected in flight count")
+}
+
+func TestBulkhead_Allow(t *testing.T) {
+	b := NewBulkhead(NewConfig().WithMaxConcurrent(1))
+	defer b.Stop()
+
+	notifier, err := b.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 1, b.InFlight(), "Unexpected in flight count")
+
+	_, err = b.Allow()
+	assert.ErrorIs(t, err, com.ErrorBulkheadFull, "Unexpected error")
+
+	// Marking twice releases the slot only once
+	notifier.MarkSuccess()
+	notifier.MarkFailure(nil)
+	assert.Equal(t, 0, b.InFlight(), "Unexpected in flight count")
+}
@@ -0,0 +1,94 @@
+package bulkhead
+
+import (
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+const (
+	// DefaultMaxConcurrent 是最大并发执行数的默认值。
+	// DefaultMaxConcurrent is the default value of the maximum concurrent executions.
+	DefaultMaxConcurrent = 100
+
+	// DefaultMaxQueue 是等待队列长度的默认值，0 表示不排队，隔舱已满时立即拒绝。
+	// DefaultMaxQueue is the default length of the wait queue, 0 means no queueing, executions are rejected immediately when the bulkhead is full.
+	DefaultMaxQueue = 0
+
+	// DefaultMaxWait 是在队列中等待的最长时间的默认值，0 表示一直等待，直到有空闲名额或上下文结束。
+	// DefaultMaxWait is the default value of the maximum time waiting in the queue, 0 means waiting until a slot is free or the context is done.
+	DefaultMaxWait = time.Duration(0)
+)
+
+// Config 是隔舱的配置。
+// Config is the configuration for the bulkhead.
+type Config struct {
+	maxConcurrent int
+	maxQueue      int
+	maxWait       time.Duration
+	breaker       com.Breaker
+}
+
+// NewConfig 返回隔舱的新配置。
+// NewConfig returns a new configuration for the bulkhead.
+func NewConfig() *Config {
+	return &Config{
+		maxConcurrent: DefaultMaxConcurrent,
+		maxQueue:      DefaultMaxQueue,
+		maxWait:       DefaultMaxWait,
+	}
+}
+
+// DefaultConfig 返回隔舱的默认配置。
+// DefaultConfig returns the default configuration for the bulkhead.
+func DefaultConfig() *Config {
+	return NewConfig()
+}
+
+// WithMaxConcurrent 设置同时执行的最大数量。
+// WithMaxConcurrent sets the maximum number of concurrent executions.
+func (c *Config) WithMaxConcurrent(max int) *Config {
+	c.maxConcurrent = max
+	return c
+}
+
+// WithMaxQueue 设置隔舱已满时可以排队等待的最大数量。
+// WithMaxQueue sets the maximum number of executions that can wait in the queue when the bulkhead is full.
+func (c *Config) WithMaxQueue(max int) *Config {
+	c.maxQueue = max
+	return c
+}
+
+// WithMaxWait 设置在队列中等待的最长时间。
+// WithMaxWait sets the maximum time waiting in the queue.
+func (c *Config) WithMaxWait(wait time.Duration) *Config {
+	c.maxWait = wait
+	return c
+}
+
+// WithBreaker 设置隔舱内部的熔断器，获得执行名额后由它执行函数，例如 GoogleBreaker。
+// WithBreaker sets the breaker inside the bulkhead which executes the function after a slot is acquired, e.g. GoogleBreaker.
+func (c *Config) WithBreaker(breaker com.Breaker) *Config {
+	c.breaker = breaker
+	return c
+}
+
+// isConfigValid 检查配置是否有效。
+// isConfigValid checks if the configuration is valid.
+func isConfigValid(conf *Config) *Config {
+	if conf != nil {
+		if conf.maxConcurrent <= 0 {
+			conf.maxConcurrent = DefaultMaxConcurrent
+		}
+		if conf.maxQueue < 0 {
+			conf.maxQueue = DefaultMaxQueue
+		}
+		if conf.maxWait < 0 {
+			conf.maxWait = DefaultMaxWait
+		}
+	} else {
+		conf = DefaultConfig()
+	}
+
+	return conf
+}
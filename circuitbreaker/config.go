@@ -1,5 +1,13 @@
 package circuitbreaker
 
+import (
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	rw "github.com/shengyanli1982/tripwire/internal/rolling"
+	"github.com/shengyanli1982/tripwire/internal/utils"
+)
+
 // 定义默认的常量值
 // Define the default constant values
 const (
@@ -14,25 +22,90 @@ const (
 	// DefaultStateWindow 是 state window 的默认值。
 	// DefaultStateWindow is the default value of state window.
 	DefaultStateWindow = 10
+
+	// DefaultFailureThreshold 是 failure threshold 的默认值。
+	// DefaultFailureThreshold is the default value of failure threshold.
+	DefaultFailureThreshold = 0.5
+
+	// DefaultMinRequests 是 min requests 的默认值。
+	// DefaultMinRequests is the default value of min requests.
+	DefaultMinRequests = 10
+
+	// DefaultOpenTimeout 是 open timeout 的默认值。
+	// DefaultOpenTimeout is the default value of open timeout.
+	DefaultOpenTimeout = 5 * time.Second
+
+	// DefaultHalfOpenProbes 是 half-open probes 的默认值。
+	// DefaultHalfOpenProbes is the default value of half-open probes.
+	DefaultHalfOpenProbes = 3
+
+	// DefaultStateDebounce 是 state debounce 的默认值。
+	// DefaultStateDebounce is the default value of state debounce.
+	DefaultStateDebounce = time.Second
+
+	// DefaultRejectingRatio 是 rejecting ratio 的默认值。
+	// DefaultRejectingRatio is the default value of rejecting ratio.
+	DefaultRejectingRatio = 0.9
+
+	// DefaultSlowCallWeight 是 slow call weight 的默认值。
+	// DefaultSlowCallWeight is the default value of slow call weight.
+	DefaultSlowCallWeight = 1.0
+)
+
+// WindowMode 是熔断器统计数据使用的滚动窗口实现。
+// WindowMode is the rolling window implementation used for the statistics of the breaker.
+type WindowMode int
+
+const (
+	// WindowModeMutex 使用一个由互斥锁保护的滚动窗口，是默认的模式。
+	// WindowModeMutex uses one rolling window protected by a mutex, it is the default mode.
+	WindowModeMutex WindowMode = iota
+
+	// WindowModeAtomic 使用原子计数器的滚动窗口，写入和读取都不需要锁，适合高并发场景。
+	// WindowModeAtomic uses a rolling window with atomic counters, neither writes nor reads take a lock, it suits highly concurrent workloads.
+	WindowModeAtomic
 )
 
 // Config 是熔断器的配置。
 // Config is the configuration for the breaker.
 type Config struct {
-	k           float64
-	protected   int
-	callback    Callback
-	stateWindow int
+	k                float64
+	protected        int
+	callback         Callback
+	stateWindow      int
+	failureThreshold float64
+	minRequests      int
+	openTimeout      time.Duration
+	halfOpenProbes   int
+	stateDebounce    time.Duration
+	rejectingRatio   float64
+	clock            com.Clock
+	random           RandomSource
+	window           time.Duration
+	slotInterval     time.Duration
+	windowMode       WindowMode
+	slowThreshold    time.Duration
+	slowWeight       float64
+	latency          bool
+	recoverPanics    bool
 }
 
 // NewConfig 返回熔断器的新配置。
 // NewConfig returns a new configuration for the breaker.
 func NewConfig() *Config {
 	return &Config{
-		k:           DefaultKValue,
-		protected:   DefaultProtected,
-		callback:    NewEmptyCallback(),
-		stateWindow: DefaultStateWindow,
+		k:                DefaultKValue,
+		protected:        DefaultProtected,
+		callback:         NewEmptyCallback(),
+		stateWindow:      DefaultStateWindow,
+		failureThreshold: DefaultFailureThreshold,
+		minRequests:      DefaultMinRequests,
+		openTimeout:      DefaultOpenTimeout,
+		halfOpenProbes:   DefaultHalfOpenProbes,
+		stateDebounce:    DefaultStateDebounce,
+		rejectingRatio:   DefaultRejectingRatio,
+		clock:            utils.SystemClock{},
+		slowWeight:       DefaultSlowCallWeight,
 	}
 }
 
@@ -66,10 +139,178 @@ func (c *Config) WithProtected(protected int) *Config {
 // WithStateWindow 设置配置的 state window 值。
 // WithStateWindow sets the state window of the configuration.
 func (c *Config) WithStateWindow(window int) *Config {
-	c.protected = window
+	c.stateWindow = window
+	return c
+}
+
+// WithWindow 设置滚动窗口的时长，优先于 WithStateWindow。时长必须是插槽间隔的整数倍。
+// WithWindow sets the duration of the rolling window, takes precedence over WithStateWindow. The duration must be a multiple of the slot interval.
+func (c *Config) WithWindow(window time.Duration) *Config {
+	c.window = window
+	return c
+}
+
+// WithSlotInterval 设置滚动窗口中每个插槽的时长，即统计的粒度。
+// WithSlotInterval sets the duration of each slot in the rolling window, i.e. the granularity of the statistics.
+func (c *Config) WithSlotInterval(interval time.Duration) *Config {
+	c.slotInterval = interval
+	return c
+}
+
+// WithFailureThreshold 设置配置的 failure threshold 值，失败率达到该值时三态熔断器打开。
+// WithFailureThreshold sets the failure threshold of the configuration, the three-state breaker opens when the failure ratio reaches it.
+func (c *Config) WithFailureThreshold(threshold float64) *Config {
+	c.failureThreshold = threshold
+	return c
+}
+
+// WithMinRequests 设置配置的 min requests 值，窗口内请求数达到该值后才会计算失败率。
+// WithMinRequests sets the min requests of the configuration, the failure ratio is only evaluated after the window holds this many requests.
+func (c *Config) WithMinRequests(requests int) *Config {
+	c.minRequests = requests
+	return c
+}
+
+// WithOpenTimeout 设置配置的 open timeout 值，即三态熔断器保持打开的时间。
+// WithOpenTimeout sets the open timeout of the configuration, i.e. how long the three-state breaker stays open.
+func (c *Config) WithOpenTimeout(timeout time.Duration) *Config {
+	c.openTimeout = timeout
+	return c
+}
+
+// WithHalfOpenProbes 设置配置的 half-open probes 值，即半开状态下允许的探测请求数。
+// WithHalfOpenProbes sets the half-open probes of the configuration, i.e. the number of probe requests allowed in the half-open state.
+func (c *Config) WithHalfOpenProbes(probes int) *Config {
+	c.halfOpenProbes = probes
+	return c
+}
+
+// WithStateDebounce 设置配置的 state debounce 值，新状态必须保持该时间后才会发出状态变化事件，0 表示立即发出。
+// WithStateDebounce sets the state debounce of the configuration, a new state must hold for this long before the state change event is emitted, 0 means emit immediately.
+func (c *Config) WithStateDebounce(debounce time.Duration) *Config {
+	c.stateDebounce = debounce
+	return c
+}
+
+// WithRejectingRatio 设置配置的 rejecting ratio 值，熔断比率达到该值时 GoogleBreaker 进入拒绝状态。
+// WithRejectingRatio sets the rejecting ratio of the configuration, the GoogleBreaker enters the rejecting state when the fuse ratio reaches it.
+func (c *Config) WithRejectingRatio(ratio float64) *Config {
+	c.rejectingRatio = ratio
+	return c
+}
+
+// WithClock 设置配置的时钟，滚动窗口和状态计时都使用该时钟，测试时可以替换为手动推进的时钟。
+// WithClock sets the clock of the configuration, the rolling window and the state timing use it, it can be replaced by a manually advanced clock in tests.
+func (c *Config) WithClock(clock com.Clock) *Config {
+	c.clock = clock
+	return c
+}
+
+// WithRandom 设置 GoogleBreaker 决定是否拒绝执行时使用的随机数来源，nil 表示每个熔断器使用自己的 SafeRandom。
+// WithRandom sets the random source used by the GoogleBreaker to decide whether to reject an execution, nil means every breaker uses its own SafeRandom.
+func (c *Config) WithRandom(random RandomSource) *Config {
+	c.random = random
 	return c
 }
 
+// WithWindowMode 设置滚动窗口的实现。WindowModeAtomic 模式下，如果没有设置随机数来源，GoogleBreaker 使用无锁的 PooledRandom。
+// WithWindowMode sets the rolling window implementation. In the WindowModeAtomic mode, the GoogleBreaker uses the lock-free PooledRandom if no random source is set.
+func (c *Config) WithWindowMode(mode WindowMode) *Config {
+	c.windowMode = mode
+	return c
+}
+
+// WithSlowCallThreshold 设置慢调用的阈值，执行时间达到该值的调用视为慢调用，0 表示不检测慢调用。
+// WithSlowCallThreshold sets the threshold of slow calls, calls taking at least this long are slow calls, 0 means slow calls are not detected.
+func (c *Config) WithSlowCallThreshold(threshold time.Duration) *Config {
+	c.slowThreshold = threshold
+	return c
+}
+
+// WithSlowCallWeight 设置成功的慢调用计为失败的权重，取值范围 (0, 1]，1 表示慢调用完全计为失败。
+// WithSlowCallWeight sets the weight with which a successful slow call counts as a failure, in (0, 1], 1 means a slow call counts as a full failure.
+func (c *Config) WithSlowCallWeight(weight float64) *Config {
+	c.slowWeight = weight
+	return c
+}
+
+// WithLatencyHistogram 设置是否在滚动窗口中记录执行时间的直方图，用于查询延迟的分位数。
+// WithLatencyHistogram sets whether to record the histogram of execution times in a rolling window, used to query latency quantiles.
+func (c *Config) WithLatencyHistogram(enable bool) *Config {
+	c.latency = enable
+	return c
+}
+
+// WithPanicRecovery 设置是否恢复执行中的 panic。启用后 panic 被转换为 PanicError，计为失败，并交给回退函数处理。
+// WithPanicRecovery sets whether to recover panics in the execution. If enabled, a panic is converted to a PanicError, counted as a failure and handed to the fallback function.
+func (c *Config) WithPanicRecovery(enable bool) *Config {
+	c.recoverPanics = enable
+	return c
+}
+
+// windowSpec 返回滚动窗口的时长和插槽间隔。没有设置时长时使用 state window 的秒数，没有设置插槽间隔时使用默认值。
+// windowSpec returns the duration and the slot interval of the rolling window. The seconds of the state window are used if no duration is set, the default slot interval is used if no slot interval is set.
+func (c *Config) windowSpec() (time.Duration, time.Duration) {
+	window, interval := c.window, c.slotInterval
+	if window == 0 {
+		window = time.Duration(c.stateWindow) * time.Second
+	}
+	if interval == 0 {
+		interval = rw.DefaultRollingWindowSlotInterval
+	}
+	return window, interval
+}
+
+// Validate 检查滚动窗口的时长和插槽间隔，无效时返回错误。
+// NewGoogleBreaker 和 NewThreeStateBreaker 不会修改配置中无效的窗口，只是改用默认的 state window，所以需要先调用 Validate，或者使用 NewGoogleBreakerE 和 NewThreeStateBreakerE。
+// Validate checks the duration and the slot interval of the rolling window, returns an error if they are invalid.
+// NewGoogleBreaker and NewThreeStateBreaker leave an invalid window in the configuration untouched and use the default state window instead, so call Validate first, or use NewGoogleBreakerE and NewThreeStateBreakerE.
+func (c *Config) Validate() error {
+	if c.window == 0 && c.slotInterval == 0 {
+		return nil
+	}
+	window, interval := c.windowSpec()
+	return rw.ValidateWindow(window, interval)
+}
+
+// newRollingWindow 根据配置创建滚动窗口，窗口无效时使用默认的 state window，配置本身不会被修改。
+// newRollingWindow creates the rolling window from the configuration, the default state window is used if the window is invalid, the configuration itself is not modified.
+func newRollingWindow(conf *Config) rw.Window {
+	useAtomic := conf.windowMode == WindowModeAtomic
+
+	// 没有设置时长和插槽间隔，使用 state window。
+	// No duration and slot interval is set, use the state window.
+	if conf.window == 0 && conf.slotInterval == 0 {
+		if useAtomic {
+			return rw.NewAtomicWindow(conf.stateWindow, conf.clock)
+		}
+		return rw.NewRollingWindowWithClock(conf.stateWindow, conf.clock)
+	}
+
+	window, interval := conf.windowSpec()
+	if useAtomic {
+		if rwin, err := rw.NewAtomicWindowWithInterval(window, interval, conf.clock); err == nil {
+			return rwin
+		}
+		return rw.NewAtomicWindow(DefaultStateWindow, conf.clock)
+	}
+	if rwin, err := rw.NewRollingWindowWithInterval(window, interval, conf.clock); err == nil {
+		return rwin
+	}
+	return rw.NewRollingWindowWithClock(DefaultStateWindow, conf.clock)
+}
+
+// newHistogramWindow 根据配置创建直方图滚动窗口，与 newRollingWindow 使用相同的时长和插槽间隔。
+// newHistogramWindow creates the histogram rolling window from the configuration, with the same duration and slot interval as newRollingWindow.
+func newHistogramWindow(conf *Config) *rw.HistogramWindow {
+	window, interval := conf.windowSpec()
+	hwin, err := rw.NewHistogramWindow(window, interval, conf.clock)
+	if err != nil {
+		hwin, _ = rw.NewHistogramWindow(DefaultStateWindow*time.Second, rw.DefaultRollingWindowSlotInterval, conf.clock)
+	}
+	return hwin
+}
+
 // isConfigValid 检查配置是否有效。
 // isConfigValid checks if the configuration is valid.
 func isConfigValid(conf *Config) *Config {
@@ -86,6 +327,36 @@ func isConfigValid(conf *Config) *Config {
 		if conf.stateWindow <= 0 {
 			conf.stateWindow = DefaultStateWindow
 		}
+		if conf.failureThreshold <= 0 || conf.failureThreshold > 1 {
+			conf.failureThreshold = DefaultFailureThreshold
+		}
+		if conf.minRequests <= 0 {
+			conf.minRequests = DefaultMinRequests
+		}
+		if conf.openTimeout <= 0 {
+			conf.openTimeout = DefaultOpenTimeout
+		}
+		if conf.halfOpenProbes <= 0 {
+			conf.halfOpenProbes = DefaultHalfOpenProbes
+		}
+		if conf.stateDebounce < 0 {
+			conf.stateDebounce = DefaultStateDebounce
+		}
+		if conf.rejectingRatio <= 0 || conf.rejectingRatio > 1 {
+			conf.rejectingRatio = DefaultRejectingRatio
+		}
+		if conf.clock == nil {
+			conf.clock = utils.SystemClock{}
+		}
+		if conf.windowMode != WindowModeMutex && conf.windowMode != WindowModeAtomic {
+			conf.windowMode = WindowModeMutex
+		}
+		if conf.slowThreshold < 0 {
+			conf.slowThreshold = 0
+		}
+		if conf.slowWeight <= 0 || conf.slowWeight > 1 {
+			conf.slowWeight = DefaultSlowCallWeight
+		}
 	} else {
 		conf = DefaultConfig()
 	}
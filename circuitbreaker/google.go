@@ -1,8 +1,12 @@
 package circuitbreaker
 
 import (
+	"context"
+	"errors"
 	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	com "github.com/shengyanli1982/tripwire/common"
 	rw "github.com/shengyanli1982/tripwire/internal/rolling"
@@ -13,6 +17,10 @@ const (
 	// 定义默认的浮点数精度为 3
 	// Define the default floating-point precision as 3
 	DefaultFloatingPrecision = 3
+
+	// untimed 表示执行没有计时，不参与慢调用的统计。
+	// untimed means the execution was not timed, it does not take part in the slow call statistics.
+	untimed = time.Duration(-1)
 )
 
 // DefaultAcceptableFunc 是默认的可接受函数。
@@ -23,33 +31,297 @@ func DefaultAcceptableFunc(err error) bool { return err == nil } // 如果错误
 // DefaultFallbackFunc is the default fallback function.
 func DefaultFallbackFunc(err error) error { return err } // 直接返回错误
 
+// isContextCanceled 检查执行是否因调用方取消上下文而结束，这种情况不计入失败。
+// isContextCanceled checks if the execution ended because the caller canceled the context, which is not counted as a failure.
+func isContextCanceled(ctx context.Context, err error) bool {
+	return errors.Is(err, context.Canceled) || (err != nil && errors.Is(ctx.Err(), context.Canceled))
+}
+
+// call 执行函数，启用 panic 恢复时把 panic 转换为 PanicError 返回。
+// call executes the function, and returns the panic as a PanicError if panic recovery is enabled.
+func call(recoverPanics bool, fn func() error) (err error) {
+	if recoverPanics {
+		defer func() {
+			if v := recover(); v != nil {
+				err = com.NewPanicError(v)
+			}
+		}()
+	}
+	return fn()
+}
+
+// markPanic 在没有恢复的 panic 经过时标记执行失败，归还占用的名额，然后继续 panic。
+// markPanic marks the execution as failed when an unrecovered panic passes by, so the slot it holds is returned, and then panics again.
+func markPanic(notifier com.Notifier) {
+	if v := recover(); v != nil {
+		notifier.MarkFailure(com.NewPanicError(v))
+		panic(v)
+	}
+}
+
+// isPanic 检查错误是否是恢复的 panic。
+// isPanic checks if the error is a recovered panic.
+func isPanic(err error) bool {
+	_, ok := err.(*com.PanicError)
+	return ok
+}
+
 // GoogleBreaker 是一个当错误率高时打开的熔断器。
 // GoogleBreaker is a circuit breaker that opens when the error rate is high.
 type GoogleBreaker struct {
-	config *Config           // 熔断器的配置 Config of the breaker
-	rwin   *rw.RollingWindow // 滚动窗口 Rolling window
-	once   sync.Once         // 用于确保某个操作只执行一次 The sync.Once to ensure that an operation is executed only once
-	sr     *SafeRandom       // 安全的随机数生成器 Safe random number generator
+	config  atomic.Pointer[Config]             // 熔断器的配置 Config of the breaker
+	rwin    windowSlot                         // 滚动窗口 Rolling window
+	slow    windowSlot                         // 慢调用的滚动窗口，没有设置慢调用阈值时为空 Rolling window of slow calls, empty if no slow call threshold is set
+	hwin    atomic.Pointer[rw.HistogramWindow] // 执行时间的直方图窗口，没有启用时为 nil Histogram window of execution times, nil if not enabled
+	latSum  atomic.Int64                       // 直方图记录的执行时间的累计总和 Cumulative sum of the execution times recorded by the histogram
+	latNum  atomic.Uint64                      // 直方图记录的执行的累计数量 Cumulative number of the executions recorded by the histogram
+	lock    sync.Mutex                         // 保护配置的更新、重置和停止 Guards updating, resetting and stopping
+	stopped bool                               // 是否已经停止 Whether the breaker is stopped
+	sr      RandomSource                       // 随机数来源 Random source
+	states  *stateTracker                      // 状态跟踪器 State tracker
+	forced  override                           // 手动覆盖 Manual override
+	events  eventHub                           // 状态变化事件的订阅者 Subscribers of state change events
 }
 
 // NewGoogleBreaker 返回一个新的熔断器。
 // NewGoogleBreaker returns a new breaker.
 func NewGoogleBreaker(conf *Config) *GoogleBreaker {
 	conf = isConfigValid(conf)
-	return &GoogleBreaker{
-		config: conf,
-		sr:     NewSafeRandom(),
-		rwin:   rw.NewRollingWindow(conf.stateWindow),
-		once:   sync.Once{},
+
+	// 没有指定随机数来源时，使用自己的 SafeRandom，原子模式下使用无锁的 PooledRandom。
+	// Use its own SafeRandom if no random source is specified, or the lock-free PooledRandom in the atomic mode.
+	sr := conf.random
+	if sr == nil {
+		if conf.windowMode == WindowModeAtomic {
+			sr = NewPooledRandom()
+		} else {
+			sr = NewSafeRandom()
+		}
+	}
+
+	b := &GoogleBreaker{
+		sr:     sr,
+		states: newStateTracker(conf.stateDebounce, conf.rejectingRatio),
+	}
+	b.config.Store(conf)
+	b.rwin.store(newRollingWindow(conf))
+
+	// 设置了慢调用阈值时，使用独立的滚动窗口记录慢调用。
+	// Record slow calls in a separate rolling window if the slow call threshold is set.
+	if conf.slowThreshold > 0 {
+		b.slow.store(newRollingWindow(conf))
+	}
+
+	// 启用了延迟直方图时，使用直方图窗口记录执行时间。
+	// Record execution times in a histogram window if the latency histogram is enabled.
+	if conf.latency {
+		b.hwin.Store(newHistogramWindow(conf))
+	}
+
+	return b
+}
+
+// NewGoogleBreakerE 返回一个新的熔断器，滚动窗口无效时返回 Validate 的错误。
+// NewGoogleBreakerE returns a new breaker, returns the error of Validate if the rolling window is invalid.
+func NewGoogleBreakerE(conf *Config) (*GoogleBreaker, error) {
+	if conf != nil {
+		if err := conf.Validate(); err != nil {
+			return nil, err
+		}
 	}
+	return NewGoogleBreaker(conf), nil
+}
+
+// conf 返回熔断器当前的配置。
+// conf returns the current configuration of the breaker.
+func (b *GoogleBreaker) conf() *Config {
+	return b.config.Load()
 }
 
 // Stop 停止熔断器。
 // Stop stops the breaker.
 func (b *GoogleBreaker) Stop() {
-	b.once.Do(func() {
-		b.rwin.Stop() // 停止滚动窗口
-	})
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.stopped {
+		return
+	}
+	b.stopped = true
+	b.rwin.Stop() // 停止滚动窗口
+	b.slow.Stop()
+	if hwin := b.hwin.Load(); hwin != nil {
+		hwin.Stop()
+	}
+}
+
+// Reset 清空滚动窗口中的统计数据，熔断器回到健康状态，手动覆盖不受影响。
+// Reset clears the statistics of the rolling windows and the breaker goes back to healthy, the manual override is not affected.
+func (b *GoogleBreaker) Reset() {
+	b.lock.Lock()
+	b.rwin.Reset()
+	b.slow.Reset()
+	if hwin := b.hwin.Load(); hwin != nil {
+		hwin.Reset()
+	}
+	from := b.states.reset()
+	b.lock.Unlock()
+
+	// 没有手动覆盖时，通知状态的变化。
+	// Notify the state change if there is no manual override.
+	if _, ok := b.overridden(b.conf().clock); !ok {
+		b.notify(from, StateHealthy)
+	}
+}
+
+// Update 原子地把新的配置应用到运行中的熔断器，包括 K 值、保护值、回调函数、慢调用、状态和窗口设置。
+// 滚动窗口的时长、插槽间隔和模式不变时，窗口保留原来的统计数据，否则使用新的空窗口。
+// 时钟和随机数来源在创建时确定，不会被更新。配置无效或熔断器已经停止时返回错误。
+// Update atomically applies the new configuration to the running breaker, including the K value, the protected value, the callback, and the slow call, state and window settings.
+// The rolling windows keep their statistics if the duration, the slot interval and the mode of the window are unchanged, otherwise new empty windows are used.
+// The clock and the random source are fixed at creation and are not updated. Returns an error if the configuration is invalid or the breaker is stopped.
+func (b *GoogleBreaker) Update(conf *Config) error {
+	// 复制配置，调用方之后的修改不会影响熔断器。
+	// Copy the configuration, so later changes of the caller do not affect the breaker.
+	next := DefaultConfig()
+	if conf != nil {
+		copied := *conf
+		next = &copied
+	}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	next = isConfigValid(next)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.stopped {
+		return com.ErrorRollingWindowStopped
+	}
+
+	prev := b.conf()
+	next.clock = prev.clock
+	next.random = prev.random
+
+	// 窗口的设置变化时，重建所有窗口。
+	// Rebuild all windows if the window settings change.
+	rebuild := !sameWindow(prev, next)
+	if rebuild {
+		b.rwin.store(newRollingWindow(next))
+	}
+
+	// 慢调用窗口只在启用状态或窗口设置变化时重建。
+	// The slow call window is rebuilt only if it is enabled or disabled, or the window settings change.
+	switch {
+	case next.slowThreshold <= 0:
+		b.slow.store(nil)
+	case rebuild || b.slow.load() == nil:
+		b.slow.store(newRollingWindow(next))
+	}
+
+	// 直方图窗口同理。
+	// The same applies to the histogram window.
+	switch {
+	case !next.latency:
+		b.hwin.Store(nil)
+	case rebuild || b.hwin.Load() == nil:
+		b.hwin.Store(newHistogramWindow(next))
+	}
+
+	b.states.configure(next.stateDebounce, next.rejectingRatio)
+	b.config.Store(next)
+	return nil
+}
+
+// sameWindow 检查两个配置的滚动窗口设置是否相同。
+// sameWindow checks if the rolling window settings of the two configurations are the same.
+func sameWindow(a, b *Config) bool {
+	aw, ai := a.windowSpec()
+	bw, bi := b.windowSpec()
+	return aw == bw && ai == bi && a.windowMode == b.windowMode
+}
+
+// State 返回熔断器的当前状态，有手动覆盖时返回覆盖的状态，否则由熔断比率推导得出。
+// State returns the current state of the breaker, the override state if there is a manual override, otherwise derived from the fuse ratio.
+func (b *GoogleBreaker) State() State {
+	if state, ok := b.overridden(b.conf().clock); ok {
+		return state
+	}
+	return b.states.current()
+}
+
+// ForceOpen 强制打开熔断器，拒绝所有执行并返回 ErrorForcedOpen，expiry 为 0 时一直有效，直到被清除。
+// 强制打开期间被拒绝的执行不计入滚动窗口，清除后熔断器从原来的统计继续判断。
+// ForceOpen forces the breaker open, all executions are rejected with ErrorForcedOpen, it stays until cleared if expiry is 0.
+// The executions rejected while forced open are not recorded in the rolling window, the breaker continues from the previous statistics after clearing.
+func (b *GoogleBreaker) ForceOpen(expiry time.Duration) {
+	b.force(StateForcedOpen, expiry)
+}
+
+// ForceClose 强制关闭熔断器，允许所有执行，expiry 为 0 时一直有效，直到被清除。执行结果仍然被记录。
+// ForceClose forces the breaker closed, all executions are allowed, it stays until cleared if expiry is 0. The results are still recorded.
+func (b *GoogleBreaker) ForceClose(expiry time.Duration) {
+	b.force(StateForcedClosed, expiry)
+}
+
+// ClearOverride 清除手动覆盖，熔断器恢复按熔断比率判断。
+// ClearOverride clears the manual override, the breaker decides by the fuse ratio again.
+func (b *GoogleBreaker) ClearOverride() {
+	if state, ok := b.forced.clear(); ok {
+		b.notify(state, b.states.current())
+	}
+}
+
+// force 设置手动覆盖，并通知状态变化。
+// force sets the manual override and notifies the state change.
+func (b *GoogleBreaker) force(state State, expiry time.Duration) {
+	from := b.State()
+	b.forced.set(b.conf().clock.Now(), state, expiry)
+	b.notify(from, state)
+}
+
+// overridden 返回手动覆盖的状态，覆盖过期时通知状态变化。
+// overridden returns the state of the manual override, and notifies the state change when the override expires.
+func (b *GoogleBreaker) overridden(clock com.Clock) (State, bool) {
+	state, active, expired := b.forced.get(clock)
+	if expired {
+		b.notify(state, b.states.current())
+	}
+	return state, active
+}
+
+// notify 通知回调函数和订阅者状态发生了变化。
+// notify notifies the callback and the subscribers of the state change.
+func (b *GoogleBreaker) notify(from, to State) {
+	if from == to {
+		return
+	}
+	notifyStateChange(b.conf().callback, from, to)
+	b.events.publish(StateChange{From: from, To: to, Time: b.conf().clock.Now()})
+}
+
+// Subscribe 订阅状态变化事件，返回取消订阅的函数。监听函数在熔断器的调用路径上同步执行，应该尽快返回。
+// Subscribe subscribes to state change events and returns the function to unsubscribe. The listener runs synchronously on the call path of the breaker and should return quickly.
+func (b *GoogleBreaker) Subscribe(listener StateListener) func() {
+	return b.events.subscribe(listener)
+}
+
+// SubscribeChan 通过指定缓冲大小的通道订阅状态变化事件，通道已满时丢弃事件。
+// 返回通道和取消订阅的函数，取消订阅时通道被关闭。
+// SubscribeChan subscribes to state change events through a channel with the given buffer size, events are dropped when the channel is full.
+// Returns the channel and the function to unsubscribe, the channel is closed on unsubscribe.
+func (b *GoogleBreaker) SubscribeChan(size int) (<-chan StateChange, func()) {
+	return b.events.subscribeChan(size)
+}
+
+// observe 根据熔断比率更新状态，状态变化时通知回调函数和订阅者。
+// observe updates the state from the fuse ratio, and notifies the callback and the subscribers when the state changes.
+func (b *GoogleBreaker) observe(fuseRatio, failureRatio float64) {
+	if event, ok := b.states.observe(b.conf().clock.Now(), fuseRatio, failureRatio); ok {
+		notifyStateChange(b.conf().callback, event.From, event.To)
+		b.events.publish(event)
+	}
 }
 
 // history 返回熔断器的历史。接受和总计的和，以及任何错误
@@ -61,6 +333,10 @@ func (b *GoogleBreaker) history() (float64, uint64, error) {
 // Accept 接受一个执行。 ratio 是一个随机浮点数，用于模拟接受执行的概率。
 // Accept accepts a execution. ratio is a random float64 used to simulate the probability of accepting the execution.
 func (b *GoogleBreaker) accept(ratio float64) error {
+	// 读取一次配置，避免同时更新时使用不一致的设置。
+	// Read the configuration once, so a concurrent update cannot mix the settings.
+	conf := b.conf()
+
 	// 获取熔断器的历史状态。
 	// Get the history state of the breaker.
 	accepted, total, err := b.history()
@@ -74,39 +350,175 @@ func (b *GoogleBreaker) accept(ratio float64) error {
 
 	// 计算加权接受。
 	// Calculate the weighted accepts.
-	weightedAcceptes := b.config.k * accepted
+	weightedAcceptes := conf.k * accepted
 
 	// 计算熔丝比率。
 	// Calculate the fuse ratio.
-	fuseRatio := utils.Round(math.Max(0, (float64(int64(total)-int64(b.config.protected))-weightedAcceptes)/float64(total+1)), DefaultFloatingPrecision)
+	fuseRatio := utils.Round(math.Max(0, (float64(int64(total)-int64(conf.protected))-weightedAcceptes)/float64(total+1)), DefaultFloatingPrecision)
+
+	// 有手动覆盖时，按覆盖的状态接受或拒绝执行。
+	// With a manual override, accept or reject the execution by the override state.
+	if state, ok := b.overridden(conf.clock); ok {
+		if state == StateForcedOpen {
+			conf.callback.OnAccept(com.ErrorForcedOpen, fuseRatio, failureRatio)
+			return com.ErrorForcedOpen
+		}
+		conf.callback.OnAccept(nil, fuseRatio, failureRatio)
+		return nil
+	}
+
+	// 根据熔断比率更新状态。
+	// Update the state from the fuse ratio.
+	b.observe(fuseRatio, failureRatio)
 
 	// 如果熔丝比率小于或等于0，或者熔丝比率大于等于0和1之间的随机浮点数，返回nil。
 	// If the fuse ratio is less than or equal to 0, or if the fuse ratio is greater than or equal a random float64 between 0 and 1, return nil.
 	if fuseRatio <= 0 || ratio >= fuseRatio {
-		b.config.callback.OnAccept(nil, fuseRatio, failureRatio)
+		conf.callback.OnAccept(nil, fuseRatio, failureRatio)
 		return nil
 	}
 
 	// 如果熔丝比率大于随机浮点数，返回服务不可用的错误。
 	// If the fuse ratio is greater than the random float64, return the error of service unavailable.
-	b.config.callback.OnAccept(com.ErrorServiceUnavailable, fuseRatio, failureRatio)
+	conf.callback.OnAccept(com.ErrorServiceUnavailable, fuseRatio, failureRatio)
 	return com.ErrorServiceUnavailable
 }
 
 // MarkFailure 标记一个失败的执行，并调用失败回调
 // MarkFailure marks a failed execution and calls the failure callback
 func (b *GoogleBreaker) MarkFailure(reason error) {
-	b.config.callback.OnFailure(b.rwin.Add(0), reason) // 添加一个失败的执行，并调用失败回调
-	// Add a failed execution and call the failure callback
+	b.markFailure(reason, untimed)
 }
 
 // MarkSuccess 标记一个成功的执行，并调用成功回调
 // MarkSuccess marks a successful execution and calls the success callback
 func (b *GoogleBreaker) MarkSuccess() {
-	b.config.callback.OnSuccess(b.rwin.Add(1)) // 添加一个成功的执行，并调用成功回调
+	b.markSuccess(untimed)
+}
+
+// timed 返回是否需要对执行计时。
+// timed returns whether the executions need to be timed.
+func (b *GoogleBreaker) timed() bool {
+	return b.slow.load() != nil || b.hwin.Load() != nil
+}
+
+// timing 返回执行的开始时间，不需要计时时返回零值。
+// timing returns the start time of the execution, returns the zero value if the executions are not timed.
+func (b *GoogleBreaker) timing() time.Time {
+	if !b.timed() {
+		return time.Time{}
+	}
+	return b.conf().clock.Now()
+}
+
+// elapsed 返回自 start 以来经过的时间，start 为零值时返回 untimed。
+// elapsed returns the time elapsed since start, returns untimed if start is zero.
+func (b *GoogleBreaker) elapsed(start time.Time) time.Duration {
+	if start.IsZero() {
+		return untimed
+	}
+	return b.conf().clock.Since(start)
+}
+
+// isSlow 记录执行时间和执行是否为慢调用，并返回是否为慢调用。
+// isSlow records the execution time and whether the execution was a slow call, and returns whether it was a slow call.
+func (b *GoogleBreaker) isSlow(elapsed time.Duration) bool {
+	if elapsed < 0 {
+		return false
+	}
+	if hwin := b.hwin.Load(); hwin != nil {
+		_ = hwin.Add(elapsed.Seconds())
+		b.latSum.Add(int64(elapsed))
+		b.latNum.Add(1)
+	}
+	slow := b.slow.load()
+	if slow == nil {
+		return false
+	}
+	if elapsed >= b.conf().slowThreshold {
+		_ = slow.Add(1)
+		return true
+	}
+	_ = slow.Add(0)
+	return false
+}
+
+// markFailure 标记一个耗时 elapsed 的失败执行。
+// markFailure marks a failed execution which took elapsed.
+func (b *GoogleBreaker) markFailure(reason error, elapsed time.Duration) {
+	b.isSlow(elapsed)
+	b.conf().callback.OnFailure(b.rwin.Add(0), reason) // 添加一个失败的执行，并调用失败回调
+	// Add a failed execution and call the failure callback
+}
+
+// markSuccess 标记一个耗时 elapsed 的成功执行，慢调用按权重计为失败。
+// markSuccess marks a successful execution which took elapsed, a slow call counts as a failure by weight.
+func (b *GoogleBreaker) markSuccess(elapsed time.Duration) {
+	value := 1.0
+	if b.isSlow(elapsed) {
+		value -= b.conf().slowWeight
+	}
+	b.conf().callback.OnSuccess(b.rwin.Add(value)) // 添加一个成功的执行，并调用成功回调
 	// Add a successful execution and call the success callback
 }
 
+// SlowCallRatio 返回滚动窗口中慢调用的比率，没有设置慢调用阈值时返回 0。
+// SlowCallRatio returns the ratio of slow calls in the rolling window, returns 0 if no slow call threshold is set.
+func (b *GoogleBreaker) SlowCallRatio() (float64, error) {
+	window := b.slow.load()
+	if window == nil {
+		return 0, nil
+	}
+	slow, total, err := window.Sum()
+	if err != nil || total == 0 {
+		return 0, err
+	}
+	return utils.Round(slow/float64(total), DefaultFloatingPrecision), nil
+}
+
+// LatencyQuantile 返回滚动窗口中执行时间的分位数 q 的近似值，q 的取值范围为 [0, 1]。
+// 没有启用延迟直方图或窗口为空时返回 0。
+// LatencyQuantile returns the approximate quantile q of the execution times in the rolling window, q is in [0, 1].
+// Returns 0 if the latency histogram is not enabled or the window is empty.
+func (b *GoogleBreaker) LatencyQuantile(q float64) (time.Duration, error) {
+	hwin := b.hwin.Load()
+	if hwin == nil {
+		return 0, nil
+	}
+	seconds, err := hwin.Quantile(q)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// LatencyTotal 返回延迟直方图记录过的执行时间的累计总和与执行的累计数量，它们不随滚动窗口过期，也不会被 Reset 清空。
+// 没有启用延迟直方图时返回 0。
+// LatencyTotal returns the cumulative sum of the execution times and the cumulative number of the executions recorded by the latency histogram, they neither expire with the rolling window nor are cleared by Reset.
+// Returns 0 if the latency histogram is not enabled.
+func (b *GoogleBreaker) LatencyTotal() (time.Duration, uint64) {
+	return time.Duration(b.latSum.Load()), b.latNum.Load()
+}
+
+// googleNotifier 是记录执行开始时间的结果通知器，用于检测慢调用。
+// googleNotifier is a result notifier which records the start time of the execution, used to detect slow calls.
+type googleNotifier struct {
+	breaker *GoogleBreaker
+	start   time.Time
+}
+
+// MarkSuccess 标记执行成功。
+// MarkSuccess marks the execution as successful.
+func (n *googleNotifier) MarkSuccess() {
+	n.breaker.markSuccess(n.breaker.elapsed(n.start))
+}
+
+// MarkFailure 标记执行失败。
+// MarkFailure marks the execution as failed.
+func (n *googleNotifier) MarkFailure(reason error) {
+	n.breaker.markFailure(reason, n.breaker.elapsed(n.start))
+}
+
 // Allow 检查熔断器是否允许执行。
 // Allow checks if the circuit breaker allows the execution.
 func (b *GoogleBreaker) Allow() (com.Notifier, error) {
@@ -116,9 +528,12 @@ func (b *GoogleBreaker) Allow() (com.Notifier, error) {
 		return nil, err
 	}
 
-	// 返回结果通知器。
-	// Return the result notifier.
-	return b, nil
+	// 不需要计时时，熔断器自己就是结果通知器，否则返回记录开始时间的通知器。
+	// The breaker itself is the result notifier if the executions are not timed, otherwise return a notifier recording the start time.
+	if !b.timed() {
+		return b, nil
+	}
+	return &googleNotifier{breaker: b, start: b.timing()}, nil
 }
 
 // do 使用熔断器保护执行给定的函数。
@@ -129,9 +544,11 @@ func (b *GoogleBreaker) do(fn com.HandleFunc, fallback com.FallbackFunc, accepta
 	// 如果 accept 返回错误，拒绝执行并返回错误。
 	// If accept returns an error, reject the execution and return the error.
 	if err = b.accept(b.sr.Float64()); err != nil {
-		// 标记执行失败
-		// Mark the execution as failed
-		b.MarkFailure(err)
+		// 标记执行失败，强制打开时不记录
+		// Mark the execution as failed, not recorded while forced open
+		if err != com.ErrorForcedOpen {
+			b.MarkFailure(err)
+		}
 
 		// 如果提供了回退函数，执行回退函数。
 		// If a fallback function is provided, execute the fallback function.
@@ -144,16 +561,28 @@ func (b *GoogleBreaker) do(fn com.HandleFunc, fallback com.FallbackFunc, accepta
 		return err
 	}
 
-	// 执行函数
-	// Execute the function
-	err = fn()
+	// 执行函数，并记录执行时间
+	// Execute the function and record the execution time
+	start := b.timing()
+	err = call(b.conf().recoverPanics, fn)
+	elapsed := b.elapsed(start)
+
+	// 函数发生了 panic，标记执行失败，并交给回退函数处理。
+	// The function panicked, mark the execution as failed and hand it to the fallback function.
+	if isPanic(err) {
+		b.markFailure(err, elapsed)
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
 
 	// 如果错误可接受，标记执行成功，否则标记执行失败并返回错误。
 	// If the error is acceptable, mark the execution as successful, otherwise mark the execution as failed and return the error.
 	if acceptable(err) {
 		// 标记执行成功
 		// Mark the execution as successful
-		b.MarkSuccess()
+		b.markSuccess(elapsed)
 
 		// 正常返回
 		// Return nil
@@ -161,7 +590,7 @@ func (b *GoogleBreaker) do(fn com.HandleFunc, fallback com.FallbackFunc, accepta
 	} else {
 		// 标记执行失败
 		// Mark the execution as failed
-		b.MarkFailure(err)
+		b.markFailure(err, elapsed)
 
 		// 返回错误。
 		// Return the error.
@@ -192,3 +621,88 @@ func (b *GoogleBreaker) DoWithFallback(fn com.HandleFunc, fallback com.FallbackF
 func (b *GoogleBreaker) DoWithFallbackAcceptable(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
 	return b.do(fn, fallback, acceptable)
 }
+
+// doCtx 使用熔断器保护执行给定的带上下文的函数。
+// doCtx executes the given function with the context under circuit breaker protection.
+func (b *GoogleBreaker) doCtx(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 如果上下文已经结束，快速失败，不计入统计。
+	// If the context is already done, fail fast without recording the statistics.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 如果 accept 返回错误，拒绝执行并返回错误。
+	// If accept returns an error, reject the execution and return the error.
+	if err := b.accept(b.sr.Float64()); err != nil {
+		// 标记执行失败，强制打开时不记录
+		// Mark the execution as failed, not recorded while forced open
+		if err != com.ErrorForcedOpen {
+			b.MarkFailure(err)
+		}
+
+		// 如果提供了回退函数，执行回退函数。
+		// If a fallback function is provided, execute the fallback function.
+		if fallback != nil {
+			return fallback(err)
+		}
+
+		// 返回错误。
+		// Return the error.
+		return err
+	}
+
+	// 执行函数，并记录执行时间
+	// Execute the function and record the execution time
+	start := b.timing()
+	err := call(b.conf().recoverPanics, func() error { return fn(ctx) })
+	elapsed := b.elapsed(start)
+
+	// 函数发生了 panic，标记执行失败，并交给回退函数处理。
+	// The function panicked, mark the execution as failed and hand it to the fallback function.
+	if isPanic(err) {
+		b.markFailure(err, elapsed)
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+
+	// 调用方取消了上下文，不计入成功或失败。
+	// The caller canceled the context, it is counted as neither success nor failure.
+	if isContextCanceled(ctx, err) {
+		return err
+	}
+
+	// 如果错误可接受，标记执行成功，否则标记执行失败并返回错误。
+	// If the error is acceptable, mark the execution as successful, otherwise mark the execution as failed and return the error.
+	if acceptable(err) {
+		b.markSuccess(elapsed)
+		return nil
+	}
+	b.markFailure(err, elapsed)
+	return err
+}
+
+// DoCtx 使用上下文执行函数并返回错误。
+// DoCtx executes the function with the context and returns the error.
+func (b *GoogleBreaker) DoCtx(ctx context.Context, fn com.HandleCtxFunc) error {
+	return b.doCtx(ctx, fn, nil, DefaultAcceptableFunc)
+}
+
+// DoCtxWithAcceptable 使用上下文和给定的可接受函数执行函数并返回错误。
+// DoCtxWithAcceptable executes the function with the context and the given acceptable function and returns the error.
+func (b *GoogleBreaker) DoCtxWithAcceptable(ctx context.Context, fn com.HandleCtxFunc, acceptable com.AcceptableFunc) error {
+	return b.doCtx(ctx, fn, nil, acceptable)
+}
+
+// DoCtxWithFallback 使用上下文和给定的回退函数执行函数并返回错误。
+// DoCtxWithFallback executes the function with the context and the given fallback function and returns the error.
+func (b *GoogleBreaker) DoCtxWithFallback(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc) error {
+	return b.doCtx(ctx, fn, fallback, DefaultAcceptableFunc)
+}
+
+// DoCtxWithFallbackAcceptable 使用上下文和给定的回退和可接受函数执行函数并返回错误。
+// DoCtxWithFallbackAcceptable executes the function with the context and the given fallback and acceptable functions and returns the error.
+func (b *GoogleBreaker) DoCtxWithFallbackAcceptable(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return b.doCtx(ctx, fn, fallback, acceptable)
+}
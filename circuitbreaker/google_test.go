@@ -1,10 +1,14 @@
 package circuitbreaker
 
 import (
+	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/tripwiretest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -314,7 +318,7 @@ func TestGoogleBreaker_Callback(t *testing.T) {
 	assert.NotNil(t, notifier, "Expected a notifier, but got nil")
 
 	// callback
-	cb := breaker.config.callback.(*testCallback)
+	cb := breaker.conf().callback.(*testCallback)
 
 	// Test case 1: OnSuccess
 	notifier.MarkSuccess()
@@ -329,3 +333,622 @@ func TestGoogleBreaker_Callback(t *testing.T) {
 	assert.NoError(t, err, "Unexpected error")
 	assert.Equal(t, float64(0), cb.fuse, "Unexpected reference factor")
 }
+
+func TestGoogleBreaker_DoCtx(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	breaker := NewGoogleBreaker(nil)
+	defer breaker.Stop()
+
+	// Test case 1: Successful execution
+	err := breaker.DoCtx(context.Background(), func(ctx context.Context) error {
+		return nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+
+	// Test case 2: Failed execution
+	err = breaker.DoCtx(context.Background(), func(ctx context.Context) error {
+		return execError
+	})
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+
+	_, total, err := breaker.history()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, uint64(2), total, "Unexpected total count")
+
+	// Test case 3: Context is already done, fail fast
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	executed := false
+	err = breaker.DoCtx(ctx, func(ctx context.Context) error {
+		executed = true
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled, "Unexpected error")
+	assert.False(t, executed, "Function should not be executed")
+
+	// Test case 4: Context is canceled during execution, not counted
+	ctx, cancel = context.WithCancel(context.Background())
+	err = breaker.DoCtx(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled, "Unexpected error")
+
+	_, total, err = breaker.history()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, uint64(2), total, "Unexpected total count")
+
+	// Test case 5: Deadline exceeded during execution, counted as failure
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err = breaker.DoCtx(ctx, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Unexpected error")
+
+	accepted, total, err := breaker.history()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, uint64(3), total, "Unexpected total count")
+	assert.Equal(t, float64(1), accepted, "Unexpected accepted count")
+}
+
+func TestGoogleBreaker_DoCtxWithFallbackAcceptable(t *testing.T) {
+	var (
+		execError = errors.New("execution error")
+		fbError   = errors.New("fallback error")
+	)
+
+	breaker := NewGoogleBreaker(nil)
+	defer breaker.Stop()
+
+	fallback := func(err error) error {
+		return fbError
+	}
+	acceptable := func(err error) bool {
+		return err == nil || err == execError
+	}
+
+	// Failed execution with acceptable result
+	err := breaker.DoCtxWithFallbackAcceptable(context.Background(), func(ctx context.Context) error {
+		return execError
+	}, fallback, acceptable)
+	assert.NoError(t, err, "Unexpected error")
+
+	// Simulate running 100 times, failed
+	for i := 0; i < 100; i++ {
+		err := breaker.rwin.Add(0)
+		assert.Nil(t, err)
+	}
+
+	// Reject with fallback, the fuse ratio is close to 1
+	rejected := 0
+	for i := 0; i < 10; i++ {
+		err = breaker.DoCtxWithFallback(context.Background(), func(ctx context.Context) error {
+			return nil
+		}, fallback)
+		if errors.Is(err, fbError) {
+			rejected++
+		}
+	}
+	assert.Greater(t, rejected, 0, "Expected at least one rejection")
+}
+
+func TestGoogleBreaker_StateChange(t *testing.T) {
+	// create a new GoogleBreaker without debounce
+	callback := &stateCallback{}
+	breaker := NewGoogleBreaker(NewConfig().WithCallback(callback).WithStateDebounce(0))
+	defer breaker.Stop()
+
+	events, unsubscribe := breaker.SubscribeChan(8)
+	var received []StateChange
+	cancel := breaker.Subscribe(func(event StateChange) { received = append(received, event) })
+	defer cancel()
+
+	assert.Equal(t, StateHealthy, breaker.State(), "Unexpected state")
+
+	// Simulate running 100 times, failed, fuse ratio is 0.941
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, breaker.rwin.Add(0))
+	}
+	_ = breaker.accept(1)
+	assert.Equal(t, StateRejecting, breaker.State(), "Unexpected state")
+
+	// Simulate running 200 times, success, fuse ratio is 0
+	for i := 0; i < 200; i++ {
+		assert.Nil(t, breaker.rwin.Add(1))
+	}
+	_ = breaker.accept(1)
+	assert.Equal(t, StateRecovered, breaker.State(), "Unexpected state")
+	_ = breaker.accept(1)
+	assert.Equal(t, StateHealthy, breaker.State(), "Unexpected state")
+
+	// Check the events delivered to the channel
+	unsubscribe()
+	var states []State
+	for event := range events {
+		states = append(states, event.To)
+		assert.False(t, event.Time.IsZero(), "Unexpected event time")
+	}
+	assert.Equal(t, []State{StateRejecting, StateRecovered, StateHealthy}, states, "Unexpected events")
+
+	// Check the events delivered to the listener and the callback
+	assert.Equal(t, 3, len(received), "Unexpected events")
+	assert.Equal(t, StateHealthy, received[0].From, "Unexpected event")
+	assert.Equal(t, 0.941, received[0].FuseRatio, "Unexpected fuse ratio")
+	assert.Equal(t, 3, len(callback.Changes()), "Unexpected state changes")
+}
+
+func TestGoogleBreaker_StateDebounce(t *testing.T) {
+	tracker := newStateTracker(time.Second, DefaultRejectingRatio)
+	now := time.Now()
+
+	// A short spike does not change the state
+	_, ok := tracker.observe(now, 0.5, 0.5)
+	assert.False(t, ok, "Unexpected state change")
+	_, ok = tracker.observe(now.Add(500*time.Millisecond), 0, 0)
+	assert.False(t, ok, "Unexpected state change")
+	assert.Equal(t, StateHealthy, tracker.current(), "Unexpected state")
+
+	// A stable ratio changes the state after the debounce time
+	_, ok = tracker.observe(now.Add(time.Second), 0.5, 0.5)
+	assert.False(t, ok, "Unexpected state change")
+	_, ok = tracker.observe(now.Add(1500*time.Millisecond), 0.6, 0.6)
+	assert.False(t, ok, "Unexpected state change")
+	event, ok := tracker.observe(now.Add(2*time.Second), 0.5, 0.5)
+	assert.True(t, ok, "Unexpected state change")
+	assert.Equal(t, StateHealthy, event.From, "Unexpected event")
+	assert.Equal(t, StateThrottling, event.To, "Unexpected event")
+	assert.Equal(t, StateThrottling, tracker.current(), "Unexpected state")
+}
+
+func TestGoogleBreaker_WithClock(t *testing.T) {
+	// create a new GoogleBreaker with a fake clock
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	breaker := NewGoogleBreaker(NewConfig().WithClock(clock).WithStateDebounce(0))
+	defer breaker.Stop()
+
+	// Simulate running 100 times, failed
+	for i := 0; i < 100; i++ {
+		breaker.MarkFailure(errors.New("execution error"))
+	}
+	err := breaker.accept(0.4)
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "unexpected error returned by accept")
+	assert.Equal(t, StateRejecting, breaker.State(), "Unexpected state")
+
+	// The failures expire after the state window, the breaker recovers
+	clock.Advance(DefaultStateWindow * time.Second)
+	err = breaker.accept(0)
+	assert.NoError(t, err, "unexpected error returned by accept")
+	assert.Equal(t, StateRecovered, breaker.State(), "Unexpected state")
+}
+
+func TestGoogleBreaker_WithRandom(t *testing.T) {
+	// decisions returns the rejection decisions of a breaker whose fuse ratio is 0.941
+	decisions := func(random RandomSource) []bool {
+		breaker := NewGoogleBreaker(NewConfig().WithRandom(random))
+		defer breaker.Stop()
+
+		for i := 0; i < 100; i++ {
+			assert.Nil(t, breaker.rwin.Add(0))
+		}
+
+		var result []bool
+		for i := 0; i < 50; i++ {
+			_, err := breaker.Allow()
+			result = append(result, err != nil)
+		}
+		return result
+	}
+
+	// The scripted values decide the rejections
+	assert.Equal(t, []bool{true, false, true, false}, decisions(NewScriptedRandom(0.5, 0.95))[:4], "Unexpected decisions")
+
+	// The same seed produces the same decisions
+	assert.Equal(t, decisions(NewSeededRandom(42)), decisions(NewSeededRandom(42)), "Unexpected decisions")
+
+	// The recorded values replay the same decisions
+	recording := NewRecordingRandom(nil, 0)
+	expected := decisions(recording)
+	assert.Equal(t, 50, len(recording.Values()), "Unexpected recorded values")
+	assert.Equal(t, expected, decisions(recording.Replay()), "Unexpected decisions")
+
+	// The recording keeps only the most recent values
+	recording = NewRecordingRandom(NewScriptedRandom(0.1, 0.2, 0.3, 0.4, 0.5), 3)
+	for i := 0; i < 5; i++ {
+		recording.Float64()
+	}
+	assert.Equal(t, []float64{0.3, 0.4, 0.5}, recording.Values(), "Unexpected recorded values")
+}
+
+func TestGoogleBreaker_WithWindow(t *testing.T) {
+	// create a new GoogleBreaker with a 2s window and 50ms slots
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	conf := NewConfig().WithClock(clock).WithWindow(2 * time.Second).WithSlotInterval(50 * time.Millisecond)
+	assert.NoError(t, conf.Validate(), "Unexpected error")
+	breaker := NewGoogleBreaker(conf)
+	defer breaker.Stop()
+
+	// Simulate running 100 times, failed
+	for i := 0; i < 100; i++ {
+		breaker.MarkFailure(errors.New("execution error"))
+	}
+	err := breaker.accept(0.4)
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "unexpected error returned by accept")
+
+	// The failures expire after 2s
+	clock.Advance(2 * time.Second)
+	err = breaker.accept(0)
+	assert.NoError(t, err, "unexpected error returned by accept")
+
+	// The state window is used without a window duration
+	breaker = NewGoogleBreaker(NewConfig().WithClock(clock).WithStateWindow(2))
+	defer breaker.Stop()
+	for i := 0; i < 100; i++ {
+		breaker.MarkFailure(errors.New("execution error"))
+	}
+	clock.Advance(2 * time.Second)
+	err = breaker.accept(0)
+	assert.NoError(t, err, "unexpected error returned by accept")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, NewConfig().Validate(), "Unexpected error")
+	assert.NoError(t, NewConfig().WithWindow(30*time.Minute).WithSlotInterval(10*time.Second).Validate(), "Unexpected error")
+	assert.NoError(t, NewConfig().WithSlotInterval(100*time.Millisecond).Validate(), "Unexpected error")
+
+	// Invalid values are reported instead of replaced by the default values
+	assert.ErrorIs(t, NewConfig().WithSlotInterval(-time.Second).Validate(), com.ErrorInvalidSlotInterval, "Unexpected error")
+	assert.ErrorIs(t, NewConfig().WithWindow(time.Second).WithSlotInterval(300*time.Millisecond).Validate(), com.ErrorInvalidWindow, "Unexpected error")
+	assert.ErrorIs(t, NewConfig().WithWindow(-time.Second).Validate(), com.ErrorInvalidWindow, "Unexpected error")
+
+	// The breaker uses the default window with an invalid window
+	conf := NewConfig().WithWindow(time.Second).WithSlotInterval(300 * time.Millisecond).WithLatencyHistogram(true)
+	assert.NotPanics(t, func() {
+		breaker := NewGoogleBreaker(conf)
+		defer breaker.Stop()
+		assert.NoError(t, breaker.Do(func() error { return nil }), "Unexpected error")
+	}, "Unexpected panic")
+
+	// The error constructors report an invalid window
+	_, err := NewGoogleBreakerE(conf)
+	assert.ErrorIs(t, err, com.ErrorInvalidWindow, "Unexpected error")
+	_, err = NewThreeStateBreakerE(conf)
+	assert.ErrorIs(t, err, com.ErrorInvalidWindow, "Unexpected error")
+	breaker, err := NewGoogleBreakerE(nil)
+	assert.NoError(t, err, "Unexpected error")
+	breaker.Stop()
+	assert.NotPanics(t, func() {
+		NewGoogleBreaker(NewConfig().WithStateWindow(1 << 20).WithLatencyHistogram(true)).Stop()
+	}, "Unexpected panic")
+}
+
+func TestGoogleBreaker_AtomicWindow(t *testing.T) {
+	// create a new GoogleBreaker with the atomic window
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	breaker := NewGoogleBreaker(NewConfig().WithClock(clock).WithWindowMode(WindowModeAtomic))
+	defer breaker.Stop()
+	assert.IsType(t, &PooledRandom{}, breaker.sr, "Unexpected random source")
+
+	// Simulate running 100 times, failed, from several goroutines
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				breaker.MarkFailure(errors.New("execution error"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// All failures are counted
+	_, total, err := breaker.history()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, uint64(100), total, "Unexpected total")
+	err = breaker.accept(0.4)
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "unexpected error returned by accept")
+
+	// The failures expire after the state window
+	clock.Advance(DefaultStateWindow * time.Second)
+	err = breaker.accept(0)
+	assert.NoError(t, err, "unexpected error returned by accept")
+}
+
+func benchmarkGoogleBreakerDo(b *testing.B, conf *Config) {
+	breaker := NewGoogleBreaker(conf)
+	defer breaker.Stop()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = breaker.Do(func() error { return nil })
+		}
+	})
+}
+
+func BenchmarkGoogleBreaker_Do(b *testing.B) {
+	benchmarkGoogleBreakerDo(b, NewConfig())
+}
+
+func BenchmarkGoogleBreaker_DoAtomic(b *testing.B) {
+	benchmarkGoogleBreakerDo(b, NewConfig().WithWindowMode(WindowModeAtomic))
+}
+
+func TestGoogleBreaker_SlowCall(t *testing.T) {
+	// create a new GoogleBreaker which counts calls taking 10ms or longer as failures
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	breaker := NewGoogleBreaker(NewConfig().WithClock(clock).WithSlowCallThreshold(10 * time.Millisecond))
+	defer breaker.Stop()
+
+	// Fast calls are successes
+	for i := 0; i < 10; i++ {
+		err := breaker.Do(func() error { return nil })
+		assert.NoError(t, err, "Unexpected error")
+	}
+	ratio, err := breaker.SlowCallRatio()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0.0, ratio, "Unexpected slow call ratio")
+
+	// Slow calls return nil, but are counted as failures
+	for i := 0; i < 90; i++ {
+		_ = breaker.Do(func() error {
+			clock.Advance(20 * time.Millisecond)
+			return nil
+		})
+	}
+	ratio, err = breaker.SlowCallRatio()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Greater(t, ratio, 0.5, "Unexpected slow call ratio")
+	accepted, total, err := breaker.history()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Less(t, accepted, float64(total)/2, "Unexpected accepted")
+	assert.ErrorIs(t, breaker.accept(0), com.ErrorServiceUnavailable, "unexpected error returned by accept")
+
+	// The notifier returned by Allow times the execution
+	breaker = NewGoogleBreaker(NewConfig().WithClock(clock).WithSlowCallThreshold(time.Second).WithSlowCallWeight(0.5))
+	defer breaker.Stop()
+	notifier, err := breaker.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	clock.Advance(time.Second)
+	notifier.MarkSuccess()
+	accepted, total, err = breaker.history()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0.5, accepted, "Unexpected accepted")
+	assert.Equal(t, uint64(1), total, "Unexpected total")
+	ratio, err = breaker.SlowCallRatio()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 1.0, ratio, "Unexpected slow call ratio")
+
+	// Slow call detection is disabled by default
+	breaker = NewGoogleBreaker(NewConfig().WithClock(clock))
+	defer breaker.Stop()
+	notifier, err = breaker.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, breaker, notifier, "Unexpected notifier")
+}
+
+func TestGoogleBreaker_LatencyQuantile(t *testing.T) {
+	// create a new GoogleBreaker with the latency histogram
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	breaker := NewGoogleBreaker(NewConfig().WithClock(clock).WithLatencyHistogram(true))
+	defer breaker.Stop()
+
+	// 90 calls take 10ms, 10 calls take 100ms
+	for i := 0; i < 100; i++ {
+		latency := 10 * time.Millisecond
+		if i%10 == 0 {
+			latency = 100 * time.Millisecond
+		}
+		notifier, err := breaker.Allow()
+		assert.NoError(t, err, "Unexpected error")
+		clock.Advance(latency)
+		notifier.MarkSuccess()
+	}
+
+	p50, err := breaker.LatencyQuantile(0.5)
+	assert.NoError(t, err, "Unexpected error")
+	assert.InEpsilon(t, float64(10*time.Millisecond), float64(p50), 0.07, "Unexpected p50")
+	p99, err := breaker.LatencyQuantile(0.99)
+	assert.NoError(t, err, "Unexpected error")
+	assert.InEpsilon(t, float64(100*time.Millisecond), float64(p99), 0.07, "Unexpected p99")
+	sum, count := breaker.LatencyTotal()
+	assert.Equal(t, 1900*time.Millisecond, sum, "Unexpected sum")
+	assert.Equal(t, uint64(100), count, "Unexpected count")
+
+	// Without the latency histogram the quantile is 0
+	breaker = NewGoogleBreaker(NewConfig())
+	defer breaker.Stop()
+	p99, err = breaker.LatencyQuantile(0.99)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, time.Duration(0), p99, "Unexpected p99")
+}
+
+func TestGoogleBreaker_PanicRecovery(t *testing.T) {
+	callback := &testCallback{}
+	breaker := NewGoogleBreaker(NewConfig().WithCallback(callback).WithPanicRecovery(true))
+	defer breaker.Stop()
+
+	// Test case 1: Panic is recovered and returned as a PanicError
+	err := breaker.Do(func() error {
+		panic("boom")
+	})
+	var pe *com.PanicError
+	assert.True(t, errors.As(err, &pe), "Expected panic error")
+	assert.Equal(t, "boom", pe.Value, "Unexpected panic value")
+	assert.NotEmpty(t, pe.Stack, "Expected stack trace")
+	assert.Equal(t, 1, callback.fc, "Unexpected failure count")
+
+	// Test case 2: Panic is handed to the fallback function
+	var fallbackErr error
+	err = breaker.DoWithFallback(func() error {
+		panic(errors.New("panic error"))
+	}, func(err error) error {
+		fallbackErr = err
+		return nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.EqualError(t, fallbackErr, "panic: panic error", "Unexpected fallback error")
+	assert.Equal(t, 2, callback.fc, "Unexpected failure count")
+
+	// Test case 3: Panic with the context
+	err = breaker.DoCtx(context.Background(), func(ctx context.Context) error {
+		panic("boom")
+	})
+	assert.True(t, errors.As(err, &pe), "Expected panic error")
+	assert.Equal(t, 3, callback.fc, "Unexpected failure count")
+
+	// Test case 4: Panic propagates when recovery is disabled
+	plain := NewGoogleBreaker(nil)
+	defer plain.Stop()
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = plain.Do(func() error {
+			panic("boom")
+		})
+	}, "Expected panic")
+}
+
+func TestGoogleBreaker_Override(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	clock := tripwiretest.NewFakeClock(time.Time{})
+	callback := &stateCallback{}
+	breaker := NewGoogleBreaker(NewConfig().WithCallback(callback).WithClock(clock).WithStateDebounce(0))
+	defer breaker.Stop()
+
+	events, unsubscribe := breaker.SubscribeChan(8)
+	defer unsubscribe()
+
+	// Test case 1: Forced open rejects all executions without recording them
+	breaker.ForceOpen(0)
+	assert.Equal(t, StateForcedOpen, breaker.State(), "State mismatch")
+	for i := 0; i < 10; i++ {
+		err := breaker.Do(func() error { return nil })
+		assert.ErrorIs(t, err, com.ErrorForcedOpen, "Unexpected error")
+		assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+	}
+	_, err := breaker.Allow()
+	assert.ErrorIs(t, err, com.ErrorForcedOpen, "Unexpected error")
+	_, total, _ := breaker.history()
+	assert.Equal(t, uint64(0), total, "Unexpected total")
+
+	// Test case 2: Forced closed allows all executions, the results are still recorded
+	breaker.ForceClose(0)
+	assert.Equal(t, StateForcedClosed, breaker.State(), "State mismatch")
+	for i := 0; i < 100; i++ {
+		err := breaker.Do(func() error { return execError })
+		assert.ErrorIs(t, err, execError, "Unexpected error")
+	}
+	_, total, _ = breaker.history()
+	assert.Equal(t, uint64(100), total, "Unexpected total")
+
+	// Test case 3: Clearing the override returns to the automatic decision
+	breaker.ClearOverride()
+	assert.Equal(t, StateHealthy, breaker.State(), "State mismatch")
+	breaker.ClearOverride()
+
+	// Test case 4: The override expires, concurrent reads notify the expiry only once
+	breaker.ForceOpen(time.Second)
+	assert.Equal(t, StateForcedOpen, breaker.State(), "State mismatch")
+	clock.Advance(time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, StateHealthy, breaker.State(), "State mismatch")
+		}()
+	}
+	wg.Wait()
+
+	expected := []stateChange{
+		{StateHealthy, StateForcedOpen},
+		{StateForcedOpen, StateForcedClosed},
+		{StateForcedClosed, StateHealthy},
+		{StateHealthy, StateForcedOpen},
+		{StateForcedOpen, StateHealthy},
+	}
+	assert.Equal(t, expected, callback.Changes(), "State changes mismatch")
+	for _, change := range expected {
+		event := <-events
+		assert.Equal(t, change, stateChange{event.From, event.To}, "Event mismatch")
+	}
+}
+
+func TestGoogleBreaker_Reset(t *testing.T) {
+	callback := &stateCallback{}
+	breaker := NewGoogleBreaker(NewConfig().WithCallback(callback).WithStateDebounce(0).WithSlowCallThreshold(time.Second).WithLatencyHistogram(true))
+	defer breaker.Stop()
+
+	// Simulate running 100 times, failed
+	for i := 0; i < 100; i++ {
+		breaker.markFailure(errors.New("execution error"), 2*time.Second)
+	}
+	err := breaker.accept(0.4)
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+	assert.Equal(t, StateRejecting, breaker.State(), "State mismatch")
+
+	// Reset clears the windows and the state
+	breaker.Reset()
+	assert.Equal(t, StateHealthy, breaker.State(), "State mismatch")
+	_, total, _ := breaker.history()
+	assert.Equal(t, uint64(0), total, "Unexpected total")
+	ratio, err := breaker.SlowCallRatio()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0.0, ratio, "Unexpected slow call ratio")
+	latency, err := breaker.LatencyQuantile(0.5)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, time.Duration(0), latency, "Unexpected latency")
+	assert.NoError(t, breaker.accept(0.4), "Unexpected error")
+
+	// Resetting a healthy breaker does not notify
+	breaker.Reset()
+	assert.Equal(t, []stateChange{{StateHealthy, StateRejecting}, {StateRejecting, StateHealthy}}, callback.Changes(), "State changes mismatch")
+}
+
+func TestGoogleBreaker_Update(t *testing.T) {
+	breaker := NewGoogleBreaker(NewConfig().WithStateDebounce(0))
+	defer breaker.Stop()
+
+	// Simulate running 100 times, failed, fuse ratio is 0.941
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, breaker.rwin.Add(0))
+	}
+	err := breaker.accept(0.9)
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+
+	// Test case 1: Changing the thresholds keeps the history, fuse ratio is 0.693
+	callback := newTestCallback()
+	err = breaker.Update(NewConfig().WithProtected(30).WithCallback(callback).WithStateDebounce(0))
+	assert.NoError(t, err, "Unexpected error")
+	_, total, _ := breaker.history()
+	assert.Equal(t, uint64(100), total, "Unexpected total")
+	assert.NoError(t, breaker.accept(0.9), "Unexpected error")
+	assert.Equal(t, 0.693, callback.(*testCallback).fuse, "Unexpected fuse ratio")
+
+	// Test case 2: Changing the window starts a new window
+	err = breaker.Update(NewConfig().WithWindow(5 * time.Second).WithSlotInterval(500 * time.Millisecond))
+	assert.NoError(t, err, "Unexpected error")
+	_, total, _ = breaker.history()
+	assert.Equal(t, uint64(0), total, "Unexpected total")
+
+	// Test case 3: Enabling and disabling the slow call window
+	assert.NoError(t, breaker.Update(NewConfig().WithWindow(5*time.Second).WithSlotInterval(500*time.Millisecond).WithSlowCallThreshold(time.Second)), "Unexpected error")
+	breaker.markSuccess(2 * time.Second)
+	ratio, _ := breaker.SlowCallRatio()
+	assert.Equal(t, 1.0, ratio, "Unexpected slow call ratio")
+	assert.NoError(t, breaker.Update(NewConfig().WithWindow(5*time.Second).WithSlotInterval(500*time.Millisecond)), "Unexpected error")
+	ratio, _ = breaker.SlowCallRatio()
+	assert.Equal(t, 0.0, ratio, "Unexpected slow call ratio")
+
+	// Test case 4: An invalid window is rejected and the configuration is unchanged
+	err = breaker.Update(NewConfig().WithWindow(time.Second).WithSlotInterval(300 * time.Millisecond))
+	assert.ErrorIs(t, err, com.ErrorInvalidWindow, "Unexpected error")
+	window, _ := breaker.conf().windowSpec()
+	assert.Equal(t, 5*time.Second, window, "Unexpected window")
+
+	// Test case 5: A stopped breaker cannot be updated
+	breaker.Stop()
+	assert.ErrorIs(t, breaker.Update(nil), com.ErrorRollingWindowStopped, "Unexpected error")
+}
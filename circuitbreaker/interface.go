@@ -18,6 +18,22 @@ type Callback interface {
 	OnAccept(reason error, fuse, failure float64)
 }
 
+// StateChangeCallback 是可选的回调接口，实现了它的 Callback 会在熔断器状态发生变化时收到通知。
+// StateChangeCallback is an optional callback interface, a Callback implementing it is notified when the state of the breaker changes.
+type StateChangeCallback interface {
+	// OnStateChange 在熔断器状态发生变化时被调用。
+	// OnStateChange is called when the state of the breaker changes.
+	OnStateChange(from, to State)
+}
+
+// notifyStateChange 在回调实现了 StateChangeCallback 时通知它状态发生了变化。
+// notifyStateChange notifies the callback of the state change if it implements StateChangeCallback.
+func notifyStateChange(callback Callback, from, to State) {
+	if sc, ok := callback.(StateChangeCallback); ok {
+		sc.OnStateChange(from, to)
+	}
+}
+
 // emptyCallback 是熔断器的空回调。
 // emptyCallback is the empty callback for the breaker.
 type emptyCallback struct{}
@@ -0,0 +1,57 @@
+package circuitbreaker
+
+import (
+	"sync/atomic"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// forcedState 是一次手动覆盖的状态和过期时间，设置后不再修改。
+// forcedState is the state and the expiry time of one manual override, it is not modified after being set.
+type forcedState struct {
+	state State     // StateForcedOpen 或 StateForcedClosed StateForcedOpen or StateForcedClosed
+	until time.Time // 过期时间，零值表示不过期 Expiry time, zero means no expiry
+}
+
+// override 是熔断器的手动覆盖，过期的覆盖在下一次读取时被清除。
+// 覆盖保存在原子指针中，没有覆盖时读取只需要一次原子加载，不会在执行路径上加锁。
+// override is the manual override of a breaker, an expired override is cleared on the next read.
+// The override is kept in an atomic pointer, reading without an override takes one atomic load and no lock on the execution path.
+type override struct {
+	p atomic.Pointer[forcedState]
+}
+
+// set 设置覆盖，expiry 为 0 时不过期。
+// set sets the override, it does not expire if expiry is 0.
+func (o *override) set(now time.Time, state State, expiry time.Duration) {
+	forced := &forcedState{state: state}
+	if expiry > 0 {
+		forced.until = now.Add(expiry)
+	}
+	o.p.Store(forced)
+}
+
+// clear 清除覆盖，返回被清除的覆盖状态，没有覆盖时返回 false。
+// clear clears the override, returns the cleared override state, returns false if there is no override.
+func (o *override) clear() (State, bool) {
+	if forced := o.p.Swap(nil); forced != nil {
+		return forced.state, true
+	}
+	return 0, false
+}
+
+// get 返回当前的覆盖状态，只有覆盖会过期时才读取时钟。
+// 覆盖已经过期时清除它，并通过 expired 返回被清除的覆盖状态，同时过期的读取中只有一个会得到 expired。
+// get returns the current override state, the clock is read only if the override expires.
+// If the override has expired, it is cleared and the cleared override state is returned through expired, only one of the concurrent reads gets expired.
+func (o *override) get(clock com.Clock) (state State, active bool, expired bool) {
+	forced := o.p.Load()
+	if forced == nil {
+		return 0, false, false
+	}
+	if !forced.until.IsZero() && !clock.Now().Before(forced.until) {
+		return forced.state, false, o.p.CompareAndSwap(forced, nil)
+	}
+	return forced.state, true, false
+}
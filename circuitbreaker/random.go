@@ -3,9 +3,18 @@ package circuitbreaker
 import (
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// RandomSource 是 GoogleBreaker 决定是否拒绝执行时使用的随机数来源，返回 [0, 1) 之间的浮点数。
+// RandomSource is the source of random numbers used by the GoogleBreaker to decide whether to reject an execution, it returns a float64 in [0, 1).
+type RandomSource interface {
+	Float64() float64
+}
+
+// SafeRandom 是一个线程安全的随机数生成器。
+// SafeRandom is a thread-safe random number generator.
 type SafeRandom struct {
 	// rand.New(...) returns a non thread safe object
 	r    *rand.Rand
@@ -14,8 +23,14 @@ type SafeRandom struct {
 
 // NewSafeRandom returns a Proba.
 func NewSafeRandom() *SafeRandom {
+	return NewSeededRandom(time.Now().UnixNano())
+}
+
+// NewSeededRandom 返回使用指定种子的随机数生成器，相同的种子产生相同的序列。
+// NewSeededRandom returns a random number generator with the given seed, the same seed produces the same sequence.
+func NewSeededRandom(seed int64) *SafeRandom {
 	return &SafeRandom{
-		r: rand.New(rand.NewSource(time.Now().UnixNano())),
+		r: rand.New(rand.NewSource(seed)),
 	}
 }
 
@@ -38,3 +53,117 @@ func (p *SafeRandom) Float64() float64 {
 	defer p.lock.Unlock()
 	return p.r.Float64()
 }
+
+// PooledRandom 是一个没有全局锁的随机数生成器，每个 P 使用 sync.Pool 缓存的独立生成器，适合高并发场景。
+// PooledRandom is a random number generator without a global lock, every P uses its own generator cached in a sync.Pool, it suits highly concurrent workloads.
+type PooledRandom struct {
+	seed int64
+	pool sync.Pool
+}
+
+// NewPooledRandom 返回一个新的 PooledRandom。
+// NewPooledRandom returns a new PooledRandom.
+func NewPooledRandom() *PooledRandom {
+	p := &PooledRandom{seed: time.Now().UnixNano()}
+	p.pool.New = func() any {
+		return rand.New(rand.NewSource(atomic.AddInt64(&p.seed, 1)))
+	}
+	return p
+}
+
+// Float64 返回 [0, 1) 之间的随机浮点数。
+// Float64 returns a random float64 in [0, 1).
+func (p *PooledRandom) Float64() float64 {
+	r := p.pool.Get().(*rand.Rand)
+	v := r.Float64()
+	p.pool.Put(r)
+	return v
+}
+
+// ScriptedRandom 按顺序返回预先给定的值，用完后从头开始循环。
+// ScriptedRandom returns the given values in order, and starts over from the beginning when they run out.
+type ScriptedRandom struct {
+	values []float64
+	index  int
+	lock   sync.Mutex
+}
+
+// NewScriptedRandom 返回一个按顺序返回 values 的随机数来源，values 为空时总是返回 0。
+// NewScriptedRandom returns a random source which returns values in order, it always returns 0 if values is empty.
+func NewScriptedRandom(values ...float64) *ScriptedRandom {
+	return &ScriptedRandom{values: append([]float64(nil), values...)}
+}
+
+// Float64 返回下一个值。
+// Float64 returns the next value.
+func (s *ScriptedRandom) Float64() float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.values) == 0 {
+		return 0
+	}
+	v := s.values[s.index]
+	s.index = (s.index + 1) % len(s.values)
+	return v
+}
+
+// DefaultRecordingSize 是 RecordingRandom 默认保留的值的数量。
+// DefaultRecordingSize is the default number of values kept by RecordingRandom.
+const DefaultRecordingSize = 4096
+
+// RecordingRandom 在固定容量的环形缓冲区中记录另一个随机数来源最近返回的值，可以通过 ScriptedRandom 精确地重放。
+// 缓冲区写满后最早的值被覆盖，所以长时间运行时内存不会增长。
+// RecordingRandom records the values recently returned by another random source in a ring buffer of fixed capacity, they can be replayed exactly with ScriptedRandom.
+// The oldest values are overwritten when the buffer is full, so the memory does not grow in long runs.
+type RecordingRandom struct {
+	source RandomSource
+	values []float64
+	next   int
+	full   bool
+	lock   sync.Mutex
+}
+
+// NewRecordingRandom 返回一个记录 source 最近 size 个返回值的随机数来源。
+// source 为 nil 时使用 NewSafeRandom，size 小于等于 0 时使用 DefaultRecordingSize。
+// NewRecordingRandom returns a random source which records the last size values returned by source.
+// NewSafeRandom is used if source is nil, DefaultRecordingSize is used if size is less than or equal to 0.
+func NewRecordingRandom(source RandomSource, size int) *RecordingRandom {
+	if source == nil {
+		source = NewSafeRandom()
+	}
+	if size <= 0 {
+		size = DefaultRecordingSize
+	}
+	return &RecordingRandom{source: source, values: make([]float64, size)}
+}
+
+// Float64 返回来源的下一个值并记录它。
+// Float64 returns the next value of the source and records it.
+func (r *RecordingRandom) Float64() float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	v := r.source.Float64()
+	r.values[r.next] = v
+	r.next++
+	if r.next == len(r.values) {
+		r.next, r.full = 0, true
+	}
+	return v
+}
+
+// Values 按记录顺序返回保留的值，从最早的开始。
+// Values returns the kept values in the recorded order, starting from the oldest.
+func (r *RecordingRandom) Values() []float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.full {
+		return append([]float64(nil), r.values[:r.next]...)
+	}
+	return append(append([]float64(nil), r.values[r.next:]...), r.values[:r.next]...)
+}
+
+// Replay 返回按记录顺序重放保留值的 ScriptedRandom。
+// Replay returns a ScriptedRandom which replays the kept values in the recorded order.
+func (r *RecordingRandom) Replay() *ScriptedRandom {
+	return NewScriptedRandom(r.Values()...)
+}
@@ -0,0 +1,74 @@
+package circuitbreaker
+
+import (
+	"sync/atomic"
+
+	rw "github.com/shengyanli1982/tripwire/internal/rolling"
+)
+
+// windowBox 包装滚动窗口，使 atomic.Pointer 可以存储接口。
+// windowBox wraps the rolling window, so atomic.Pointer can store the interface.
+type windowBox struct {
+	window rw.Window
+}
+
+// windowSlot 保存一个可以在运行时原子替换的滚动窗口，它本身也实现了 Window 接口。
+// windowSlot holds a rolling window which can be replaced atomically at runtime, it implements the Window interface itself.
+type windowSlot struct {
+	p atomic.Pointer[windowBox]
+}
+
+var _ rw.Window = (*windowSlot)(nil)
+
+// load 返回当前的滚动窗口，没有窗口时返回 nil。
+// load returns the current rolling window, returns nil if there is no window.
+func (s *windowSlot) load() rw.Window {
+	if box := s.p.Load(); box != nil {
+		return box.window
+	}
+	return nil
+}
+
+// store 替换当前的滚动窗口，window 为 nil 时清空。
+// store replaces the current rolling window, clears it if window is nil.
+func (s *windowSlot) store(window rw.Window) {
+	if window == nil {
+		s.p.Store(nil)
+		return
+	}
+	s.p.Store(&windowBox{window: window})
+}
+
+// Add 向当前窗口添加一个值。
+// Add adds a value to the current window.
+func (s *windowSlot) Add(value float64) error {
+	return s.load().Add(value)
+}
+
+// Sum 返回当前窗口中的值的总和和数量。
+// Sum returns the sum and the count of the values in the current window.
+func (s *windowSlot) Sum() (float64, uint64, error) {
+	return s.load().Sum()
+}
+
+// Avg 返回当前窗口中的值的平均值和数量。
+// Avg returns the average and the count of the values in the current window.
+func (s *windowSlot) Avg() (float64, uint64, error) {
+	return s.load().Avg()
+}
+
+// Reset 清空当前窗口中的统计数据。
+// Reset clears the statistics of the current window.
+func (s *windowSlot) Reset() {
+	if w := s.load(); w != nil {
+		w.Reset()
+	}
+}
+
+// Stop 停止当前窗口。
+// Stop stops the current window.
+func (s *windowSlot) Stop() {
+	if w := s.load(); w != nil {
+		w.Stop()
+	}
+}
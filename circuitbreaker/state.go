@@ -0,0 +1,292 @@
+package circuitbreaker
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State 是熔断器的状态。
+// State is the state of the breaker.
+type State int32
+
+const (
+	// StateClosed 表示熔断器关闭，所有请求都被允许。
+	// StateClosed means the breaker is closed and all requests are allowed.
+	StateClosed State = iota
+
+	// StateOpen 表示熔断器打开，所有请求都被拒绝。
+	// StateOpen means the breaker is open and all requests are rejected.
+	StateOpen
+
+	// StateHalfOpen 表示熔断器半开，只允许有限的探测请求。
+	// StateHalfOpen means the breaker is half-open and only a limited number of probe requests are allowed.
+	StateHalfOpen
+
+	// StateHealthy 表示 GoogleBreaker 健康，没有拒绝任何请求。
+	// StateHealthy means the GoogleBreaker is healthy and rejects no requests.
+	StateHealthy
+
+	// StateThrottling 表示 GoogleBreaker 正在按概率拒绝部分请求。
+	// StateThrottling means the GoogleBreaker is rejecting part of the requests by probability.
+	StateThrottling
+
+	// StateRejecting 表示 GoogleBreaker 几乎拒绝所有请求。
+	// StateRejecting means the GoogleBreaker is rejecting almost all requests.
+	StateRejecting
+
+	// StateRecovered 表示 GoogleBreaker 从限流或拒绝中恢复，不再拒绝请求。
+	// StateRecovered means the GoogleBreaker has recovered from throttling or rejecting and rejects no requests.
+	StateRecovered
+
+	// StateForcedOpen 表示熔断器被手动强制打开，拒绝所有请求。
+	// StateForcedOpen means the breaker is forced open manually and rejects all requests.
+	StateForcedOpen
+
+	// StateForcedClosed 表示熔断器被手动强制关闭，允许所有请求。
+	// StateForcedClosed means the breaker is forced closed manually and allows all requests.
+	StateForcedClosed
+)
+
+// String 返回状态的名称。
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	case StateHealthy:
+		return "healthy"
+	case StateThrottling:
+		return "throttling"
+	case StateRejecting:
+		return "rejecting"
+	case StateRecovered:
+		return "recovered"
+	case StateForcedOpen:
+		return "forced-open"
+	case StateForcedClosed:
+		return "forced-closed"
+	default:
+		return "unknown"
+	}
+}
+
+// StateChange 是熔断器状态变化的事件。
+// StateChange is the event of a breaker state change.
+type StateChange struct {
+	From         State     // 变化前的状态 The state before the change
+	To           State     // 变化后的状态 The state after the change
+	Time         time.Time // 变化的时间 The time of the change
+	FuseRatio    float64   // 变化时的熔断比率 The fuse ratio at the change
+	FailureRatio float64   // 变化时的失败比率 The failure ratio at the change
+}
+
+// StateListener 是接收状态变化事件的函数。
+// StateListener is a function that receives state change events.
+type StateListener = func(event StateChange)
+
+// chanListener 把状态变化事件发送到通道，通道已满时丢弃事件。
+// chanListener sends state change events to a channel, events are dropped when the channel is full.
+type chanListener struct {
+	lock   sync.Mutex
+	ch     chan StateChange
+	closed bool
+}
+
+// send 发送事件，不会阻塞。
+// send sends the event without blocking.
+func (l *chanListener) send(event StateChange) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return
+	}
+	select {
+	case l.ch <- event:
+	default:
+	}
+}
+
+// close 关闭通道。
+// close closes the channel.
+func (l *chanListener) close() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !l.closed {
+		l.closed = true
+		close(l.ch)
+	}
+}
+
+// eventHub 管理状态变化事件的订阅者。
+// eventHub manages the subscribers of state change events.
+type eventHub struct {
+	lock      sync.RWMutex
+	listeners map[uint64]StateListener
+	nextID    uint64
+}
+
+// subscribe 添加一个订阅者，返回取消订阅的函数。
+// subscribe adds a subscriber and returns the function to unsubscribe.
+func (h *eventHub) subscribe(listener StateListener) func() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.listeners == nil {
+		h.listeners = make(map[uint64]StateListener)
+	}
+	id := h.nextID
+	h.nextID++
+	h.listeners[id] = listener
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			h.lock.Lock()
+			delete(h.listeners, id)
+			h.lock.Unlock()
+		})
+	}
+}
+
+// subscribeChan 添加一个通道订阅者，返回通道和取消订阅的函数，取消订阅时通道被关闭。
+// subscribeChan adds a channel subscriber and returns the channel and the function to unsubscribe, the channel is closed on unsubscribe.
+func (h *eventHub) subscribeChan(size int) (<-chan StateChange, func()) {
+	if size < 0 {
+		size = 0
+	}
+	l := &chanListener{ch: make(chan StateChange, size)}
+	unsubscribe := h.subscribe(l.send)
+	return l.ch, func() {
+		unsubscribe()
+		l.close()
+	}
+}
+
+// publish 把事件发送给所有订阅者。
+// publish sends the event to all subscribers.
+func (h *eventHub) publish(event StateChange) {
+	h.lock.RLock()
+	listeners := make([]StateListener, 0, len(h.listeners))
+	for _, l := range h.listeners {
+		listeners = append(listeners, l)
+	}
+	h.lock.RUnlock()
+
+	// 在锁外调用订阅者，订阅者可以安全地取消订阅。
+	// Call the subscribers outside the lock, so they can unsubscribe safely.
+	for _, l := range listeners {
+		l(event)
+	}
+}
+
+// stateTracker 根据熔断比率推导 GoogleBreaker 的状态，并对状态变化去抖动。
+// 状态、候选状态和拒绝阈值保存在原子变量中，状态稳定时观察不需要加锁，只有状态可能变化时才加锁。
+// stateTracker derives the state of the GoogleBreaker from the fuse ratio and debounces the state changes.
+// The state, the candidate state and the rejecting threshold are kept in atomics, so observing takes no lock while the state is stable, the lock is taken only when the state may change.
+type stateTracker struct {
+	lock      sync.Mutex    // 保护状态变化和下面字段的锁 Lock protecting the state changes and the fields below
+	state     atomic.Int32  // 当前状态 Current state
+	candidate atomic.Int32  // 候选状态 Candidate state
+	rejecting atomic.Uint64 // 拒绝阈值的位表示 Bits of the rejecting threshold
+	since     time.Time     // 候选状态开始的时间 Time the candidate state started
+	debounce  time.Duration // 去抖动时间 Debounce time
+}
+
+// newStateTracker 返回一个新的状态跟踪器，初始状态为健康。
+// newStateTracker returns a new state tracker, the initial state is healthy.
+func newStateTracker(debounce time.Duration, rejecting float64) *stateTracker {
+	t := &stateTracker{debounce: debounce}
+	t.state.Store(int32(StateHealthy))
+	t.candidate.Store(int32(StateHealthy))
+	t.rejecting.Store(math.Float64bits(rejecting))
+	return t
+}
+
+// current 返回当前状态。
+// current returns the current state.
+func (t *stateTracker) current() State {
+	return State(t.state.Load())
+}
+
+// reset 把状态重置为健康，返回重置前的状态。
+// reset resets the state to healthy and returns the state before the reset.
+func (t *stateTracker) reset() State {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.candidate.Store(int32(StateHealthy))
+	return State(t.state.Swap(int32(StateHealthy)))
+}
+
+// configure 更新去抖动时间和拒绝阈值。
+// configure updates the debounce time and the rejecting threshold.
+func (t *stateTracker) configure(debounce time.Duration, rejecting float64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.debounce = debounce
+	t.rejecting.Store(math.Float64bits(rejecting))
+}
+
+// target 返回熔断比率在当前状态下对应的目标状态。
+// target returns the target state of the fuse ratio in the current state.
+func (t *stateTracker) target(fuse float64, state State) State {
+	switch {
+	case fuse >= math.Float64frombits(t.rejecting.Load()):
+		return StateRejecting
+	case fuse > 0:
+		return StateThrottling
+	case state == StateThrottling || state == StateRejecting:
+		return StateRecovered
+	default:
+		return StateHealthy
+	}
+}
+
+// observe 观察一次熔断比率，如果状态在去抖动时间内保持稳定，返回状态变化事件。
+// observe observes a fuse ratio, returns a state change event if the state stays stable for the debounce time.
+func (t *stateTracker) observe(now time.Time, fuse, failure float64) (StateChange, bool) {
+	// 目标状态与当前状态相同，并且没有候选状态，不需要加锁。
+	// The target state is the current state and there is no candidate state, no lock is needed.
+	state := t.current()
+	if t.target(fuse, state) == state && State(t.candidate.Load()) == state {
+		return StateChange{}, false
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// 目标状态与当前状态相同，取消候选状态。
+	// The target state is the current state, drop the candidate state.
+	state = t.current()
+	target := t.target(fuse, state)
+	if target == state {
+		t.candidate.Store(int32(state))
+		return StateChange{}, false
+	}
+
+	// 新的候选状态，从现在开始计时。
+	// A new candidate state, start timing from now.
+	if target != State(t.candidate.Load()) {
+		t.candidate.Store(int32(target))
+		t.since = now
+	}
+
+	// 候选状态还没有稳定足够长的时间。
+	// The candidate state has not been stable long enough.
+	if now.Sub(t.since) < t.debounce {
+		return StateChange{}, false
+	}
+
+	// 切换到候选状态。
+	// Switch to the candidate state.
+	event := StateChange{From: state, To: target, Time: now, FuseRatio: fuse, FailureRatio: failure}
+	t.state.Store(int32(target))
+	return event, true
+}
@@ -0,0 +1,491 @@
+package circuitbreaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	rw "github.com/shengyanli1982/tripwire/internal/rolling"
+	"github.com/shengyanli1982/tripwire/internal/utils"
+)
+
+// ThreeStateBreaker 是一个经典的三态 (关闭/打开/半开) 熔断器。
+// ThreeStateBreaker is a classic three-state (closed/open/half-open) circuit breaker.
+type ThreeStateBreaker struct {
+	config     *Config    // 熔断器的配置 Config of the breaker
+	rwin       rw.Window  // 关闭状态下的滚动窗口 Rolling window used in the closed state
+	once       sync.Once  // 用于确保某个操作只执行一次 The sync.Once to ensure that an operation is executed only once
+	lock       sync.Mutex // 保护状态的互斥锁 The mutex to protect the state
+	state      State      // 当前状态 Current state
+	generation uint64     // 状态代数，每次状态变化时递增 State generation, increased on every state change
+	openedAt   time.Time  // 熔断器打开的时间 The time when the breaker was opened
+	probes     int        // 半开状态下正在执行的探测请求数 Number of in-flight probes in the half-open state
+	successes  int        // 半开状态下成功的探测请求数 Number of successful probes in the half-open state
+	forced     override   // 手动覆盖 Manual override
+}
+
+// NewThreeStateBreaker 返回一个新的三态熔断器。
+// NewThreeStateBreaker returns a new three-state breaker.
+func NewThreeStateBreaker(conf *Config) *ThreeStateBreaker {
+	conf = isConfigValid(conf)
+	return &ThreeStateBreaker{
+		config: conf,
+		rwin:   newRollingWindow(conf),
+		once:   sync.Once{},
+		lock:   sync.Mutex{},
+		state:  StateClosed,
+	}
+}
+
+// NewThreeStateBreakerE 返回一个新的三态熔断器，滚动窗口无效时返回 Validate 的错误。
+// NewThreeStateBreakerE returns a new three-state breaker, returns the error of Validate if the rolling window is invalid.
+func NewThreeStateBreakerE(conf *Config) (*ThreeStateBreaker, error) {
+	if conf != nil {
+		if err := conf.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return NewThreeStateBreaker(conf), nil
+}
+
+// Stop 停止熔断器。
+// Stop stops the breaker.
+func (b *ThreeStateBreaker) Stop() {
+	b.once.Do(func() {
+		b.rwin.Stop() // 停止滚动窗口
+	})
+}
+
+// State 返回熔断器的当前状态，有手动覆盖时返回覆盖的状态。
+// State returns the current state of the breaker, the override state if there is a manual override.
+func (b *ThreeStateBreaker) State() State {
+	if state, ok := b.overridden(); ok {
+		return state
+	}
+	return b.current()
+}
+
+// current 返回自动判断的状态，不考虑手动覆盖。
+// current returns the automatically decided state, regardless of the manual override.
+func (b *ThreeStateBreaker) current() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+// ForceOpen 强制打开熔断器，拒绝所有执行并返回 ErrorForcedOpen，expiry 为 0 时一直有效，直到被清除。
+// ForceOpen forces the breaker open, all executions are rejected with ErrorForcedOpen, it stays until cleared if expiry is 0.
+func (b *ThreeStateBreaker) ForceOpen(expiry time.Duration) {
+	b.force(StateForcedOpen, expiry)
+}
+
+// ForceClose 强制关闭熔断器，允许所有执行，expiry 为 0 时一直有效，直到被清除。
+// 强制关闭期间的执行结果只通知回调函数，不影响自动判断的状态，清除后熔断器从原来的状态继续。
+// ForceClose forces the breaker closed, all executions are allowed, it stays until cleared if expiry is 0.
+// The results while forced closed are only reported to the callback and do not affect the automatic state, the breaker continues from the previous state after clearing.
+func (b *ThreeStateBreaker) ForceClose(expiry time.Duration) {
+	b.force(StateForcedClosed, expiry)
+}
+
+// ClearOverride 清除手动覆盖，熔断器恢复自动判断。
+// ClearOverride clears the manual override, the breaker decides automatically again.
+func (b *ThreeStateBreaker) ClearOverride() {
+	if state, ok := b.forced.clear(); ok {
+		b.notify(state, b.current())
+	}
+}
+
+// force 设置手动覆盖，并通知状态变化。
+// force sets the manual override and notifies the state change.
+func (b *ThreeStateBreaker) force(state State, expiry time.Duration) {
+	from := b.State()
+	b.forced.set(b.config.clock.Now(), state, expiry)
+	b.notify(from, state)
+}
+
+// overridden 返回手动覆盖的状态，覆盖过期时通知状态变化。
+// overridden returns the state of the manual override, and notifies the state change when the override expires.
+func (b *ThreeStateBreaker) overridden() (State, bool) {
+	state, active, expired := b.forced.get(b.config.clock)
+	if expired {
+		b.notify(state, b.current())
+	}
+	return state, active
+}
+
+// notify 通知回调函数状态发生了变化。
+// notify notifies the callback of the state change.
+func (b *ThreeStateBreaker) notify(from, to State) {
+	if from != to {
+		notifyStateChange(b.config.callback, from, to)
+	}
+}
+
+// setState 切换熔断器的状态，调用方必须持有锁。返回切换前的状态。
+// setState switches the state of the breaker, the caller must hold the lock. Returns the state before the switch.
+func (b *ThreeStateBreaker) setState(state State) State {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.successes = 0
+
+	switch state {
+	case StateOpen:
+		b.openedAt = b.config.clock.Now()
+	case StateClosed:
+		// 关闭时清空历史，避免旧的失败立即再次打开熔断器。
+		// Clear the history when closing, so old failures do not open the breaker again immediately.
+		b.rwin.Reset()
+	}
+
+	return from
+}
+
+// failureRatio 返回滚动窗口中的失败比率和请求总数。
+// failureRatio returns the failure ratio and the total number of requests in the rolling window.
+func (b *ThreeStateBreaker) failureRatio() (float64, uint64, error) {
+	accepted, total, err := b.rwin.Sum()
+	if err != nil {
+		return 0, 0, err
+	}
+	if total == 0 {
+		return 0, 0, nil
+	}
+	return utils.Round((float64(total)-accepted)/float64(total), DefaultFloatingPrecision), total, nil
+}
+
+// Allow 检查熔断器是否允许执行。
+// Allow checks if the circuit breaker allows the execution.
+func (b *ThreeStateBreaker) Allow() (com.Notifier, error) {
+	// 获取失败比率。
+	// Get the failure ratio.
+	failureRatio, _, err := b.failureRatio()
+	if err != nil {
+		return nil, err
+	}
+
+	// 有手动覆盖时，按覆盖的状态接受或拒绝执行。
+	// With a manual override, accept or reject the execution by the override state.
+	if state, ok := b.overridden(); ok {
+		if state == StateForcedOpen {
+			b.config.callback.OnAccept(com.ErrorForcedOpen, 1, failureRatio)
+			return nil, com.ErrorForcedOpen
+		}
+		b.config.callback.OnAccept(nil, 0, failureRatio)
+		return &threeStateNotifier{breaker: b, forced: true}, nil
+	}
+
+	b.lock.Lock()
+
+	// 打开状态下，超过打开时间后切换到半开状态。
+	// In the open state, switch to the half-open state after the open timeout.
+	from, changed := b.state, false
+	if b.state == StateOpen && b.config.clock.Since(b.openedAt) >= b.config.openTimeout {
+		b.setState(StateHalfOpen)
+		changed = true
+	}
+
+	// 根据当前状态决定是否允许执行。
+	// Decide whether to allow the execution according to the current state.
+	var reason error
+	switch b.state {
+	case StateOpen:
+		reason = com.ErrorServiceUnavailable
+	case StateHalfOpen:
+		if b.probes >= b.config.halfOpenProbes {
+			reason = com.ErrorServiceUnavailable
+		} else {
+			b.probes++
+		}
+	}
+	to, generation := b.state, b.generation
+
+	b.lock.Unlock()
+
+	// 在锁外调用回调函数。
+	// Call the callbacks outside the lock.
+	if changed {
+		notifyStateChange(b.config.callback, from, to)
+	}
+	if reason != nil {
+		b.config.callback.OnAccept(reason, 1, failureRatio)
+		return nil, reason
+	}
+	b.config.callback.OnAccept(nil, 0, failureRatio)
+
+	// 返回绑定到当前状态代数的结果通知器。
+	// Return the result notifier bound to the current state generation.
+	return &threeStateNotifier{breaker: b, generation: generation}, nil
+}
+
+// onSuccess 处理一个成功的执行。
+// onSuccess handles a successful execution.
+func (b *ThreeStateBreaker) onSuccess(generation uint64) {
+	b.lock.Lock()
+
+	// 状态已经变化，忽略过期的结果。
+	// The state has changed, ignore the stale result.
+	if generation != b.generation {
+		b.lock.Unlock()
+		b.config.callback.OnSuccess(nil)
+		return
+	}
+
+	var opterr error
+	from, changed := b.state, false
+	switch b.state {
+	case StateClosed:
+		opterr = b.rwin.Add(1)
+	case StateHalfOpen:
+		// 探测请求全部成功后关闭熔断器。
+		// Close the breaker after all probes succeed.
+		b.probes--
+		b.successes++
+		if b.successes >= b.config.halfOpenProbes {
+			b.setState(StateClosed)
+			changed = true
+		}
+	}
+	to := b.state
+
+	b.lock.Unlock()
+
+	b.config.callback.OnSuccess(opterr)
+	if changed {
+		notifyStateChange(b.config.callback, from, to)
+	}
+}
+
+// onFailure 处理一个失败的执行。
+// onFailure handles a failed execution.
+func (b *ThreeStateBreaker) onFailure(generation uint64, reason error) {
+	b.lock.Lock()
+
+	// 状态已经变化，忽略过期的结果。
+	// The state has changed, ignore the stale result.
+	if generation != b.generation {
+		b.lock.Unlock()
+		b.config.callback.OnFailure(nil, reason)
+		return
+	}
+
+	var opterr error
+	from, changed := b.state, false
+	switch b.state {
+	case StateClosed:
+		// 请求数足够且失败率达到阈值时打开熔断器。
+		// Open the breaker when there are enough requests and the failure ratio reaches the threshold.
+		if opterr = b.rwin.Add(0); opterr == nil {
+			accepted, total, err := b.rwin.Sum()
+			if err == nil && total >= uint64(b.config.minRequests) && (float64(total)-accepted)/float64(total) >= b.config.failureThreshold {
+				b.setState(StateOpen)
+				changed = true
+			}
+		}
+	case StateHalfOpen:
+		// 任何探测请求失败都会重新打开熔断器。
+		// Any failed probe opens the breaker again.
+		b.setState(StateOpen)
+		changed = true
+	}
+	to := b.state
+
+	b.lock.Unlock()
+
+	b.config.callback.OnFailure(opterr, reason)
+	if changed {
+		notifyStateChange(b.config.callback, from, to)
+	}
+}
+
+// release 释放一个未记录结果的执行占用的探测名额。
+// release releases the probe slot held by an execution whose result is not recorded.
+func (b *ThreeStateBreaker) release(generation uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if generation == b.generation && b.state == StateHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// run 执行函数，没有恢复的 panic 会先标记执行失败，归还半开状态的探测名额。
+// run executes the function, an unrecovered panic marks the execution as failed first, so the half-open probe slot is returned.
+func (b *ThreeStateBreaker) run(notifier com.Notifier, fn func() error) error {
+	defer markPanic(notifier)
+	return call(b.config.recoverPanics, fn)
+}
+
+// do 使用熔断器保护执行给定的函数。
+// do executes the given function with circuit breaker protection.
+func (b *ThreeStateBreaker) do(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 如果熔断器拒绝执行，执行回退函数或返回错误。
+	// If the breaker rejects the execution, execute the fallback function or return the error.
+	notifier, err := b.Allow()
+	if err != nil {
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+
+	// 执行函数
+	// Execute the function
+	err = b.run(notifier, fn)
+
+	// 函数发生了 panic，标记执行失败，并交给回退函数处理。
+	// The function panicked, mark the execution as failed and hand it to the fallback function.
+	if isPanic(err) {
+		notifier.MarkFailure(err)
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+
+	// 如果错误可接受，标记执行成功，否则标记执行失败并返回错误。
+	// If the error is acceptable, mark the execution as successful, otherwise mark the execution as failed and return the error.
+	if acceptable(err) {
+		notifier.MarkSuccess()
+		return nil
+	}
+	notifier.MarkFailure(err)
+	return err
+}
+
+// Do 执行函数并返回错误。
+// Do executes the function and returns the error.
+func (b *ThreeStateBreaker) Do(fn com.HandleFunc) error {
+	return b.do(fn, nil, DefaultAcceptableFunc)
+}
+
+// DoWithAcceptable 使用给定的可接受函数执行函数并返回错误。
+// DoWithAcceptable executes the function with the given acceptable function and returns the error.
+func (b *ThreeStateBreaker) DoWithAcceptable(fn com.HandleFunc, acceptable com.AcceptableFunc) error {
+	return b.do(fn, nil, acceptable)
+}
+
+// DoWithFallback 使用给定的回退函数执行函数并返回错误。
+// DoWithFallback executes the function with the given fallback function and returns the error.
+func (b *ThreeStateBreaker) DoWithFallback(fn com.HandleFunc, fallback com.FallbackFunc) error {
+	return b.do(fn, fallback, DefaultAcceptableFunc)
+}
+
+// DoWithFallbackAcceptable 使用给定的回退和可接受函数执行函数并返回错误。
+// DoWithFallbackAcceptable executes the function with the given fallback and acceptable functions and returns the error.
+func (b *ThreeStateBreaker) DoWithFallbackAcceptable(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return b.do(fn, fallback, acceptable)
+}
+
+// doCtx 使用熔断器保护执行给定的带上下文的函数。
+// doCtx executes the given function with the context under circuit breaker protection.
+func (b *ThreeStateBreaker) doCtx(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 如果上下文已经结束，快速失败，不计入统计。
+	// If the context is already done, fail fast without recording the statistics.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 如果熔断器拒绝执行，执行回退函数或返回错误。
+	// If the breaker rejects the execution, execute the fallback function or return the error.
+	notifier, err := b.Allow()
+	if err != nil {
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+
+	// 执行函数
+	// Execute the function
+	err = b.run(notifier, func() error { return fn(ctx) })
+
+	// 函数发生了 panic，标记执行失败，并交给回退函数处理。
+	// The function panicked, mark the execution as failed and hand it to the fallback function.
+	if isPanic(err) {
+		notifier.MarkFailure(err)
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+
+	// 调用方取消了上下文，不计入成功或失败，但要释放半开状态的探测名额。
+	// The caller canceled the context, it is counted as neither success nor failure, but the half-open probe slot is released.
+	if isContextCanceled(ctx, err) {
+		notifier.(*threeStateNotifier).Release()
+		return err
+	}
+
+	// 如果错误可接受，标记执行成功，否则标记执行失败并返回错误。
+	// If the error is acceptable, mark the execution as successful, otherwise mark the execution as failed and return the error.
+	if acceptable(err) {
+		notifier.MarkSuccess()
+		return nil
+	}
+	notifier.MarkFailure(err)
+	return err
+}
+
+// DoCtx 使用上下文执行函数并返回错误。
+// DoCtx executes the function with the context and returns the error.
+func (b *ThreeStateBreaker) DoCtx(ctx context.Context, fn com.HandleCtxFunc) error {
+	return b.doCtx(ctx, fn, nil, DefaultAcceptableFunc)
+}
+
+// DoCtxWithAcceptable 使用上下文和给定的可接受函数执行函数并返回错误。
+// DoCtxWithAcceptable executes the function with the context and the given acceptable function and returns the error.
+func (b *ThreeStateBreaker) DoCtxWithAcceptable(ctx context.Context, fn com.HandleCtxFunc, acceptable com.AcceptableFunc) error {
+	return b.doCtx(ctx, fn, nil, acceptable)
+}
+
+// DoCtxWithFallback 使用上下文和给定的回退函数执行函数并返回错误。
+// DoCtxWithFallback executes the function with the context and the given fallback function and returns the error.
+func (b *ThreeStateBreaker) DoCtxWithFallback(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc) error {
+	return b.doCtx(ctx, fn, fallback, DefaultAcceptableFunc)
+}
+
+// DoCtxWithFallbackAcceptable 使用上下文和给定的回退和可接受函数执行函数并返回错误。
+// DoCtxWithFallbackAcceptable executes the function with the context and the given fallback and acceptable functions and returns the error.
+func (b *ThreeStateBreaker) DoCtxWithFallbackAcceptable(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return b.doCtx(ctx, fn, fallback, acceptable)
+}
+
+// threeStateNotifier 是三态熔断器的结果通知器，绑定到允许执行时的状态代数。
+// threeStateNotifier is the result notifier of the three-state breaker, bound to the state generation when the execution was allowed.
+// 强制关闭时允许的执行只把结果通知给回调函数。
+// An execution allowed while forced closed only reports the result to the callback.
+type threeStateNotifier struct {
+	breaker    *ThreeStateBreaker
+	generation uint64
+	forced     bool
+}
+
+// MarkSuccess 标记一个成功的执行。
+// MarkSuccess marks a successful execution.
+func (n *threeStateNotifier) MarkSuccess() {
+	if n.forced {
+		n.breaker.config.callback.OnSuccess(nil)
+		return
+	}
+	n.breaker.onSuccess(n.generation)
+}
+
+// MarkFailure 标记一个失败的执行。
+// MarkFailure marks a failed execution.
+func (n *threeStateNotifier) MarkFailure(reason error) {
+	if n.forced {
+		n.breaker.config.callback.OnFailure(nil, reason)
+		return
+	}
+	n.breaker.onFailure(n.generation, reason)
+}
+
+// Release 释放执行占用的半开状态探测名额，不记录结果。
+// Release releases the half-open probe slot held by the execution without recording the result.
+func (n *threeStateNotifier) Release() {
+	if !n.forced {
+		n.breaker.release(n.generation)
+	}
+}
@@ -0,0 +1,329 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/tripwiretest"
+	"github.com/stretchr/testify/assert"
+)
+
+type stateChange struct {
+	from, to State
+}
+
+type stateCallback struct {
+	emptyCallback
+	lock    sync.Mutex
+	changes []stateChange
+}
+
+func (c *stateCallback) OnStateChange(from, to State) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.changes = append(c.changes, stateChange{from: from, to: to})
+}
+
+func (c *stateCallback) Changes() []stateChange {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]stateChange(nil), c.changes...)
+}
+
+func TestThreeStateBreaker_OpenOnFailureThreshold(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	callback := &stateCallback{}
+	conf := NewConfig().WithCallback(callback).WithMinRequests(10).WithFailureThreshold(0.5)
+	breaker := NewThreeStateBreaker(conf)
+	defer breaker.Stop()
+
+	// Simulate running 5 times, success
+	for i := 0; i < 5; i++ {
+		err := breaker.Do(func() error { return nil })
+		assert.NoError(t, err, "Unexpected error")
+	}
+
+	// Simulate running 4 times, failed, below min requests
+	for i := 0; i < 4; i++ {
+		err := breaker.Do(func() error { return execError })
+		assert.ErrorIs(t, err, execError, "Unexpected error")
+	}
+	assert.Equal(t, StateClosed, breaker.State(), "State mismatch")
+
+	// Simulate running 1 time, failed, failure ratio is 0.5
+	err := breaker.Do(func() error { return execError })
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+	assert.Equal(t, StateOpen, breaker.State(), "State mismatch")
+	assert.Equal(t, []stateChange{{StateClosed, StateOpen}}, callback.Changes(), "State changes mismatch")
+
+	// The breaker is open, reject the execution
+	err = breaker.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+}
+
+func TestThreeStateBreaker_HalfOpenRecovery(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	callback := &stateCallback{}
+	conf := NewConfig().WithCallback(callback).WithMinRequests(2).WithOpenTimeout(100 * time.Millisecond).WithHalfOpenProbes(2)
+	breaker := NewThreeStateBreaker(conf)
+	defer breaker.Stop()
+
+	// Open the breaker
+	for i := 0; i < 2; i++ {
+		_ = breaker.Do(func() error { return execError })
+	}
+	assert.Equal(t, StateOpen, breaker.State(), "State mismatch")
+
+	// Wait for the open timeout
+	time.Sleep(150 * time.Millisecond)
+
+	// Only 2 probes are allowed in the half-open state
+	n1, err := breaker.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	n2, err := breaker.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	_, err = breaker.Allow()
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+	assert.Equal(t, StateHalfOpen, breaker.State(), "State mismatch")
+
+	// All probes succeed, close the breaker
+	n1.MarkSuccess()
+	assert.Equal(t, StateHalfOpen, breaker.State(), "State mismatch")
+	n2.MarkSuccess()
+	assert.Equal(t, StateClosed, breaker.State(), "State mismatch")
+
+	// History is cleared after closing
+	err = breaker.Do(func() error { return execError })
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+	assert.Equal(t, StateClosed, breaker.State(), "State mismatch")
+
+	expected := []stateChange{{StateClosed, StateOpen}, {StateOpen, StateHalfOpen}, {StateHalfOpen, StateClosed}}
+	assert.Equal(t, expected, callback.Changes(), "State changes mismatch")
+}
+
+func TestThreeStateBreaker_HalfOpenFailure(t *testing.T) {
+	var (
+		execError = errors.New("execution error")
+		fbError   = errors.New("fallback error")
+	)
+
+	conf := NewConfig().WithMinRequests(2).WithOpenTimeout(100 * time.Millisecond)
+	breaker := NewThreeStateBreaker(conf)
+	defer breaker.Stop()
+
+	// Open the breaker
+	for i := 0; i < 2; i++ {
+		_ = breaker.Do(func() error { return execError })
+	}
+
+	// Fallback is called when rejected
+	err := breaker.DoWithFallback(func() error { return nil }, func(err error) error { return fbError })
+	assert.ErrorIs(t, err, fbError, "Unexpected error")
+
+	// Wait for the open timeout
+	time.Sleep(150 * time.Millisecond)
+
+	// A failed probe opens the breaker again
+	err = breaker.Do(func() error { return execError })
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+	assert.Equal(t, StateOpen, breaker.State(), "State mismatch")
+}
+
+func TestThreeStateBreaker_StaleNotifier(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	conf := NewConfig().WithMinRequests(2)
+	breaker := NewThreeStateBreaker(conf)
+	defer breaker.Stop()
+
+	// Allow an execution in the closed state
+	notifier, err := breaker.Allow()
+	assert.NoError(t, err, "Unexpected error")
+
+	// Open the breaker
+	for i := 0; i < 2; i++ {
+		_ = breaker.Do(func() error { return execError })
+	}
+	assert.Equal(t, StateOpen, breaker.State(), "State mismatch")
+
+	// The stale result is ignored
+	notifier.MarkSuccess()
+	assert.Equal(t, StateOpen, breaker.State(), "State mismatch")
+}
+
+func TestThreeStateBreaker_DoAfterStop(t *testing.T) {
+	breaker := NewThreeStateBreaker(nil)
+	breaker.Stop()
+
+	err := breaker.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorRollingWindowStopped, "Unexpected error")
+}
+
+func TestThreeStateBreaker_DoCtx(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	conf := NewConfig().WithMinRequests(2).WithOpenTimeout(100 * time.Millisecond).WithHalfOpenProbes(1)
+	breaker := NewThreeStateBreaker(conf)
+	defer breaker.Stop()
+
+	// Context is already done, fail fast
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := breaker.DoCtx(ctx, func(ctx context.Context) error { return nil })
+	assert.ErrorIs(t, err, context.Canceled, "Unexpected error")
+
+	// Canceled executions are not counted as failures
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		err = breaker.DoCtx(ctx, func(ctx context.Context) error {
+			cancel()
+			return ctx.Err()
+		})
+		assert.ErrorIs(t, err, context.Canceled, "Unexpected error")
+	}
+	assert.Equal(t, StateClosed, breaker.State(), "State mismatch")
+
+	// Open the breaker
+	for i := 0; i < 2; i++ {
+		err = breaker.DoCtx(context.Background(), func(ctx context.Context) error { return execError })
+		assert.ErrorIs(t, err, execError, "Unexpected error")
+	}
+	assert.Equal(t, StateOpen, breaker.State(), "State mismatch")
+
+	// Wait for the open timeout
+	time.Sleep(150 * time.Millisecond)
+
+	// A canceled probe releases its slot
+	ctx, cancel = context.WithCancel(context.Background())
+	err = breaker.DoCtx(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled, "Unexpected error")
+	assert.Equal(t, StateHalfOpen, breaker.State(), "State mismatch")
+
+	// The next probe succeeds and closes the breaker
+	err = breaker.DoCtx(context.Background(), func(ctx context.Context) error { return nil })
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, StateClosed, breaker.State(), "State mismatch")
+}
+
+func TestThreeStateBreaker_WithClock(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	// create a new ThreeStateBreaker with a fake clock
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	conf := NewConfig().WithClock(clock).WithMinRequests(2).WithOpenTimeout(time.Minute).WithHalfOpenProbes(1)
+	breaker := NewThreeStateBreaker(conf)
+	defer breaker.Stop()
+
+	// Open the breaker
+	for i := 0; i < 2; i++ {
+		_ = breaker.Do(func() error { return execError })
+	}
+	assert.Equal(t, StateOpen, breaker.State(), "State mismatch")
+
+	// The breaker stays open before the open timeout
+	clock.Advance(time.Minute - time.Millisecond)
+	_, err := breaker.Allow()
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+
+	// The breaker becomes half-open after the open timeout and closes after the probe succeeds
+	clock.Advance(time.Millisecond)
+	err = breaker.Do(func() error { return nil })
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, StateClosed, breaker.State(), "State mismatch")
+}
+
+func TestThreeStateBreaker_PanicRecovery(t *testing.T) {
+	conf := NewConfig().WithMinRequests(2).WithFailureThreshold(0.5).WithPanicRecovery(true)
+	breaker := NewThreeStateBreaker(conf)
+	defer breaker.Stop()
+
+	// Panics are recovered and counted as failures, the breaker opens
+	for i := 0; i < 2; i++ {
+		err := breaker.Do(func() error { panic("boom") })
+		var pe *com.PanicError
+		assert.True(t, errors.As(err, &pe), "Expected panic error")
+	}
+	assert.Equal(t, StateOpen, breaker.State(), "State mismatch")
+}
+
+func TestThreeStateBreaker_HalfOpenPanic(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	conf := NewConfig().WithClock(clock).WithMinRequests(2).WithOpenTimeout(time.Minute).WithHalfOpenProbes(1)
+	breaker := NewThreeStateBreaker(conf)
+	defer breaker.Stop()
+
+	// Open the breaker
+	for i := 0; i < 2; i++ {
+		_ = breaker.Do(func() error { return execError })
+	}
+
+	// A probe panics without recovery, the panic is propagated and the probe counts as a failure
+	clock.Advance(time.Minute)
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = breaker.DoCtx(context.Background(), func(ctx context.Context) error { panic("boom") })
+	}, "Unexpected panic")
+	assert.Equal(t, StateOpen, breaker.State(), "State mismatch")
+
+	// The probe slot is returned, the next probe closes the breaker
+	clock.Advance(time.Minute)
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = breaker.Do(func() error { panic("boom") })
+	}, "Unexpected panic")
+	clock.Advance(time.Minute)
+	assert.NoError(t, breaker.Do(func() error { return nil }), "Unexpected error")
+	assert.Equal(t, StateClosed, breaker.State(), "State mismatch")
+}
+
+func TestThreeStateBreaker_Override(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	callback := &stateCallback{}
+	breaker := NewThreeStateBreaker(NewConfig().WithCallback(callback).WithClock(clock).WithMinRequests(2).WithOpenTimeout(time.Minute))
+	defer breaker.Stop()
+
+	var _ com.Overrider = breaker
+
+	// Test case 1: Forced open rejects all executions
+	breaker.ForceOpen(0)
+	assert.Equal(t, StateForcedOpen, breaker.State(), "State mismatch")
+	err := breaker.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorForcedOpen, "Unexpected error")
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+
+	// Test case 2: Forced closed allows all executions without opening the breaker
+	breaker.ForceClose(0)
+	for i := 0; i < 10; i++ {
+		assert.ErrorIs(t, breaker.Do(func() error { return execError }), execError, "Unexpected error")
+	}
+	assert.Equal(t, StateForcedClosed, breaker.State(), "State mismatch")
+
+	// Test case 3: Clearing the override returns to the automatic state
+	breaker.ClearOverride()
+	assert.Equal(t, StateClosed, breaker.State(), "State mismatch")
+
+	// Test case 4: The override expires
+	breaker.ForceOpen(time.Second)
+	clock.Advance(time.Second)
+	assert.Equal(t, StateClosed, breaker.State(), "State mismatch")
+
+	expected := []stateChange{
+		{StateClosed, StateForcedOpen},
+		{StateForcedOpen, StateForcedClosed},
+		{StateForcedClosed, StateClosed},
+		{StateClosed, StateForcedOpen},
+		{StateForcedOpen, StateClosed},
+	}
+	assert.Equal(t, expected, callback.Changes(), "State changes mismatch")
+}
@@ -1,6 +1,12 @@
 package common
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
 
 var (
 	// 定义服务不可用的错误
@@ -10,4 +16,104 @@ var (
 	// 滚动窗口停止的错误。
 	// Error when the rolling window is stopped.
 	ErrorRollingWindowStopped = errors.New("rolling window stopped")
+
+	// 熔断器组停止的错误。
+	// Error when the breaker group is stopped.
+	ErrorGroupStopped = errors.New("group stopped")
+
+	// 滚动窗口长度无效的错误。
+	// Error when the length of the rolling window is invalid.
+	ErrorInvalidWindow = errors.New("invalid rolling window")
+
+	// 滚动窗口插槽间隔无效的错误。
+	// Error when the slot interval of the rolling window is invalid.
+	ErrorInvalidSlotInterval = errors.New("invalid rolling window slot interval")
+
+	// 隔舱已满的错误，并发执行数和等待队列都已达到上限。
+	// Error when the bulkhead is full, both the concurrent executions and the wait queue reach the limit.
+	ErrorBulkheadFull = errors.New("bulkhead full")
+
+	// 隔舱停止的错误。
+	// Error when the bulkhead is stopped.
+	ErrorBulkheadStopped = errors.New("bulkhead stopped")
+
+	// 被限流的错误，令牌已经用完。
+	// Error when the execution is rate limited, the tokens run out.
+	ErrorRateLimited = errors.New("rate limited")
+
+	// 限流器停止的错误。
+	// Error when the rate limiter is stopped.
+	ErrorRateLimiterStopped = errors.New("rate limiter stopped")
+
+	// 超过并发限制的错误。
+	// Error when the concurrency limit is exceeded.
+	ErrorLimitExceeded = errors.New("concurrency limit exceeded")
+
+	// 熔断器被手动强制打开的错误，它包装了 ErrorServiceUnavailable。
+	// Error when the breaker is forced open manually, it wraps ErrorServiceUnavailable.
+	ErrorForcedOpen = fmt.Errorf("%w: forced open", ErrorServiceUnavailable)
+
+	// 熔断器不支持手动覆盖的错误。
+	// Error when the breaker does not support manual overrides.
+	ErrorOverrideUnsupported = errors.New("override not supported")
+
+	// 配置的值无效的错误。
+	// Error when a value of the configuration is invalid.
+	ErrorInvalidConfig = errors.New("invalid configuration")
 )
+
+// TimeoutError 是执行超过超时时间被放弃时返回的错误。
+// TimeoutError is the error returned when the execution is abandoned after the timeout.
+type TimeoutError struct {
+	Timeout time.Duration // 执行的超时时间 Timeout of the execution
+}
+
+// Error 返回错误的描述。
+// Error returns the description of the error.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("execution timed out after %s", e.Timeout)
+}
+
+// Unwrap 返回 context.DeadlineExceeded，使 errors.Is(err, context.DeadlineExceeded) 成立。
+// Unwrap returns context.DeadlineExceeded, so errors.Is(err, context.DeadlineExceeded) holds.
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// IsTimeout 检查错误是否是执行超时的错误。
+// IsTimeout checks if the error is an execution timeout error.
+func IsTimeout(err error) bool {
+	var te *TimeoutError
+	return errors.As(err, &te)
+}
+
+// PanicError 是执行中发生 panic 时返回的错误，包含 panic 的值和堆栈。
+// PanicError is the error returned when the execution panics, it carries the panic value and the stack trace.
+type PanicError struct {
+	Value any    // panic 的值 Value of the panic
+	Stack []byte // panic 时的堆栈 Stack trace at the panic
+}
+
+// NewPanicError 使用 panic 的值和当前协程的堆栈创建 PanicError，如果值已经是 PanicError，直接返回它。
+// NewPanicError creates a PanicError with the panic value and the stack of the current goroutine, returns the value itself if it is already a PanicError.
+func NewPanicError(value any) *PanicError {
+	if pe, ok := value.(*PanicError); ok {
+		return pe
+	}
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+// Error 返回错误的描述。
+// Error returns the description of the error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap 如果 panic 的值是错误，返回该错误。
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
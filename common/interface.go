@@ -1,5 +1,10 @@
 package common
 
+import (
+	"context"
+	"time"
+)
+
 type (
 	// AcceptableFunc 是一个检查错误是否可接受的函数。
 	// AcceptableFunc is a function that checks if the error is acceptable.
@@ -13,6 +18,10 @@ type (
 	// HandleFunc is a function that handles the execution.
 	HandleFunc = func() error
 
+	// HandleCtxFunc 是一个带上下文处理执行的函数。
+	// HandleCtxFunc is a function that handles the execution with a context.
+	HandleCtxFunc = func(ctx context.Context) error
+
 	// RetryableFunc 是一个处理重试逻辑的函数。
 	// RetryableFunc is a function that handles the retry logic.
 	RetryableFunc = func() (any, error)
@@ -29,6 +38,32 @@ type (
 		MarkFailure(reason error)
 	}
 
+	// Releaser 是可选的 Notifier 接口，释放允许执行时占用的资源，不记录任何结果。
+	// 当执行被放弃，既不算成功也不算失败时使用，例如组合熔断器中后面的策略拒绝了执行。
+	// Releaser is an optional Notifier interface, it releases the resources taken when the execution was allowed, without recording any result.
+	// It is used when the execution is abandoned and counts as neither success nor failure, e.g. a later policy of a composite breaker rejected the execution.
+	Releaser = interface {
+		// Release 释放允许执行时占用的资源。
+		// Release releases the resources taken when the execution was allowed.
+		Release()
+	}
+
+	// Overrider 是支持手动覆盖的熔断器接口，expiry 为 0 时覆盖一直有效，直到被清除。
+	// Overrider is the interface of a breaker supporting manual overrides, the override stays until cleared if expiry is 0.
+	Overrider = interface {
+		// ForceOpen 强制打开熔断器，拒绝所有执行。
+		// ForceOpen forces the breaker open, all executions are rejected.
+		ForceOpen(expiry time.Duration)
+
+		// ForceClose 强制关闭熔断器，允许所有执行。
+		// ForceClose forces the breaker closed, all executions are allowed.
+		ForceClose(expiry time.Duration)
+
+		// ClearOverride 清除手动覆盖，熔断器恢复自动判断。
+		// ClearOverride clears the manual override, the breaker decides automatically again.
+		ClearOverride()
+	}
+
 	// Breaker 是一个表示熔断器的接口。
 	// Breaker is an interface that represents a circuit breaker.
 	Breaker = interface {
@@ -52,6 +87,22 @@ type (
 		// DoWithFallbackAcceptable executes the function and returns the error.
 		DoWithFallbackAcceptable(fn HandleFunc, fallback FallbackFunc, acceptable AcceptableFunc) error
 
+		// DoCtx 使用上下文执行函数并返回错误。
+		// DoCtx executes the function with the context and returns the error.
+		DoCtx(ctx context.Context, fn HandleCtxFunc) error
+
+		// DoCtxWithAcceptable 使用上下文执行函数并返回错误。
+		// DoCtxWithAcceptable executes the function with the context and returns the error.
+		DoCtxWithAcceptable(ctx context.Context, fn HandleCtxFunc, acceptable AcceptableFunc) error
+
+		// DoCtxWithFallback 使用上下文执行函数并返回错误。
+		// DoCtxWithFallback executes the function with the context and returns the error.
+		DoCtxWithFallback(ctx context.Context, fn HandleCtxFunc, fallback FallbackFunc) error
+
+		// DoCtxWithFallbackAcceptable 使用上下文执行函数并返回错误。
+		// DoCtxWithFallbackAcceptable executes the function with the context and returns the error.
+		DoCtxWithFallbackAcceptable(ctx context.Context, fn HandleCtxFunc, fallback FallbackFunc, acceptable AcceptableFunc) error
+
 		// Stop 停止熔断器。
 		// Stop stops the circuit breaker.
 		Stop()
@@ -93,6 +144,18 @@ type (
 		Count() int64
 	}
 
+	// Clock 是提供当前时间的接口，可以在测试中替换为手动推进的时钟。
+	// Clock is an interface that provides the current time, it can be replaced by a manually advanced clock in tests.
+	Clock = interface {
+		// Now 返回当前时间。
+		// Now returns the current time.
+		Now() time.Time
+
+		// Since 返回自 t 以来经过的时间。
+		// Since returns the time elapsed since t.
+		Since(t time.Time) time.Duration
+	}
+
 	// Retry 是一个接口，定义了一个方法 TryOnConflictVal。
 	// Retry is an interface that defines a method TryOnConflictVal.
 	Retry = interface {
@@ -100,4 +163,12 @@ type (
 		// TryOnConflictVal executes the function and returns the retry result.
 		TryOnConflictVal(fn RetryableFunc) RetryResult
 	}
+
+	// RetryCtx 是可以被上下文中断的重试机制实现的可选接口，上下文结束时退避等待立即返回。
+	// RetryCtx is an optional interface implemented by retry mechanisms which can be interrupted by the context, the backoff wait returns as soon as the context is done.
+	RetryCtx = interface {
+		// TryOnConflictValCtx 执行函数并返回重试结果，上下文结束时停止重试。
+		// TryOnConflictValCtx executes the function and returns the retry result, retrying stops when the context is done.
+		TryOnConflictValCtx(ctx context.Context, fn RetryableFunc) RetryResult
+	}
 )
@@ -0,0 +1,258 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// RejectedError 是组合熔断器中的某个策略拒绝执行时返回的错误，包含策略的名称、位置和原始错误。
+// RejectedError is the error returned when a policy of the composite breaker rejects the execution, it carries the name and the position of the policy and the original error.
+type RejectedError struct {
+	Policy string // 拒绝执行的策略名称 Name of the policy which rejected the execution
+	Index  int    // 拒绝执行的策略位置 Position of the policy which rejected the execution
+	Err    error  // 策略返回的错误 Error returned by the policy
+}
+
+// Error 返回错误的描述。
+// Error returns the description of the error.
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("rejected by policy %q: %v", e.Policy, e.Err)
+}
+
+// Unwrap 返回策略返回的错误，使 errors.Is(err, com.ErrorServiceUnavailable) 等判断成立。
+// Unwrap returns the error of the policy, so checks such as errors.Is(err, com.ErrorServiceUnavailable) hold.
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// compositeNotifier 把执行结果通知给所有允许了执行的策略。
+// compositeNotifier notifies all policies which allowed the execution of the result.
+type compositeNotifier struct {
+	notifiers []com.Notifier
+}
+
+// MarkSuccess 通知所有策略执行成功。
+// MarkSuccess notifies all policies that the execution is successful.
+func (n *compositeNotifier) MarkSuccess() {
+	for _, notifier := range n.notifiers {
+		notifier.MarkSuccess()
+	}
+}
+
+// MarkFailure 通知所有策略执行失败。
+// MarkFailure notifies all policies that the execution is failed.
+func (n *compositeNotifier) MarkFailure(reason error) {
+	for _, notifier := range n.notifiers {
+		notifier.MarkFailure(reason)
+	}
+}
+
+// Release 释放所有策略占用的资源，不记录结果。
+// Release releases the resources taken by all policies, without recording the result.
+func (n *compositeNotifier) Release() {
+	for i := len(n.notifiers) - 1; i >= 0; i-- {
+		if r, ok := n.notifiers[i].(com.Releaser); ok {
+			r.Release()
+		}
+	}
+}
+
+// markPanic 在函数发生 panic 时把失败通知给所有允许了执行的策略，释放它们占用的资源，然后继续 panic。
+// markPanic notifies all policies which allowed the execution of the failure when the function panics, so their resources are released, and then panics again.
+func markPanic(notifier com.Notifier) {
+	if v := recover(); v != nil {
+		notifier.MarkFailure(com.NewPanicError(v))
+		panic(v)
+	}
+}
+
+// Composite 是按顺序组合多个策略的熔断器，例如限流器、隔舱和 GoogleBreaker。
+// 只有所有策略都允许时才执行，执行结果通知给所有策略。
+// Composite is a breaker which chains multiple policies in order, e.g. a rate limiter, a bulkhead and a GoogleBreaker.
+// The execution happens only if all policies allow it, and the result is notified to all policies.
+type Composite struct {
+	config *Config   // 组合熔断器的配置 Config of the composite breaker
+	once   sync.Once // 用于确保只停止一次 The sync.Once to ensure stopping only once
+}
+
+// NewComposite 返回一个新的组合熔断器。
+// NewComposite returns a new composite breaker.
+func NewComposite(conf *Config) *Composite {
+	return &Composite{config: isConfigValid(conf)}
+}
+
+// Stop 按相反的顺序停止所有策略。
+// Stop stops all policies in reverse order.
+func (c *Composite) Stop() {
+	c.once.Do(func() {
+		for i := len(c.config.policies) - 1; i >= 0; i-- {
+			c.config.policies[i].breaker.Stop()
+		}
+	})
+}
+
+// ForceOpen 强制打开所有支持手动覆盖的策略，expiry 为 0 时一直有效，直到被清除。
+// ForceOpen forces all policies supporting manual overrides open, it stays until cleared if expiry is 0.
+func (c *Composite) ForceOpen(expiry time.Duration) {
+	c.override(func(o com.Overrider) { o.ForceOpen(expiry) })
+}
+
+// ForceClose 强制关闭所有支持手动覆盖的策略，expiry 为 0 时一直有效，直到被清除。不支持手动覆盖的策略，例如隔舱，仍然可能拒绝执行。
+// ForceClose forces all policies supporting manual overrides closed, it stays until cleared if expiry is 0. Policies without manual overrides, e.g. a bulkhead, may still reject executions.
+func (c *Composite) ForceClose(expiry time.Duration) {
+	c.override(func(o com.Overrider) { o.ForceClose(expiry) })
+}
+
+// ClearOverride 清除所有支持手动覆盖的策略的覆盖。
+// ClearOverride clears the overrides of all policies supporting manual overrides.
+func (c *Composite) ClearOverride() {
+	c.override(func(o com.Overrider) { o.ClearOverride() })
+}
+
+// override 把手动覆盖的操作转发给所有实现了 Overrider 的策略。
+// override forwards the manual override operation to all policies implementing Overrider.
+func (c *Composite) override(fn func(o com.Overrider)) {
+	for _, p := range c.config.policies {
+		if o, ok := p.breaker.(com.Overrider); ok {
+			fn(o)
+		}
+	}
+}
+
+// Allow 按顺序检查所有策略，如果某个策略拒绝，释放前面的策略占用的资源，并返回 RejectedError。
+// Allow checks all policies in order, if a policy rejects, the resources taken by the previous policies are released and a RejectedError is returned.
+func (c *Composite) Allow() (com.Notifier, error) {
+	n := &compositeNotifier{notifiers: make([]com.Notifier, 0, len(c.config.policies))}
+	for i, p := range c.config.policies {
+		notifier, err := p.breaker.Allow()
+		if err != nil {
+			n.Release()
+			return nil, &RejectedError{Policy: p.name, Index: i, Err: err}
+		}
+		n.notifiers = append(n.notifiers, notifier)
+	}
+	return n, nil
+}
+
+// do 在所有策略允许后执行函数。
+// do executes the function after all policies allow it.
+func (c *Composite) do(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 如果某个策略拒绝执行，执行回退函数或返回错误。
+	// If a policy rejects the execution, execute the fallback function or return the error.
+	notifier, err := c.Allow()
+	if err != nil {
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+
+	// 执行函数，发生 panic 时也要通知所有策略
+	// Execute the function, notify all policies even if it panics
+	defer markPanic(notifier)
+	err = fn()
+
+	// 如果错误可接受，标记执行成功，否则标记执行失败并返回错误。
+	// If the error is acceptable, mark the execution as successful, otherwise mark the execution as failed and return the error.
+	if acceptable(err) {
+		notifier.MarkSuccess()
+		return nil
+	}
+	notifier.MarkFailure(err)
+	return err
+}
+
+// Do 执行函数并返回错误。
+// Do executes the function and returns the error.
+func (c *Composite) Do(fn com.HandleFunc) error {
+	return c.do(fn, nil, cb.DefaultAcceptableFunc)
+}
+
+// DoWithAcceptable 使用给定的可接受函数执行函数并返回错误。
+// DoWithAcceptable executes the function with the given acceptable function and returns the error.
+func (c *Composite) DoWithAcceptable(fn com.HandleFunc, acceptable com.AcceptableFunc) error {
+	return c.do(fn, nil, acceptable)
+}
+
+// DoWithFallback 使用给定的回退函数执行函数并返回错误。
+// DoWithFallback executes the function with the given fallback function and returns the error.
+func (c *Composite) DoWithFallback(fn com.HandleFunc, fallback com.FallbackFunc) error {
+	return c.do(fn, fallback, cb.DefaultAcceptableFunc)
+}
+
+// DoWithFallbackAcceptable 使用给定的回退和可接受函数执行函数并返回错误。
+// DoWithFallbackAcceptable executes the function with the given fallback and acceptable functions and returns the error.
+func (c *Composite) DoWithFallbackAcceptable(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return c.do(fn, fallback, acceptable)
+}
+
+// doCtx 在所有策略允许后使用上下文执行函数。
+// doCtx executes the function with the context after all policies allow it.
+func (c *Composite) doCtx(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 如果上下文已经结束，快速失败，不计入统计。
+	// If the context is already done, fail fast without recording the statistics.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 如果某个策略拒绝执行，执行回退函数或返回错误。
+	// If a policy rejects the execution, execute the fallback function or return the error.
+	notifier, err := c.Allow()
+	if err != nil {
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+
+	// 执行函数，发生 panic 时也要通知所有策略
+	// Execute the function, notify all policies even if it panics
+	defer markPanic(notifier)
+	err = fn(ctx)
+
+	// 调用方取消了上下文，不计入成功或失败，但要释放策略占用的资源。
+	// The caller canceled the context, it is counted as neither success nor failure, but the resources taken by the policies are released.
+	if errors.Is(err, context.Canceled) || (err != nil && errors.Is(ctx.Err(), context.Canceled)) {
+		notifier.(*compositeNotifier).Release()
+		return err
+	}
+
+	// 如果错误可接受，标记执行成功，否则标记执行失败并返回错误。
+	// If the error is acceptable, mark the execution as successful, otherwise mark the execution as failed and return the error.
+	if acceptable(err) {
+		notifier.MarkSuccess()
+		return nil
+	}
+	notifier.MarkFailure(err)
+	return err
+}
+
+// DoCtx 使用上下文执行函数并返回错误。
+// DoCtx executes the function with the context and returns the error.
+func (c *Composite) DoCtx(ctx context.Context, fn com.HandleCtxFunc) error {
+	return c.doCtx(ctx, fn, nil, cb.DefaultAcceptableFunc)
+}
+
+// DoCtxWithAcceptable 使用上下文和给定的可接受函数执行函数并返回错误。
+// DoCtxWithAcceptable executes the function with the context and the given acceptable function and returns the error.
+func (c *Composite) DoCtxWithAcceptable(ctx context.Context, fn com.HandleCtxFunc, acceptable com.AcceptableFunc) error {
+	return c.doCtx(ctx, fn, nil, acceptable)
+}
+
+// DoCtxWithFallback 使用上下文和给定的回退函数执行函数并返回错误。
+// DoCtxWithFallback executes the function with the context and the given fallback function and returns the error.
+func (c *Composite) DoCtxWithFallback(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc) error {
+	return c.doCtx(ctx, fn, fallback, cb.DefaultAcceptableFunc)
+}
+
+// DoCtxWithFallbackAcceptable 使用上下文和给定的回退和可接受函数执行函数并返回错误。
+// DoCtxWithFallbackAcceptable executes the function with the context and the given fallback and acceptable functions and returns the error.
+func (c *Composite) DoCtxWithFallbackAcceptable(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return c.doCtx(ctx, fn, fallback, acceptable)
+}
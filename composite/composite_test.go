@@ -0,0 +1,155 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shengyanli1982/tripwire/bulkhead"
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type countCallback struct {
+	cb.Callback
+	successes, failures int
+}
+
+func (c *countCallback) OnSuccess(opterr error) {
+	c.successes++
+}
+
+func (c *countCallback) OnFailure(opterr, reason error) {
+	c.failures++
+}
+
+func TestComposite_FanOut(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	first := &countCallback{Callback: cb.NewEmptyCallback()}
+	second := &countCallback{Callback: cb.NewEmptyCallback()}
+	breaker := NewComposite(NewConfig().
+		WithPolicy("first", cb.NewThreeStateBreaker(cb.NewConfig().WithCallback(first))).
+		WithPolicy("second", cb.NewThreeStateBreaker(cb.NewConfig().WithCallback(second))))
+	defer breaker.Stop()
+
+	// Test case 1: Success is notified to all policies
+	err := breaker.Do(func() error { return nil })
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 1, first.successes, "Unexpected success count")
+	assert.Equal(t, 1, second.successes, "Unexpected success count")
+
+	// Test case 2: Failure is notified to all policies
+	err = breaker.DoCtx(context.Background(), func(ctx context.Context) error { return execError })
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+	assert.Equal(t, 1, first.failures, "Unexpected failure count")
+	assert.Equal(t, 1, second.failures, "Unexpected failure count")
+}
+
+func TestComposite_Rejected(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	bh := bulkhead.NewBulkhead(bulkhead.NewConfig().WithMaxConcurrent(1))
+	inner := cb.NewThreeStateBreaker(cb.NewConfig().WithMinRequests(1).WithFailureThreshold(0.5))
+	breaker := NewComposite(NewConfig().WithPolicy("bulkhead", bh).WithPolicy("breaker", inner))
+	defer breaker.Stop()
+
+	// Open the inner breaker
+	err := breaker.Do(func() error { return execError })
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+	assert.Equal(t, cb.StateOpen, inner.State(), "State mismatch")
+
+	// Test case 1: The rejecting policy is reported
+	err = breaker.DoWithFallback(func() error { return nil }, func(err error) error {
+		var rejected *RejectedError
+		assert.True(t, errors.As(err, &rejected), "Expected rejected error")
+		assert.Equal(t, "breaker", rejected.Policy, "Unexpected policy")
+		assert.Equal(t, 1, rejected.Index, "Unexpected policy index")
+		return err
+	})
+	assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+
+	// Test case 2: The slot of the bulkhead admitted before the rejection is released
+	assert.Equal(t, 0, bh.InFlight(), "Unexpected in flight count")
+
+	// Test case 3: The first policy rejects
+	notifier, err := bh.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	err = breaker.Do(func() error { return nil })
+	assert.EqualError(t, err, `rejected by policy "bulkhead": bulkhead full`, "Unexpected error")
+	assert.ErrorIs(t, err, com.ErrorBulkheadFull, "Unexpected error")
+	notifier.MarkSuccess()
+}
+
+func TestComposite_Canceled(t *testing.T) {
+	bh := bulkhead.NewBulkhead(bulkhead.NewConfig().WithMaxConcurrent(1))
+	breaker := NewComposite(NewConfig().WithPolicy("bulkhead", bh))
+	defer breaker.Stop()
+
+	// The caller cancels the context, the slot is released without a result
+	ctx, cancel := context.WithCancel(context.Background())
+	err := breaker.DoCtx(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled, "Unexpected error")
+	assert.Equal(t, 0, bh.InFlight(), "Unexpected in flight count")
+}
+
+func TestComposite_Panic(t *testing.T) {
+	bh := bulkhead.NewBulkhead(bulkhead.NewConfig().WithMaxConcurrent(1))
+	callback := &countCallback{Callback: cb.NewEmptyCallback()}
+	breaker := NewComposite(NewConfig().
+		WithPolicy("bulkhead", bh).
+		WithPolicy("breaker", cb.NewThreeStateBreaker(cb.NewConfig().WithCallback(callback))))
+	defer breaker.Stop()
+
+	// The panic is propagated, the slot is released and the failure is recorded
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = breaker.Do(func() error { panic("boom") })
+	}, "Unexpected panic")
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = breaker.DoCtx(context.Background(), func(ctx context.Context) error { panic("boom") })
+	}, "Unexpected panic")
+	assert.Equal(t, 0, bh.InFlight(), "Unexpected in flight count")
+	assert.Equal(t, 2, callback.failures, "Unexpected failure count")
+
+	// The composite still allows executions
+	notifier, err := breaker.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	notifier.MarkSuccess()
+}
+
+func TestComposite_Override(t *testing.T) {
+	google := cb.NewGoogleBreaker(cb.NewConfig())
+	three := cb.NewThreeStateBreaker(cb.NewConfig())
+	breaker := NewComposite(NewConfig().
+		WithPolicy("bulkhead", bulkhead.NewBulkhead(bulkhead.NewConfig())).
+		WithPolicy("google", google).
+		WithPolicy("three", three))
+	defer breaker.Stop()
+
+	// The override is forwarded to the policies supporting it
+	breaker.ForceOpen(0)
+	assert.Equal(t, cb.StateForcedOpen, google.State(), "State mismatch")
+	assert.Equal(t, cb.StateForcedOpen, three.State(), "State mismatch")
+	err := breaker.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorForcedOpen, "Unexpected error")
+
+	breaker.ForceClose(0)
+	assert.Equal(t, cb.StateForcedClosed, three.State(), "State mismatch")
+	assert.NoError(t, breaker.Do(func() error { return nil }), "Unexpected error")
+
+	breaker.ClearOverride()
+	assert.Equal(t, cb.StateHealthy, google.State(), "State mismatch")
+	assert.Equal(t, cb.StateClosed, three.State(), "State mismatch")
+}
+
+func TestComposite_Empty(t *testing.T) {
+	breaker := NewComposite(NewConfig().WithPolicy("nil", nil))
+	defer breaker.Stop()
+
+	err := breaker.Do(func() error { return nil })
+	assert.NoError(t, err, "Unexpected error")
+}
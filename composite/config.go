@@ -0,0 +1,55 @@
+package composite
+
+import (
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// policy 是组合熔断器中的一个策略。
+// policy is a policy of the composite breaker.
+type policy struct {
+	name    string
+	breaker com.Breaker
+}
+
+// Config 是组合熔断器的配置。
+// Config is the configuration for the composite breaker.
+type Config struct {
+	policies []policy
+}
+
+// NewConfig 返回组合熔断器的新配置。
+// NewConfig returns a new configuration for the composite breaker.
+func NewConfig() *Config {
+	return &Config{}
+}
+
+// DefaultConfig 返回组合熔断器的默认配置，没有任何策略，所有执行都被允许。
+// DefaultConfig returns the default configuration for the composite breaker, without any policy all executions are allowed.
+func DefaultConfig() *Config {
+	return NewConfig()
+}
+
+// WithPolicy 在策略列表的末尾添加一个策略，策略按添加的顺序被检查，名称用于报告拒绝执行的策略。
+// WithPolicy appends a policy to the list of policies, the policies are checked in the order they are added, the name is used to report the policy which rejected the execution.
+func (c *Config) WithPolicy(name string, breaker com.Breaker) *Config {
+	c.policies = append(c.policies, policy{name: name, breaker: breaker})
+	return c
+}
+
+// isConfigValid 检查配置是否有效，移除没有熔断器的策略。
+// isConfigValid checks if the configuration is valid, and removes the policies without a breaker.
+func isConfigValid(conf *Config) *Config {
+	if conf != nil {
+		policies := make([]policy, 0, len(conf.policies))
+		for _, p := range conf.policies {
+			if p.breaker != nil {
+				policies = append(policies, p)
+			}
+		}
+		conf.policies = policies
+	} else {
+		conf = DefaultConfig()
+	}
+
+	return conf
+}
@@ -1,6 +1,8 @@
 package tripwire
 
 import (
+	"time"
+
 	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
 	com "github.com/shengyanli1982/tripwire/common"
 )
@@ -8,8 +10,9 @@ import (
 // 定义配置结构体
 // Define the Config struct
 type Config struct {
-	retry   com.Retry   // 重试策略 Retry strategy
-	breaker com.Breaker // 断路器 Circuit breaker
+	retry   com.Retry     // 重试策略 Retry strategy
+	breaker com.Breaker   // 断路器 Circuit breaker
+	timeout time.Duration // 每次执行的超时时间，0 表示不限制 Timeout of each execution, 0 means no limit
 }
 
 // NewConfig 函数创建并返回一个新的配置实例
@@ -40,6 +43,13 @@ func (c *Config) WithBreaker(breaker com.Breaker) *Config {
 	return c
 }
 
+// WithTimeout 方法设置每次执行的超时时间，超时的执行被放弃并计为失败，0 表示不限制，并返回配置本身
+// The WithTimeout method sets the timeout of each execution, a timed out execution is abandoned and counted as a failure, 0 means no limit, and returns the Config itself
+func (c *Config) WithTimeout(timeout time.Duration) *Config {
+	c.timeout = timeout
+	return c
+}
+
 // isConfigValid 函数检查配置是否有效，如果无效则使用默认值，最后返回配置
 // The isConfigValid function checks whether the Config is valid, uses default values if invalid, and finally returns the Config
 func isConfigValid(conf *Config) *Config {
@@ -55,6 +65,12 @@ func isConfigValid(conf *Config) *Config {
 		if conf.breaker == nil {
 			conf.breaker = cb.NewGoogleBreaker(cb.DefaultConfig())
 		}
+
+		// 如果超时时间为负数，则不限制超时
+		// If the timeout is negative, do not limit the timeout
+		if conf.timeout < 0 {
+			conf.timeout = 0
+		}
 	} else {
 		// 如果配置为空，则创建一个新的配置
 		// If the Config is nil, create a new Config
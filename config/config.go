@@ -0,0 +1,321 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	tp "github.com/shengyanli1982/tripwire"
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+const (
+	// BreakerGoogle 是 GoogleBreaker 的类型名称，也是默认的类型。
+	// BreakerGoogle is the type name of the GoogleBreaker, it is also the default type.
+	BreakerGoogle = "google"
+
+	// BreakerThreeState 是 ThreeStateBreaker 的类型名称。
+	// BreakerThreeState is the type name of the ThreeStateBreaker.
+	BreakerThreeState = "three-state"
+)
+
+// windowModes 是窗口模式的名称。
+// windowModes are the names of the window modes.
+var windowModes = map[string]cb.WindowMode{
+	"":       cb.WindowModeMutex,
+	"mutex":  cb.WindowModeMutex,
+	"atomic": cb.WindowModeAtomic,
+}
+
+// jitters 是抖动策略的名称。
+// jitters are the names of the jitter strategies.
+var jitters = map[string]tp.Jitter{
+	"":             tp.JitterFull,
+	"none":         tp.JitterNone,
+	"full":         tp.JitterFull,
+	"equal":        tp.JitterEqual,
+	"decorrelated": tp.JitterDecorrelated,
+}
+
+// invalid 返回一个包装了 ErrorInvalidConfig 的错误。
+// invalid returns an error wrapping ErrorInvalidConfig.
+func invalid(field string, format string, args ...any) error {
+	return fmt.Errorf("%w: %s %s", com.ErrorInvalidConfig, field, fmt.Sprintf(format, args...))
+}
+
+// Document 是描述多个命名熔断器的配置文档。
+// Document is a configuration document describing many named breakers.
+type Document struct {
+	Breakers map[string]*BreakerSpec `json:"breakers" yaml:"breakers"`
+}
+
+// Validate 检查文档中的所有熔断器，返回第一个无效值的错误。
+// Validate checks all breakers in the document and returns the error of the first invalid value.
+func (d *Document) Validate() error {
+	for _, name := range d.Names() {
+		spec := d.Breakers[name]
+		if spec == nil {
+			return fmt.Errorf("breaker %q: %w: empty breaker", name, com.ErrorInvalidConfig)
+		}
+		if err := spec.Validate(); err != nil {
+			return fmt.Errorf("breaker %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Names 返回按名称排序的熔断器名称列表。
+// Names returns the breaker names sorted by name.
+func (d *Document) Names() []string {
+	names := make([]string, 0, len(d.Breakers))
+	for name := range d.Breakers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build 创建文档中的所有熔断器，任何一个无效时停止已经创建的熔断器并返回错误。
+// Build creates all breakers in the document, stops the created breakers and returns an error if any of them is invalid.
+func (d *Document) Build() (map[string]*tp.CircuitBreaker, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	breakers := make(map[string]*tp.CircuitBreaker, len(d.Breakers))
+	for _, name := range d.Names() {
+		breaker, err := d.Breakers[name].Build()
+		if err != nil {
+			for _, b := range breakers {
+				b.Stop()
+			}
+			return nil, fmt.Errorf("breaker %q: %w", name, err)
+		}
+		breakers[name] = breaker
+	}
+	return breakers, nil
+}
+
+// BreakerSpec 是一个熔断器的可序列化配置，零值表示使用默认值。
+// BreakerSpec is the serializable configuration of one breaker, zero values mean the defaults.
+type BreakerSpec struct {
+	Type              string     `json:"type,omitempty" yaml:"type,omitempty"`                           // google 或 three-state google or three-state
+	K                 float64    `json:"k,omitempty" yaml:"k,omitempty"`                                 // GoogleBreaker 的 K 值 K value of the GoogleBreaker
+	Protected         *int       `json:"protected,omitempty" yaml:"protected,omitempty"`                 // GoogleBreaker 的保护值 Protected value of the GoogleBreaker
+	StateWindow       int        `json:"stateWindow,omitempty" yaml:"stateWindow,omitempty"`             // 滚动窗口的秒数 Seconds of the rolling window
+	Window            Duration   `json:"window,omitempty" yaml:"window,omitempty"`                       // 滚动窗口的时长 Duration of the rolling window
+	SlotInterval      Duration   `json:"slotInterval,omitempty" yaml:"slotInterval,omitempty"`           // 滚动窗口的插槽间隔 Slot interval of the rolling window
+	WindowMode        string     `json:"windowMode,omitempty" yaml:"windowMode,omitempty"`               // mutex 或 atomic mutex or atomic
+	FailureThreshold  float64    `json:"failureThreshold,omitempty" yaml:"failureThreshold,omitempty"`   // 三态熔断器的失败率阈值 Failure ratio threshold of the three-state breaker
+	MinRequests       int        `json:"minRequests,omitempty" yaml:"minRequests,omitempty"`             // 三态熔断器的最小请求数 Min requests of the three-state breaker
+	OpenTimeout       Duration   `json:"openTimeout,omitempty" yaml:"openTimeout,omitempty"`             // 三态熔断器的打开时长 Open timeout of the three-state breaker
+	HalfOpenProbes    int        `json:"halfOpenProbes,omitempty" yaml:"halfOpenProbes,omitempty"`       // 三态熔断器的探测请求数 Probes of the three-state breaker
+	StateDebounce     *Duration  `json:"stateDebounce,omitempty" yaml:"stateDebounce,omitempty"`         // 状态变化的去抖动时间 Debounce time of state changes
+	RejectingRatio    float64    `json:"rejectingRatio,omitempty" yaml:"rejectingRatio,omitempty"`       // 拒绝状态的熔断比率阈值 Fuse ratio threshold of the rejecting state
+	SlowCallThreshold Duration   `json:"slowCallThreshold,omitempty" yaml:"slowCallThreshold,omitempty"` // 慢调用阈值 Slow call threshold
+	SlowCallWeight    float64    `json:"slowCallWeight,omitempty" yaml:"slowCallWeight,omitempty"`       // 慢调用的失败权重 Failure weight of slow calls
+	LatencyHistogram  bool       `json:"latencyHistogram,omitempty" yaml:"latencyHistogram,omitempty"`   // 是否启用延迟直方图 Whether the latency histogram is enabled
+	PanicRecovery     bool       `json:"panicRecovery,omitempty" yaml:"panicRecovery,omitempty"`         // 是否恢复 panic Whether panics are recovered
+	Timeout           Duration   `json:"timeout,omitempty" yaml:"timeout,omitempty"`                     // 每次执行的超时时间 Timeout of each execution
+	Retry             *RetrySpec `json:"retry,omitempty" yaml:"retry,omitempty"`                         // 重试策略，为空时不重试 Retry policy, no retry if empty
+}
+
+// Validate 检查熔断器的配置，返回第一个无效值的错误。
+// Validate checks the configuration of the breaker and returns the error of the first invalid value.
+func (s *BreakerSpec) Validate() error {
+	switch {
+	case s.Type != "" && s.Type != BreakerGoogle && s.Type != BreakerThreeState:
+		return invalid("type", "must be %q or %q, got %q", BreakerGoogle, BreakerThreeState, s.Type)
+	case s.K != 0 && (s.K < 1 || s.K >= 5):
+		return invalid("k", "must be in [1, 5), got %v", s.K)
+	case s.Protected != nil && *s.Protected < 0:
+		return invalid("protected", "must not be negative, got %d", *s.Protected)
+	case s.StateWindow < 0:
+		return invalid("stateWindow", "must not be negative, got %d", s.StateWindow)
+	case s.Window < 0:
+		return invalid("window", "must not be negative, got %s", s.Window)
+	case s.SlotInterval < 0:
+		return invalid("slotInterval", "must not be negative, got %s", s.SlotInterval)
+	case s.FailureThreshold < 0 || s.FailureThreshold > 1:
+		return invalid("failureThreshold", "must be in (0, 1], got %v", s.FailureThreshold)
+	case s.MinRequests < 0:
+		return invalid("minRequests", "must not be negative, got %d", s.MinRequests)
+	case s.OpenTimeout < 0:
+		return invalid("openTimeout", "must not be negative, got %s", s.OpenTimeout)
+	case s.HalfOpenProbes < 0:
+		return invalid("halfOpenProbes", "must not be negative, got %d", s.HalfOpenProbes)
+	case s.StateDebounce != nil && *s.StateDebounce < 0:
+		return invalid("stateDebounce", "must not be negative, got %s", *s.StateDebounce)
+	case s.RejectingRatio < 0 || s.RejectingRatio > 1:
+		return invalid("rejectingRatio", "must be in (0, 1], got %v", s.RejectingRatio)
+	case s.SlowCallThreshold < 0:
+		return invalid("slowCallThreshold", "must not be negative, got %s", s.SlowCallThreshold)
+	case s.SlowCallWeight < 0 || s.SlowCallWeight > 1:
+		return invalid("slowCallWeight", "must be in (0, 1], got %v", s.SlowCallWeight)
+	case s.Timeout < 0:
+		return invalid("timeout", "must not be negative, got %s", s.Timeout)
+	}
+	if _, ok := windowModes[s.WindowMode]; !ok {
+		return invalid("windowMode", "must be %q or %q, got %q", "mutex", "atomic", s.WindowMode)
+	}
+	if err := s.breakerConfig().Validate(); err != nil {
+		return fmt.Errorf("window: %w", err)
+	}
+	if s.Retry != nil {
+		if err := s.Retry.Validate(); err != nil {
+			return fmt.Errorf("retry: %w", err)
+		}
+	}
+	return nil
+}
+
+// breakerConfig 把配置转换为熔断器的配置，不检查值是否有效。
+// breakerConfig converts the configuration to the breaker configuration without checking the values.
+func (s *BreakerSpec) breakerConfig() *cb.Config {
+	conf := cb.NewConfig().
+		WithWindow(dur(s.Window)).
+		WithSlotInterval(dur(s.SlotInterval)).
+		WithWindowMode(windowModes[s.WindowMode]).
+		WithSlowCallThreshold(dur(s.SlowCallThreshold)).
+		WithLatencyHistogram(s.LatencyHistogram).
+		WithPanicRecovery(s.PanicRecovery)
+
+	if s.K != 0 {
+		conf.WithK(s.K)
+	}
+	if s.Protected != nil {
+		conf.WithProtected(*s.Protected)
+	}
+	if s.StateWindow != 0 {
+		conf.WithStateWindow(s.StateWindow)
+	}
+	if s.FailureThreshold != 0 {
+		conf.WithFailureThreshold(s.FailureThreshold)
+	}
+	if s.MinRequests != 0 {
+		conf.WithMinRequests(s.MinRequests)
+	}
+	if s.OpenTimeout != 0 {
+		conf.WithOpenTimeout(dur(s.OpenTimeout))
+	}
+	if s.HalfOpenProbes != 0 {
+		conf.WithHalfOpenProbes(s.HalfOpenProbes)
+	}
+	if s.StateDebounce != nil {
+		conf.WithStateDebounce(dur(*s.StateDebounce))
+	}
+	if s.RejectingRatio != 0 {
+		conf.WithRejectingRatio(s.RejectingRatio)
+	}
+	if s.SlowCallWeight != 0 {
+		conf.WithSlowCallWeight(s.SlowCallWeight)
+	}
+	return conf
+}
+
+// BreakerConfig 检查配置并把它转换为熔断器的配置。
+// BreakerConfig checks the configuration and converts it to the breaker configuration.
+func (s *BreakerSpec) BreakerConfig() (*cb.Config, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s.breakerConfig(), nil
+}
+
+// Build 检查配置并创建熔断器，熔断器使用配置的类型、重试策略和超时时间。
+// Build checks the configuration and creates the circuit breaker with the configured type, retry policy and timeout.
+func (s *BreakerSpec) Build() (*tp.CircuitBreaker, error) {
+	conf, err := s.BreakerConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var breaker com.Breaker
+	if s.Type == BreakerThreeState {
+		breaker = cb.NewThreeStateBreaker(conf)
+	} else {
+		breaker = cb.NewGoogleBreaker(conf)
+	}
+
+	retry := tp.NewEmptyRetry()
+	if s.Retry != nil {
+		retry = tp.NewBackoffRetry(s.Retry.retryConfig())
+	}
+
+	return tp.New(tp.NewConfig().WithBreaker(breaker).WithRetry(retry).WithTimeout(dur(s.Timeout))), nil
+}
+
+// RetrySpec 是退避重试的可序列化配置，零值表示使用默认值。
+// RetrySpec is the serializable configuration of the backoff retry, zero values mean the defaults.
+type RetrySpec struct {
+	Attempts        int      `json:"attempts,omitempty" yaml:"attempts,omitempty"`               // 最大尝试次数 Max attempts
+	InitialInterval Duration `json:"initialInterval,omitempty" yaml:"initialInterval,omitempty"` // 初始退避间隔 Initial backoff interval
+	MaxInterval     Duration `json:"maxInterval,omitempty" yaml:"maxInterval,omitempty"`         // 最大退避间隔 Max backoff interval
+	Multiplier      float64  `json:"multiplier,omitempty" yaml:"multiplier,omitempty"`           // 退避间隔倍数 Backoff interval multiplier
+	Jitter          string   `json:"jitter,omitempty" yaml:"jitter,omitempty"`                   // none、full、equal 或 decorrelated none, full, equal or decorrelated
+}
+
+// Validate 检查重试策略的配置，返回第一个无效值的错误。
+// Validate checks the configuration of the retry policy and returns the error of the first invalid value.
+func (s *RetrySpec) Validate() error {
+	switch {
+	case s.Attempts < 0:
+		return invalid("attempts", "must not be negative, got %d", s.Attempts)
+	case s.InitialInterval < 0:
+		return invalid("initialInterval", "must not be negative, got %s", s.InitialInterval)
+	case s.MaxInterval < 0:
+		return invalid("maxInterval", "must not be negative, got %s", s.MaxInterval)
+	case s.MaxInterval != 0 && dur(s.MaxInterval) < s.initialInterval():
+		return invalid("maxInterval", "must not be less than the initial interval %s, got %s", s.initialInterval(), s.MaxInterval)
+	case s.Multiplier != 0 && s.Multiplier < 1:
+		return invalid("multiplier", "must not be less than 1, got %v", s.Multiplier)
+	}
+	if _, ok := jitters[s.Jitter]; !ok {
+		return invalid("jitter", "must be %q, %q, %q or %q, got %q", "none", "full", "equal", "decorrelated", s.Jitter)
+	}
+	return nil
+}
+
+// initialInterval 返回生效的初始退避间隔。
+// initialInterval returns the effective initial backoff interval.
+func (s *RetrySpec) initialInterval() time.Duration {
+	if s.InitialInterval == 0 {
+		return tp.DefaultRetryInitialInterval
+	}
+	return dur(s.InitialInterval)
+}
+
+// retryConfig 把配置转换为退避重试的配置，不检查值是否有效。
+// retryConfig converts the configuration to the backoff retry configuration without checking the values.
+func (s *RetrySpec) retryConfig() *tp.RetryConfig {
+	conf := tp.NewRetryConfig().WithJitter(jitters[s.Jitter])
+	if s.Attempts != 0 {
+		conf.WithAttempts(s.Attempts)
+	}
+	if s.InitialInterval != 0 {
+		conf.WithInitialInterval(dur(s.InitialInterval))
+	}
+	if s.MaxInterval != 0 {
+		conf.WithMaxInterval(dur(s.MaxInterval))
+	}
+	if s.Multiplier != 0 {
+		conf.WithMultiplier(s.Multiplier)
+	}
+	return conf
+}
+
+// RetryConfig 检查配置并把它转换为退避重试的配置。
+// RetryConfig checks the configuration and converts it to the backoff retry configuration.
+func (s *RetrySpec) RetryConfig() (*tp.RetryConfig, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s.retryConfig(), nil
+}
+
+// dur 把 Duration 转换为 time.Duration。
+// dur converts a Duration to a time.Duration.
+func dur(d Duration) time.Duration {
+	return time.Duration(d)
+}
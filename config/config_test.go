@@ -0,0 +1,230 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/stretchr/testify/assert"
+)
+
+const testJSON = `{
+	"breakers": {
+		"api": {
+			"k": 2,
+			"protected": 0,
+			"window": "10s",
+			"slotInterval": "500ms",
+			"windowMode": "atomic",
+			"stateDebounce": 0,
+			"timeout": "2s",
+			"retry": {"attempts": 5, "initialInterval": "10ms", "maxInterval": "1s", "jitter": "equal"}
+		},
+		"db": {
+			"type": "three-state",
+			"failureThreshold": 0.3,
+			"minRequests": 20,
+			"openTimeout": "30s"
+		}
+	}
+}`
+
+const testYAML = `
+breakers:
+  api:
+    k: 2
+    protected: 0
+    window: 10s
+    slotInterval: 500ms
+    windowMode: atomic
+    stateDebounce: 0
+    timeout: 2s
+    retry:
+      attempts: 5
+      initialInterval: 10ms
+      maxInterval: 1s
+      jitter: equal
+  db:
+    type: three-state
+    failureThreshold: 0.3
+    minRequests: 20
+    openTimeout: 30s
+`
+
+func checkDocument(t *testing.T, doc *Document) {
+	assert.Equal(t, []string{"api", "db"}, doc.Names(), "Unexpected names")
+
+	api := doc.Breakers["api"]
+	assert.Equal(t, 2.0, api.K, "Unexpected k")
+	assert.Equal(t, 0, *api.Protected, "Unexpected protected")
+	assert.Equal(t, Duration(10*time.Second), api.Window, "Unexpected window")
+	assert.Equal(t, Duration(0), *api.StateDebounce, "Unexpected state debounce")
+	assert.Equal(t, Duration(2*time.Second), api.Timeout, "Unexpected timeout")
+	assert.Equal(t, &RetrySpec{Attempts: 5, InitialInterval: Duration(10 * time.Millisecond), MaxInterval: Duration(time.Second), Jitter: "equal"}, api.Retry, "Unexpected retry")
+
+	db := doc.Breakers["db"]
+	assert.Equal(t, BreakerThreeState, db.Type, "Unexpected type")
+	assert.Equal(t, Duration(30*time.Second), db.OpenTimeout, "Unexpected open timeout")
+	assert.Nil(t, db.Retry, "Unexpected retry")
+}
+
+func TestLoadFromJSON(t *testing.T) {
+	doc, err := LoadFromJSON(strings.NewReader(testJSON))
+	assert.NoError(t, err, "Unexpected error")
+	checkDocument(t, doc)
+
+	// Build the breakers of the document
+	breakers, err := doc.Build()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 2, len(breakers), "Unexpected breakers")
+	for _, b := range breakers {
+		assert.NoError(t, b.Do(func() error { return nil }), "Unexpected error")
+		b.Stop()
+	}
+}
+
+func TestLoadFromYAML(t *testing.T) {
+	doc, err := LoadFromYAML(strings.NewReader(testYAML))
+	assert.NoError(t, err, "Unexpected error")
+	checkDocument(t, doc)
+
+	// An empty document has no breakers
+	doc, err = LoadFromYAML(strings.NewReader(""))
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0, len(doc.Names()), "Unexpected names")
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "breakers.json")
+	yamlPath := filepath.Join(dir, "breakers.yml")
+	assert.NoError(t, os.WriteFile(jsonPath, []byte(testJSON), 0o600), "Unexpected error")
+	assert.NoError(t, os.WriteFile(yamlPath, []byte(testYAML), 0o600), "Unexpected error")
+
+	doc, err := LoadFile(jsonPath)
+	assert.NoError(t, err, "Unexpected error")
+	checkDocument(t, doc)
+
+	doc, err = LoadFile(yamlPath)
+	assert.NoError(t, err, "Unexpected error")
+	checkDocument(t, doc)
+
+	_, err = LoadFile(filepath.Join(dir, "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "Unexpected error")
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  error
+		msg  string
+	}{
+		{"unknown field", `{"breakers": {"api": {"kk": 2}}}`, com.ErrorInvalidConfig, "kk"},
+		{"bad duration", `{"breakers": {"api": {"window": "ten seconds"}}}`, com.ErrorInvalidConfig, "ten seconds"},
+		{"bad type", `{"breakers": {"api": {"type": "two-state"}}}`, com.ErrorInvalidConfig, `breaker "api": invalid configuration: type`},
+		{"bad k", `{"breakers": {"api": {"k": 5}}}`, com.ErrorInvalidConfig, "k must be in [1, 5)"},
+		{"negative protected", `{"breakers": {"api": {"protected": -1}}}`, com.ErrorInvalidConfig, "protected"},
+		{"bad threshold", `{"breakers": {"db": {"failureThreshold": 1.5}}}`, com.ErrorInvalidConfig, "failureThreshold"},
+		{"negative timeout", `{"breakers": {"api": {"timeout": "-1s"}}}`, com.ErrorInvalidConfig, "timeout"},
+		{"bad window mode", `{"breakers": {"api": {"windowMode": "lockfree"}}}`, com.ErrorInvalidConfig, "windowMode"},
+		{"bad window", `{"breakers": {"api": {"window": "1s", "slotInterval": "300ms"}}}`, com.ErrorInvalidWindow, "window"},
+		{"bad jitter", `{"breakers": {"api": {"retry": {"jitter": "random"}}}}`, com.ErrorInvalidConfig, "retry: invalid configuration: jitter"},
+		{"bad max interval", `{"breakers": {"api": {"retry": {"maxInterval": "50ms"}}}}`, com.ErrorInvalidConfig, "maxInterval"},
+		{"empty breaker", `{"breakers": {"api": null}}`, com.ErrorInvalidConfig, "empty breaker"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFromJSON(strings.NewReader(tt.doc))
+			assert.ErrorIs(t, err, tt.err, "Unexpected error")
+			assert.Contains(t, err.Error(), tt.msg, "Unexpected error message")
+		})
+	}
+
+	// YAML rejects unknown fields as well
+	_, err := LoadFromYAML(strings.NewReader("breakers:\n  api:\n    kk: 2\n"))
+	assert.ErrorIs(t, err, com.ErrorInvalidConfig, "Unexpected error")
+}
+
+func TestBreakerSpec_Build(t *testing.T) {
+	// The zero value builds a default GoogleBreaker
+	spec := &BreakerSpec{}
+	conf, err := spec.BreakerConfig()
+	assert.NoError(t, err, "Unexpected error")
+	assert.NotNil(t, conf, "Unexpected config")
+
+	breaker, err := spec.Build()
+	assert.NoError(t, err, "Unexpected error")
+	defer breaker.Stop()
+
+	execError := errors.New("execution error")
+	assert.ErrorIs(t, breaker.Do(func() error { return execError }), execError, "Unexpected error")
+
+	// An invalid value returns an error
+	_, err = (&BreakerSpec{SlowCallWeight: 2}).Build()
+	assert.ErrorIs(t, err, com.ErrorInvalidConfig, "Unexpected error")
+	_, err = (&RetrySpec{Multiplier: 0.5}).RetryConfig()
+	assert.ErrorIs(t, err, com.ErrorInvalidConfig, "Unexpected error")
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("API_TYPE", "three-state")
+	t.Setenv("API_PROTECTED", "0")
+	t.Setenv("API_WINDOW", "10s")
+	t.Setenv("API_WINDOW_MODE", "atomic")
+	t.Setenv("API_FAILURE_THRESHOLD", "0.3")
+	t.Setenv("API_PANIC_RECOVERY", "true")
+	t.Setenv("API_TIMEOUT", "2s")
+	t.Setenv("API_RETRY_ATTEMPTS", "4")
+
+	spec, err := FromEnv("API")
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, BreakerThreeState, spec.Type, "Unexpected type")
+	assert.Equal(t, 0, *spec.Protected, "Unexpected protected")
+	assert.Equal(t, Duration(10*time.Second), spec.Window, "Unexpected window")
+	assert.Equal(t, "atomic", spec.WindowMode, "Unexpected window mode")
+	assert.Equal(t, 0.3, spec.FailureThreshold, "Unexpected failure threshold")
+	assert.True(t, spec.PanicRecovery, "Unexpected panic recovery")
+	assert.Equal(t, Duration(2*time.Second), spec.Timeout, "Unexpected timeout")
+	assert.Nil(t, spec.StateDebounce, "Unexpected state debounce")
+	assert.Equal(t, &RetrySpec{Attempts: 4}, spec.Retry, "Unexpected retry")
+
+	breaker, err := spec.Build()
+	assert.NoError(t, err, "Unexpected error")
+	breaker.Stop()
+
+	// Without variables the spec is the default and the retry is disabled
+	spec, err = FromEnv("NONE_")
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, &BreakerSpec{}, spec, "Unexpected spec")
+
+	// A variable which cannot be parsed returns an error
+	t.Setenv("BAD_MIN_REQUESTS", "many")
+	_, err = FromEnv("BAD")
+	assert.ErrorIs(t, err, com.ErrorInvalidConfig, "Unexpected error")
+	assert.Contains(t, err.Error(), "BAD_MIN_REQUESTS", "Unexpected error message")
+
+	// An invalid value returns an error
+	t.Setenv("K_K", "0.5")
+	_, err = FromEnv("K")
+	assert.ErrorIs(t, err, com.ErrorInvalidConfig, "Unexpected error")
+}
+
+func TestDuration(t *testing.T) {
+	data, err := json.Marshal(Duration(1500 * time.Millisecond))
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, `"1.5s"`, string(data), "Unexpected JSON")
+
+	var d Duration
+	assert.NoError(t, json.Unmarshal([]byte(`"250ms"`), &d), "Unexpected error")
+	assert.Equal(t, Duration(250*time.Millisecond), d, "Unexpected duration")
+	assert.NoError(t, json.Unmarshal([]byte(`1000`), &d), "Unexpected error")
+	assert.Equal(t, Duration(time.Microsecond), d, "Unexpected duration")
+	assert.ErrorIs(t, json.Unmarshal([]byte(`true`), &d), com.ErrorInvalidConfig, "Unexpected error")
+}
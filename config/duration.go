@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration 是可以序列化的时间间隔，写作 "1s"、"500ms" 这样的字符串，整数按纳秒解析。
+// Duration is a serializable time duration, written as a string such as "1s" or "500ms", an integer is parsed as nanoseconds.
+type Duration time.Duration
+
+// parseDuration 解析时间间隔字符串。
+// parseDuration parses a duration string.
+func parseDuration(s string) (Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: duration %q", com.ErrorInvalidConfig, s)
+	}
+	return Duration(d), nil
+}
+
+// String 返回时间间隔的字符串表示。
+// String returns the string form of the duration.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON 把时间间隔编码为 JSON 字符串。
+// MarshalJSON encodes the duration as a JSON string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON 从 JSON 字符串或整数解码时间间隔。
+// UnmarshalJSON decodes the duration from a JSON string or integer.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		v, err := parseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = v
+		return nil
+	}
+
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("%w: duration %s", com.ErrorInvalidConfig, data)
+	}
+	*d = Duration(n)
+	return nil
+}
+
+// MarshalYAML 把时间间隔编码为 YAML 字符串。
+// MarshalYAML encodes the duration as a YAML string.
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+// UnmarshalYAML 从 YAML 字符串或整数解码时间间隔。
+// UnmarshalYAML decodes the duration from a YAML string or integer.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var n int64
+	if value.Tag == "!!int" {
+		if err := value.Decode(&n); err != nil {
+			return fmt.Errorf("%w: duration %q", com.ErrorInvalidConfig, value.Value)
+		}
+		*d = Duration(n)
+		return nil
+	}
+
+	v, err := parseDuration(value.Value)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFromJSON 从 JSON 读取配置文档并检查它，未知的字段和无效的值都会返回错误。
+// LoadFromJSON reads the configuration document from JSON and checks it, unknown fields and invalid values return an error.
+func LoadFromJSON(r io.Reader) (*Document, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	doc := &Document{}
+	if err := decoder.Decode(doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", com.ErrorInvalidConfig, err)
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// LoadFromYAML 从 YAML 读取配置文档并检查它，未知的字段和无效的值都会返回错误。
+// LoadFromYAML reads the configuration document from YAML and checks it, unknown fields and invalid values return an error.
+func LoadFromYAML(r io.Reader) (*Document, error) {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	doc := &Document{}
+	if err := decoder.Decode(doc); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w: %v", com.ErrorInvalidConfig, err)
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// LoadFile 读取配置文件，扩展名为 .yaml 或 .yml 时按 YAML 解析，否则按 JSON 解析。
+// LoadFile reads the configuration file, it is parsed as YAML if the extension is .yaml or .yml, otherwise as JSON.
+func LoadFile(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return LoadFromYAML(bytes.NewReader(data))
+	default:
+		return LoadFromJSON(bytes.NewReader(data))
+	}
+}
+
+// FromEnv 从带有前缀的环境变量读取一个熔断器的配置并检查它，例如前缀为 "API" 时读取 API_K、API_WINDOW、API_RETRY_ATTEMPTS。
+// 变量名是字段名的大写下划线形式，重试策略的变量以 RETRY_ 开头，设置了任何一个时启用重试。
+// FromEnv reads the configuration of one breaker from the environment variables with the prefix and checks it, e.g. API_K, API_WINDOW and API_RETRY_ATTEMPTS for the prefix "API".
+// The variable names are the field names in upper snake case, the variables of the retry policy start with RETRY_, and the retry is enabled if any of them is set.
+func FromEnv(prefix string) (*BreakerSpec, error) {
+	env := &envReader{prefix: prefix}
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		env.prefix += "_"
+	}
+
+	spec := &BreakerSpec{}
+	env.string("TYPE", &spec.Type)
+	env.float("K", &spec.K)
+	spec.Protected = env.intPtr("PROTECTED")
+	env.int("STATE_WINDOW", &spec.StateWindow)
+	env.duration("WINDOW", &spec.Window)
+	env.duration("SLOT_INTERVAL", &spec.SlotInterval)
+	env.string("WINDOW_MODE", &spec.WindowMode)
+	env.float("FAILURE_THRESHOLD", &spec.FailureThreshold)
+	env.int("MIN_REQUESTS", &spec.MinRequests)
+	env.duration("OPEN_TIMEOUT", &spec.OpenTimeout)
+	env.int("HALF_OPEN_PROBES", &spec.HalfOpenProbes)
+	spec.StateDebounce = env.durationPtr("STATE_DEBOUNCE")
+	env.float("REJECTING_RATIO", &spec.RejectingRatio)
+	env.duration("SLOW_CALL_THRESHOLD", &spec.SlowCallThreshold)
+	env.float("SLOW_CALL_WEIGHT", &spec.SlowCallWeight)
+	env.bool("LATENCY_HISTOGRAM", &spec.LatencyHistogram)
+	env.bool("PANIC_RECOVERY", &spec.PanicRecovery)
+	env.duration("TIMEOUT", &spec.Timeout)
+
+	retry := &RetrySpec{}
+	found := env.found
+	env.int("RETRY_ATTEMPTS", &retry.Attempts)
+	env.duration("RETRY_INITIAL_INTERVAL", &retry.InitialInterval)
+	env.duration("RETRY_MAX_INTERVAL", &retry.MaxInterval)
+	env.float("RETRY_MULTIPLIER", &retry.Multiplier)
+	env.string("RETRY_JITTER", &retry.Jitter)
+	if env.found > found {
+		spec.Retry = retry
+	}
+
+	if env.err != nil {
+		return nil, env.err
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// envReader 读取带有前缀的环境变量，并记录第一个解析错误。
+// envReader reads the environment variables with the prefix and records the first parse error.
+type envReader struct {
+	prefix string
+	found  int
+	err    error
+}
+
+// lookup 返回变量的值，变量没有设置或已经出错时返回 false。
+// lookup returns the value of the variable, returns false if the variable is not set or an error occurred.
+func (e *envReader) lookup(name string) (string, bool) {
+	if e.err != nil {
+		return "", false
+	}
+	value, ok := os.LookupEnv(e.prefix + name)
+	if ok {
+		e.found++
+	}
+	return strings.TrimSpace(value), ok
+}
+
+// fail 记录变量的解析错误。
+// fail records the parse error of the variable.
+func (e *envReader) fail(name, value string) {
+	e.err = fmt.Errorf("%w: %s%s %q", com.ErrorInvalidConfig, e.prefix, name, value)
+}
+
+// string 读取字符串变量。
+// string reads a string variable.
+func (e *envReader) string(name string, dst *string) {
+	if value, ok := e.lookup(name); ok {
+		*dst = value
+	}
+}
+
+// int 读取整数变量。
+// int reads an integer variable.
+func (e *envReader) int(name string, dst *int) {
+	if value, ok := e.lookup(name); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			e.fail(name, value)
+			return
+		}
+		*dst = n
+	}
+}
+
+// intPtr 读取整数变量，变量没有设置时返回 nil。
+// intPtr reads an integer variable, returns nil if the variable is not set.
+func (e *envReader) intPtr(name string) *int {
+	if _, ok := os.LookupEnv(e.prefix + name); !ok {
+		return nil
+	}
+	n := 0
+	e.int(name, &n)
+	return &n
+}
+
+// float 读取浮点数变量。
+// float reads a float variable.
+func (e *envReader) float(name string, dst *float64) {
+	if value, ok := e.lookup(name); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			e.fail(name, value)
+			return
+		}
+		*dst = f
+	}
+}
+
+// bool 读取布尔变量。
+// bool reads a boolean variable.
+func (e *envReader) bool(name string, dst *bool) {
+	if value, ok := e.lookup(name); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			e.fail(name, value)
+			return
+		}
+		*dst = b
+	}
+}
+
+// duration 读取时间间隔变量。
+// duration reads a duration variable.
+func (e *envReader) duration(name string, dst *Duration) {
+	if value, ok := e.lookup(name); ok {
+		d, err := parseDuration(value)
+		if err != nil {
+			e.fail(name, value)
+			return
+		}
+		*dst = d
+	}
+}
+
+// durationPtr 读取时间间隔变量，变量没有设置时返回 nil。
+// durationPtr reads a duration variable, returns nil if the variable is not set.
+func (e *envReader) durationPtr(name string) *Duration {
+	if _, ok := os.LookupEnv(e.prefix + name); !ok {
+		return nil
+	}
+	var d Duration
+	e.duration(name, &d)
+	return &d
+}
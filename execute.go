@@ -0,0 +1,128 @@
+package tripwire
+
+import (
+	"context"
+
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// execute 通过熔断器和重试机制执行返回值的函数，并返回类型化的结果
+// execute runs the value-returning function through the breaker and retry pipeline and returns the typed result
+func execute[T any](c *CircuitBreaker, fn func() (T, error), fallback func(error) (T, error), acceptable com.AcceptableFunc) (T, error) {
+	result := c.config.retry.TryOnConflictVal(func() (any, error) {
+		var value T
+
+		// 执行函数，并保存返回值，设置了超时时间时返回值从结果通道中取得
+		// Execute the function and keep the returned value, the value is taken from the result channel if a timeout is set
+		call := withTimeoutValue(func(context.Context) (T, error) { return fn() }, c.config.timeout)
+		handle := func() error {
+			var err error
+			value, err = call(context.Background())
+			return err
+		}
+
+		// 如果提供了回退函数，回退函数的返回值作为结果
+		// If a fallback function is provided, its returned value becomes the result
+		var fb com.FallbackFunc
+		if fallback != nil {
+			fb = func(err error) error {
+				var fbErr error
+				value, fbErr = fallback(err)
+				return fbErr
+			}
+		}
+
+		err := c.config.breaker.DoWithFallbackAcceptable(handle, fb, acceptable)
+		return value, err
+	})
+
+	// 从重试结果中取出类型化的数据
+	// Take the typed data from the retry result
+	value, _ := result.Data().(T)
+	return value, result.TryError()
+}
+
+// executeCtx 通过熔断器和重试机制使用上下文执行返回值的函数，并返回类型化的结果
+// executeCtx runs the value-returning function with the context through the breaker and retry pipeline and returns the typed result
+func executeCtx[T any](ctx context.Context, c *CircuitBreaker, fn func(ctx context.Context) (T, error), fallback func(error) (T, error), acceptable com.AcceptableFunc) (T, error) {
+	result := c.retry(ctx, func() (any, error) {
+		var value T
+
+		// 执行函数，并保存返回值，设置了超时时间时返回值从结果通道中取得
+		// Execute the function and keep the returned value, the value is taken from the result channel if a timeout is set
+		call := withTimeoutValue(fn, c.config.timeout)
+		handle := func(ctx context.Context) error {
+			var err error
+			value, err = call(ctx)
+			return err
+		}
+
+		// 如果提供了回退函数，回退函数的返回值作为结果
+		// If a fallback function is provided, its returned value becomes the result
+		var fb com.FallbackFunc
+		if fallback != nil {
+			fb = func(err error) error {
+				var fbErr error
+				value, fbErr = fallback(err)
+				return fbErr
+			}
+		}
+
+		err := c.config.breaker.DoCtxWithFallbackAcceptable(ctx, handle, fb, acceptable)
+		return value, err
+	})
+
+	// 从重试结果中取出类型化的数据
+	// Take the typed data from the retry result
+	value, _ := result.Data().(T)
+	return value, result.TryError()
+}
+
+// Execute 执行返回值的函数，并返回类型化的结果
+// Execute executes the value-returning function and returns the typed result
+func Execute[T any](c *CircuitBreaker, fn func() (T, error)) (T, error) {
+	return execute(c, fn, nil, cb.DefaultAcceptableFunc)
+}
+
+// ExecuteWithAcceptable 使用可接受函数执行返回值的函数，并返回类型化的结果
+// ExecuteWithAcceptable executes the value-returning function with acceptable function and returns the typed result
+func ExecuteWithAcceptable[T any](c *CircuitBreaker, fn func() (T, error), acceptable com.AcceptableFunc) (T, error) {
+	return execute(c, fn, nil, acceptable)
+}
+
+// ExecuteWithFallback 使用类型化的回退函数执行返回值的函数，并返回类型化的结果
+// ExecuteWithFallback executes the value-returning function with typed fallback function and returns the typed result
+func ExecuteWithFallback[T any](c *CircuitBreaker, fn func() (T, error), fallback func(error) (T, error)) (T, error) {
+	return execute(c, fn, fallback, cb.DefaultAcceptableFunc)
+}
+
+// ExecuteWithFallbackAcceptable 使用类型化的回退函数和可接受函数执行返回值的函数，并返回类型化的结果
+// ExecuteWithFallbackAcceptable executes the value-returning function with typed fallback and acceptable functions and returns the typed result
+func ExecuteWithFallbackAcceptable[T any](c *CircuitBreaker, fn func() (T, error), fallback func(error) (T, error), acceptable com.AcceptableFunc) (T, error) {
+	return execute(c, fn, fallback, acceptable)
+}
+
+// ExecuteCtx 使用上下文执行返回值的函数，并返回类型化的结果
+// ExecuteCtx executes the value-returning function with the context and returns the typed result
+func ExecuteCtx[T any](ctx context.Context, c *CircuitBreaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	return executeCtx(ctx, c, fn, nil, cb.DefaultAcceptableFunc)
+}
+
+// ExecuteCtxWithAcceptable 使用上下文和可接受函数执行返回值的函数，并返回类型化的结果
+// ExecuteCtxWithAcceptable executes the value-returning function with the context and acceptable function and returns the typed result
+func ExecuteCtxWithAcceptable[T any](ctx context.Context, c *CircuitBreaker, fn func(ctx context.Context) (T, error), acceptable com.AcceptableFunc) (T, error) {
+	return executeCtx(ctx, c, fn, nil, acceptable)
+}
+
+// ExecuteCtxWithFallback 使用上下文和类型化的回退函数执行返回值的函数，并返回类型化的结果
+// ExecuteCtxWithFallback executes the value-returning function with the context and typed fallback function and returns the typed result
+func ExecuteCtxWithFallback[T any](ctx context.Context, c *CircuitBreaker, fn func(ctx context.Context) (T, error), fallback func(error) (T, error)) (T, error) {
+	return executeCtx(ctx, c, fn, fallback, cb.DefaultAcceptableFunc)
+}
+
+// ExecuteCtxWithFallbackAcceptable 使用上下文、类型化的回退函数和可接受函数执行返回值的函数，并返回类型化的结果
+// ExecuteCtxWithFallbackAcceptable executes the value-returning function with the context, typed fallback and acceptable functions and returns the typed result
+func ExecuteCtxWithFallbackAcceptable[T any](ctx context.Context, c *CircuitBreaker, fn func(ctx context.Context) (T, error), fallback func(error) (T, error), acceptable com.AcceptableFunc) (T, error) {
+	return executeCtx(ctx, c, fn, fallback, acceptable)
+}
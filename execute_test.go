@@ -0,0 +1,139 @@
+package tripwire
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecute(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	breaker := New(nil)
+	defer breaker.Stop()
+
+	// Test case 1: Successful execution returns the value
+	value, err := Execute(breaker, func() (int, error) {
+		return 42, nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 42, value, "Unexpected value")
+
+	// Test case 2: Failed execution returns the error
+	str, err := Execute(breaker, func() (string, error) {
+		return "partial", execError
+	})
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+	assert.Equal(t, "partial", str, "Unexpected value")
+
+	// Test case 3: Failed execution with acceptable result
+	str, err = ExecuteWithAcceptable(breaker, func() (string, error) {
+		return "accepted", execError
+	}, func(err error) bool {
+		return err == execError
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "accepted", str, "Unexpected value")
+
+	// Test case 4: Pointer values are returned as is
+	type payload struct{ id int }
+	p, err := Execute(breaker, func() (*payload, error) {
+		return &payload{id: 7}, nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 7, p.id, "Unexpected value")
+}
+
+func TestExecuteWithFallback(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	breaker := New(nil)
+	defer breaker.Stop()
+
+	// Simulate running 100 times, failed
+	for i := 0; i < 100; i++ {
+		_ = breaker.Do(func() error {
+			return execError
+		})
+	}
+
+	// The fallback value is returned when the breaker rejects the execution
+	fallbackCalled := 0
+	for i := 0; i < 10; i++ {
+		value, err := ExecuteWithFallback(breaker, func() (int, error) {
+			return 1, execError
+		}, func(err error) (int, error) {
+			assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+			fallbackCalled++
+			return -1, nil
+		})
+		if err == nil {
+			assert.Equal(t, -1, value, "Unexpected value")
+		} else {
+			assert.ErrorIs(t, err, execError, "Unexpected error")
+			assert.Equal(t, 1, value, "Unexpected value")
+		}
+	}
+	assert.Greater(t, fallbackCalled, 0, "Expected at least one fallback")
+}
+
+func TestExecuteCtx(t *testing.T) {
+	var fbError = errors.New("fallback error")
+
+	breaker := New(nil)
+	defer breaker.Stop()
+
+	// Test case 1: Successful execution returns the value
+	value, err := ExecuteCtx(context.Background(), breaker, func(ctx context.Context) (float64, error) {
+		return 1.5, nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 1.5, value, "Unexpected value")
+
+	// Test case 2: Context is already done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	value, err = ExecuteCtxWithFallback(ctx, breaker, func(ctx context.Context) (float64, error) {
+		return 1.5, nil
+	}, func(err error) (float64, error) {
+		return 0, fbError
+	})
+	assert.ErrorIs(t, err, context.Canceled, "Unexpected error")
+	assert.Equal(t, float64(0), value, "Unexpected value")
+}
+
+func TestExecute_Timeout(t *testing.T) {
+	breaker := New(NewConfig().WithTimeout(10 * time.Millisecond))
+	defer breaker.Stop()
+
+	// The slow function keeps running after the timeout, its value must not reach the caller
+	finished := make(chan struct{}, 2)
+	value, err := Execute(breaker, func() (int, error) {
+		defer func() { finished <- struct{}{} }()
+		time.Sleep(50 * time.Millisecond)
+		return 42, nil
+	})
+	assert.True(t, com.IsTimeout(err), "Unexpected error")
+	assert.Equal(t, 0, value, "Unexpected value")
+
+	value, err = ExecuteCtx(context.Background(), breaker, func(ctx context.Context) (int, error) {
+		defer func() { finished <- struct{}{} }()
+		time.Sleep(50 * time.Millisecond)
+		return 42, nil
+	})
+	assert.True(t, com.IsTimeout(err), "Unexpected error")
+	assert.Equal(t, 0, value, "Unexpected value")
+
+	// Wait for the abandoned functions, the race detector reports any write to the caller's variables
+	<-finished
+	<-finished
+
+	// A fast function returns its value through the result channel
+	value, err = Execute(breaker, func() (int, error) { return 7, nil })
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 7, value, "Unexpected value")
+}
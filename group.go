@@ -0,0 +1,383 @@
+package tripwire
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// 定义默认的熔断器组常量值
+// Define the default group constant values
+const (
+	// DefaultGroupIdleTTL 是空闲熔断器被回收前的默认存活时间。
+	// DefaultGroupIdleTTL is the default time an idle breaker lives before it is evicted.
+	DefaultGroupIdleTTL = 10 * time.Minute
+
+	// DefaultGroupEvictInterval 是检查空闲熔断器的默认间隔。
+	// DefaultGroupEvictInterval is the default interval to check idle breakers.
+	DefaultGroupEvictInterval = 30 * time.Second
+)
+
+// TemplateFunc 是为指定键创建熔断器配置的函数。
+// TemplateFunc is a function that creates the breaker configuration for the given key.
+type TemplateFunc = func(key string) *Config
+
+// DefaultTemplateFunc 是默认的模板函数，为每个键创建默认配置。
+// DefaultTemplateFunc is the default template function, it creates the default configuration for every key.
+func DefaultTemplateFunc(key string) *Config { return NewConfig() }
+
+// GroupConfig 是熔断器组的配置。
+// GroupConfig is the configuration for the breaker group.
+type GroupConfig struct {
+	template      TemplateFunc
+	idleTTL       time.Duration
+	evictInterval time.Duration
+}
+
+// NewGroupConfig 返回熔断器组的新配置。
+// NewGroupConfig returns a new configuration for the breaker group.
+func NewGroupConfig() *GroupConfig {
+	return &GroupConfig{
+		template:      DefaultTemplateFunc,
+		idleTTL:       DefaultGroupIdleTTL,
+		evictInterval: DefaultGroupEvictInterval,
+	}
+}
+
+// DefaultGroupConfig 返回熔断器组的默认配置。
+// DefaultGroupConfig returns the default configuration for the breaker group.
+func DefaultGroupConfig() *GroupConfig {
+	return NewGroupConfig()
+}
+
+// WithTemplate 设置为每个键创建熔断器配置的模板函数。
+// WithTemplate sets the template function which creates the breaker configuration for every key.
+func (c *GroupConfig) WithTemplate(template TemplateFunc) *GroupConfig {
+	c.template = template
+	return c
+}
+
+// WithIdleTTL 设置空闲熔断器被回收前的存活时间。
+// WithIdleTTL sets the time an idle breaker lives before it is evicted.
+func (c *GroupConfig) WithIdleTTL(ttl time.Duration) *GroupConfig {
+	c.idleTTL = ttl
+	return c
+}
+
+// WithEvictInterval 设置检查空闲熔断器的间隔。
+// WithEvictInterval sets the interval to check idle breakers.
+func (c *GroupConfig) WithEvictInterval(interval time.Duration) *GroupConfig {
+	c.evictInterval = interval
+	return c
+}
+
+// isGroupConfigValid 检查配置是否有效，如果无效则使用默认值。
+// isGroupConfigValid checks if the configuration is valid, uses default values if invalid.
+func isGroupConfigValid(conf *GroupConfig) *GroupConfig {
+	if conf != nil {
+		if conf.template == nil {
+			conf.template = DefaultTemplateFunc
+		}
+		if conf.idleTTL <= 0 {
+			conf.idleTTL = DefaultGroupIdleTTL
+		}
+		if conf.evictInterval <= 0 {
+			conf.evictInterval = DefaultGroupEvictInterval
+		}
+	} else {
+		conf = DefaultGroupConfig()
+	}
+
+	return conf
+}
+
+// groupEntry 是熔断器组中的一个熔断器及其使用情况。
+// groupEntry is a breaker in the group and its usage.
+type groupEntry struct {
+	breaker  *CircuitBreaker
+	lastUsed int64 // 最后使用的时间 (纳秒) The time of last use (nanoseconds)
+	inflight int64 // 正在执行的调用数 Number of in-flight calls
+}
+
+// touch 更新最后使用的时间。
+// touch updates the time of last use.
+func (e *groupEntry) touch() {
+	atomic.StoreInt64(&e.lastUsed, time.Now().UnixNano())
+}
+
+// Group 是按键懒创建熔断器的熔断器组，空闲的熔断器会被回收。
+// Group is a breaker group which lazily creates a breaker per key, idle breakers are evicted.
+type Group struct {
+	config  *GroupConfig
+	lock    sync.RWMutex
+	entries map[string]*groupEntry
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+	running bool
+}
+
+// NewGroup 创建一个新的熔断器组，并启动空闲熔断器的回收。
+// NewGroup creates a new breaker group and starts evicting idle breakers.
+func NewGroup(conf *GroupConfig) *Group {
+	conf = isGroupConfigValid(conf)
+	g := &Group{
+		config:  conf,
+		entries: make(map[string]*groupEntry),
+		stopCh:  make(chan struct{}),
+		running: true,
+	}
+
+	g.wg.Add(1)
+	go g.executor()
+
+	return g
+}
+
+// Stop 停止熔断器组，并停止所有的熔断器。
+// Stop stops the breaker group and all of its breakers.
+func (g *Group) Stop() {
+	g.once.Do(func() {
+		close(g.stopCh)
+		g.wg.Wait()
+
+		g.lock.Lock()
+		entries := g.entries
+		g.entries = make(map[string]*groupEntry)
+		g.running = false
+		g.lock.Unlock()
+
+		for _, e := range entries {
+			e.breaker.Stop()
+		}
+	})
+}
+
+// executor 定期回收空闲的熔断器。
+// executor evicts idle breakers periodically.
+func (g *Group) executor() {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(g.config.evictInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.stopCh:
+			return
+		case <-ticker.C:
+			g.evict()
+		}
+	}
+}
+
+// evict 回收超过存活时间且没有正在执行调用的熔断器。
+// evict evicts the breakers which exceed the idle TTL and have no in-flight calls.
+func (g *Group) evict() {
+	deadline := time.Now().Add(-g.config.idleTTL).UnixNano()
+
+	// 在锁内收集并移除空闲的熔断器。
+	// Collect and remove idle breakers inside the lock.
+	var evicted []*CircuitBreaker
+	g.lock.Lock()
+	for key, e := range g.entries {
+		if atomic.LoadInt64(&e.inflight) == 0 && atomic.LoadInt64(&e.lastUsed) <= deadline {
+			delete(g.entries, key)
+			evicted = append(evicted, e.breaker)
+		}
+	}
+	g.lock.Unlock()
+
+	// 在锁外停止被回收的熔断器。
+	// Stop the evicted breakers outside the lock.
+	for _, b := range evicted {
+		b.Stop()
+	}
+}
+
+// entry 返回键对应的熔断器，如果不存在则创建。inflight 为 true 时增加正在执行的调用数。
+// entry returns the breaker of the key and creates it if not exists. The in-flight count is increased when inflight is true.
+func (g *Group) entry(key string, inflight bool) (*groupEntry, error) {
+	// 快速路径：熔断器已经存在。
+	// Fast path: the breaker already exists.
+	g.lock.RLock()
+	if !g.running {
+		g.lock.RUnlock()
+		return nil, com.ErrorGroupStopped
+	}
+	e, ok := g.entries[key]
+	if ok {
+		if inflight {
+			atomic.AddInt64(&e.inflight, 1)
+		}
+		e.touch()
+	}
+	g.lock.RUnlock()
+	if ok {
+		return e, nil
+	}
+
+	// 慢速路径：创建熔断器。
+	// Slow path: create the breaker.
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if !g.running {
+		return nil, com.ErrorGroupStopped
+	}
+	if e, ok = g.entries[key]; !ok {
+		e = &groupEntry{breaker: New(g.config.template(key))}
+		g.entries[key] = e
+	}
+	if inflight {
+		atomic.AddInt64(&e.inflight, 1)
+	}
+	e.touch()
+
+	return e, nil
+}
+
+// Get 返回键对应的熔断器，如果不存在则使用模板创建。
+// 返回的熔断器在空闲超过存活时间后可能被回收，长时间使用请调用 Do 系列方法。
+// Get returns the breaker of the key, and creates it from the template if not exists.
+// The returned breaker may be evicted after being idle for the TTL, use the Do methods for long-term usage.
+func (g *Group) Get(key string) (*CircuitBreaker, error) {
+	e, err := g.entry(key, false)
+	if err != nil {
+		return nil, err
+	}
+	return e.breaker, nil
+}
+
+// Remove 移除并停止键对应的熔断器，如果熔断器存在返回 true。
+// Remove removes and stops the breaker of the key, returns true if the breaker exists.
+func (g *Group) Remove(key string) bool {
+	g.lock.Lock()
+	e, ok := g.entries[key]
+	if ok {
+		delete(g.entries, key)
+	}
+	g.lock.Unlock()
+
+	if ok {
+		e.breaker.Stop()
+	}
+	return ok
+}
+
+// Len 返回熔断器组中熔断器的数量。
+// Len returns the number of breakers in the group.
+func (g *Group) Len() int {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return len(g.entries)
+}
+
+// Keys 返回熔断器组中所有存活的键，按字典序排序。
+// Keys returns all live keys in the group, sorted in lexical order.
+func (g *Group) Keys() []string {
+	g.lock.RLock()
+	keys := make([]string, 0, len(g.entries))
+	for key := range g.entries {
+		keys = append(keys, key)
+	}
+	g.lock.RUnlock()
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Range 按键的字典序遍历熔断器组中的熔断器，fn 返回 false 时停止遍历。
+// Range iterates over the breakers in the group in lexical order of keys, stops when fn returns false.
+func (g *Group) Range(fn func(key string, breaker *CircuitBreaker) bool) {
+	for _, key := range g.Keys() {
+		g.lock.RLock()
+		e, ok := g.entries[key]
+		g.lock.RUnlock()
+		if ok && !fn(key, e.breaker) {
+			return
+		}
+	}
+}
+
+// do 使用键对应的熔断器执行函数，执行期间熔断器不会被回收。
+// do executes the function with the breaker of the key, the breaker is not evicted during the execution.
+func (g *Group) do(key string, fn func(breaker *CircuitBreaker) error) error {
+	e, err := g.entry(key, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		e.touch()
+		atomic.AddInt64(&e.inflight, -1)
+	}()
+
+	return fn(e.breaker)
+}
+
+// DoWithFallbackAcceptable 使用键对应的熔断器，以回退和可接受函数执行函数
+// DoWithFallbackAcceptable executes the function with fallback and acceptable functions using the breaker of the key
+func (g *Group) DoWithFallbackAcceptable(key string, fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return g.do(key, func(breaker *CircuitBreaker) error {
+		return breaker.DoWithFallbackAcceptable(fn, fallback, acceptable)
+	})
+}
+
+// DoWithFallback 使用键对应的熔断器，以回退函数执行函数
+// DoWithFallback executes the function with fallback function using the breaker of the key
+func (g *Group) DoWithFallback(key string, fn com.HandleFunc, fallback com.FallbackFunc) error {
+	return g.do(key, func(breaker *CircuitBreaker) error {
+		return breaker.DoWithFallback(fn, fallback)
+	})
+}
+
+// DoWithAcceptable 使用键对应的熔断器，以可接受函数执行函数
+// DoWithAcceptable executes the function with acceptable function using the breaker of the key
+func (g *Group) DoWithAcceptable(key string, fn com.HandleFunc, acceptable com.AcceptableFunc) error {
+	return g.do(key, func(breaker *CircuitBreaker) error {
+		return breaker.DoWithAcceptable(fn, acceptable)
+	})
+}
+
+// Do 使用键对应的熔断器执行函数
+// Do executes the function using the breaker of the key
+func (g *Group) Do(key string, fn com.HandleFunc) error {
+	return g.do(key, func(breaker *CircuitBreaker) error {
+		return breaker.Do(fn)
+	})
+}
+
+// DoCtxWithFallbackAcceptable 使用键对应的熔断器，以上下文、回退和可接受函数执行函数
+// DoCtxWithFallbackAcceptable executes the function with the context, fallback and acceptable functions using the breaker of the key
+func (g *Group) DoCtxWithFallbackAcceptable(ctx context.Context, key string, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return g.do(key, func(breaker *CircuitBreaker) error {
+		return breaker.DoCtxWithFallbackAcceptable(ctx, fn, fallback, acceptable)
+	})
+}
+
+// DoCtxWithFallback 使用键对应的熔断器，以上下文和回退函数执行函数
+// DoCtxWithFallback executes the function with the context and fallback function using the breaker of the key
+func (g *Group) DoCtxWithFallback(ctx context.Context, key string, fn com.HandleCtxFunc, fallback com.FallbackFunc) error {
+	return g.do(key, func(breaker *CircuitBreaker) error {
+		return breaker.DoCtxWithFallback(ctx, fn, fallback)
+	})
+}
+
+// DoCtxWithAcceptable 使用键对应的熔断器，以上下文和可接受函数执行函数
+// DoCtxWithAcceptable executes the function with the context and acceptable function using the breaker of the key
+func (g *Group) DoCtxWithAcceptable(ctx context.Context, key string, fn com.HandleCtxFunc, acceptable com.AcceptableFunc) error {
+	return g.do(key, func(breaker *CircuitBreaker) error {
+		return breaker.DoCtxWithAcceptable(ctx, fn, acceptable)
+	})
+}
+
+// DoCtx 使用键对应的熔断器，以上下文执行函数
+// DoCtx executes the function with the context using the breaker of the key
+func (g *Group) DoCtx(ctx context.Context, key string, fn com.HandleCtxFunc) error {
+	return g.do(key, func(breaker *CircuitBreaker) error {
+		return breaker.DoCtx(ctx, fn)
+	})
+}
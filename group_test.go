@@ -0,0 +1,136 @@
+package tripwire
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_Do(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	group := NewGroup(nil)
+	defer group.Stop()
+
+	// Breakers are created lazily per key
+	assert.Equal(t, 0, group.Len(), "Unexpected length")
+
+	err := group.Do("host-a", func() error { return nil })
+	assert.NoError(t, err, "Unexpected error")
+
+	err = group.DoWithAcceptable("host-b", func() error { return execError }, func(err error) bool { return err == execError })
+	assert.NoError(t, err, "Unexpected error")
+
+	err = group.DoCtx(context.Background(), "host-c", func(ctx context.Context) error { return execError })
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+
+	assert.Equal(t, []string{"host-a", "host-b", "host-c"}, group.Keys(), "Unexpected keys")
+
+	// The same key returns the same breaker
+	b1, err := group.Get("host-a")
+	assert.NoError(t, err, "Unexpected error")
+	b2, err := group.Get("host-a")
+	assert.NoError(t, err, "Unexpected error")
+	assert.Same(t, b1, b2, "Expected the same breaker")
+
+	// Range over the live keys
+	var keys []string
+	group.Range(func(key string, breaker *CircuitBreaker) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	assert.Equal(t, []string{"host-a", "host-b"}, keys, "Unexpected keys")
+
+	// Remove a key
+	assert.True(t, group.Remove("host-a"), "Expected removal")
+	assert.False(t, group.Remove("host-a"), "Unexpected removal")
+	err = b1.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorRollingWindowStopped, "Unexpected error")
+}
+
+func TestGroup_Template(t *testing.T) {
+	var keys []string
+	var lock sync.Mutex
+
+	conf := NewGroupConfig().WithTemplate(func(key string) *Config {
+		lock.Lock()
+		keys = append(keys, key)
+		lock.Unlock()
+		return NewConfig()
+	})
+	group := NewGroup(conf)
+	defer group.Stop()
+
+	// The template is called once per key
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = group.Do("tenant", func() error { return nil })
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, []string{"tenant"}, keys, "Unexpected template calls")
+}
+
+func TestGroup_Evict(t *testing.T) {
+	conf := NewGroupConfig().WithIdleTTL(50 * time.Millisecond).WithEvictInterval(10 * time.Millisecond)
+	group := NewGroup(conf)
+	defer group.Stop()
+
+	// Create an idle breaker
+	idle, err := group.Get("idle")
+	assert.NoError(t, err, "Unexpected error")
+
+	// Keep a call in flight on another breaker
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- group.Do("busy", func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	// Wait for the eviction
+	time.Sleep(150 * time.Millisecond)
+
+	// The idle breaker is evicted and stopped, the busy one is kept
+	assert.Equal(t, []string{"busy"}, group.Keys(), "Unexpected keys")
+	err = idle.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorRollingWindowStopped, "Unexpected error")
+
+	// Finish the in-flight call
+	close(release)
+	assert.NoError(t, <-done, "Unexpected error")
+}
+
+func TestGroup_Stop(t *testing.T) {
+	group := NewGroup(nil)
+
+	b, err := group.Get("host")
+	assert.NoError(t, err, "Unexpected error")
+
+	group.Stop()
+
+	// All breakers are stopped
+	err = b.Do(func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorRollingWindowStopped, "Unexpected error")
+
+	// The group rejects new calls
+	err = group.Do("host", func() error { return nil })
+	assert.ErrorIs(t, err, com.ErrorGroupStopped, "Unexpected error")
+	_, err = group.Get("host")
+	assert.ErrorIs(t, err, com.ErrorGroupStopped, "Unexpected error")
+	assert.Equal(t, 0, group.Len(), "Unexpected length")
+}
@@ -0,0 +1,173 @@
+package rolling
+
+import (
+	"math"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/internal/utils"
+)
+
+// slotLocked 表示插槽正在被重置。
+// slotLocked means the slot is being reset.
+const slotLocked = -1
+
+// atomicSlot 是使用原子操作更新的插槽。epoch 是插槽所属的时间片序号加 1，0 表示插槽为空。
+// atomicSlot is a slot updated with atomic operations. epoch is the index of the time slice the slot belongs to plus 1, 0 means the slot is empty.
+type atomicSlot struct {
+	epoch int64
+	sum   uint64 // float64 的位表示 Bits of a float64
+	count uint64
+}
+
+// AtomicWindow 是使用原子计数器的滚动窗口，写入和读取都不需要锁，适合高并发场景。
+// 插槽按时间片序号直接定位，不需要像 RollingWindow 那样移动偏移量。
+// AtomicWindow is a rolling window using atomic counters, neither writes nor reads take a lock, it suits highly concurrent workloads.
+// Slots are located by the index of the time slice directly, there is no offset to move like in RollingWindow.
+type AtomicWindow struct {
+	slots    []atomicSlot
+	interval int64
+	clock    com.Clock
+	running  int32
+}
+
+// NewAtomicWindow 返回一个指定大小的新原子滚动窗口，大小的含义与 NewRollingWindowWithClock 相同，clock 为 nil 时使用系统时钟。
+// NewAtomicWindow returns a new atomic rolling window with the given size, the size is the same as NewRollingWindowWithClock, the system clock is used if clock is nil.
+func NewAtomicWindow(size int, clock com.Clock) *AtomicWindow {
+	if size < minRollingWindowSize || size > maxRollingWindowSize {
+		size = DefaultRollingWindowSize
+	}
+	return newAtomicWindow(size*int(time.Second/DefaultRollingWindowSlotInterval), DefaultRollingWindowSlotInterval, clock)
+}
+
+// NewAtomicWindowWithInterval 返回一个指定窗口长度和插槽间隔的新原子滚动窗口。
+// 窗口长度或插槽间隔无效时返回错误。
+// NewAtomicWindowWithInterval returns a new atomic rolling window with the given window length and slot interval.
+// Returns an error if the window length or the slot interval is invalid.
+func NewAtomicWindowWithInterval(window, interval time.Duration, clock com.Clock) (*AtomicWindow, error) {
+	if err := ValidateWindow(window, interval); err != nil {
+		return nil, err
+	}
+	return newAtomicWindow(int(window/interval), interval, clock), nil
+}
+
+// newAtomicWindow 返回一个具有指定插槽数量和插槽间隔的新原子滚动窗口。
+// newAtomicWindow returns a new atomic rolling window with the given number of slots and slot interval.
+func newAtomicWindow(slotCount int, interval time.Duration, clock com.Clock) *AtomicWindow {
+	if clock == nil {
+		clock = utils.SystemClock{}
+	}
+	return &AtomicWindow{
+		slots:    make([]atomicSlot, slotCount),
+		interval: int64(interval),
+		clock:    clock,
+		running:  1,
+	}
+}
+
+// epoch 返回当前时间片的序号加 1。
+// epoch returns the index of the current time slice plus 1.
+func (w *AtomicWindow) epoch() int64 {
+	return w.clock.Now().UnixNano()/w.interval + 1
+}
+
+// Stop 停止滚动窗口。
+// Stop stops the rolling window.
+func (w *AtomicWindow) Stop() {
+	atomic.StoreInt32(&w.running, 0)
+}
+
+// Reset 清空滚动窗口中所有插槽的统计数据。
+// Reset clears the statistics of all slots in the rolling window.
+func (w *AtomicWindow) Reset() {
+	for i := range w.slots {
+		w.acquire(&w.slots[i], 0)
+	}
+}
+
+// acquire 确保插槽属于指定的时间片，如果插槽属于更早的时间片则重置它。
+// acquire makes sure the slot belongs to the given time slice, and resets it if it belongs to an earlier time slice.
+func (w *AtomicWindow) acquire(slot *atomicSlot, epoch int64) {
+	for {
+		current := atomic.LoadInt64(&slot.epoch)
+		switch {
+		case current == slotLocked:
+			// 另一个 goroutine 正在重置插槽。
+			// Another goroutine is resetting the slot.
+			runtime.Gosched()
+		case current == epoch || (epoch != 0 && current > epoch):
+			// 插槽已经属于该时间片，或者属于更晚的时间片 (调用方的时间已经过期)。
+			// The slot already belongs to the time slice, or to a later one (the time of the caller is stale).
+			return
+		case atomic.CompareAndSwapInt64(&slot.epoch, current, slotLocked):
+			// 先清零再发布新的时间片，写入方不会把值加到即将被清零的插槽中。
+			// Zero the slot before publishing the new time slice, so writers never add to a slot that is about to be zeroed.
+			atomic.StoreUint64(&slot.sum, 0)
+			atomic.StoreUint64(&slot.count, 0)
+			atomic.StoreInt64(&slot.epoch, epoch)
+			return
+		}
+	}
+}
+
+// Add 向滚动窗口添加一个值。
+// Add adds a value to the rolling window.
+func (w *AtomicWindow) Add(value float64) error {
+	if atomic.LoadInt32(&w.running) == 0 {
+		return com.ErrorRollingWindowStopped
+	}
+
+	epoch := w.epoch()
+	slot := &w.slots[epoch%int64(len(w.slots))]
+	w.acquire(slot, epoch)
+
+	// 使用 CAS 累加浮点数。
+	// Accumulate the float64 with CAS.
+	for {
+		old := atomic.LoadUint64(&slot.sum)
+		if atomic.CompareAndSwapUint64(&slot.sum, old, math.Float64bits(math.Float64frombits(old)+value)) {
+			break
+		}
+	}
+	atomic.AddUint64(&slot.count, 1)
+
+	return nil
+}
+
+// Sum 返回滚动窗口中的值的总和和数量。
+// Sum returns the sum and the count of the values in the rolling window.
+func (w *AtomicWindow) Sum() (float64, uint64, error) {
+	if atomic.LoadInt32(&w.running) == 0 {
+		return 0, 0, com.ErrorRollingWindowStopped
+	}
+
+	// 只统计属于窗口内时间片的插槽。
+	// Only count the slots which belong to the time slices in the window.
+	var (
+		sum    float64
+		count  uint64
+		newest = w.epoch()
+		oldest = newest - int64(len(w.slots)) + 1
+	)
+	for i := range w.slots {
+		slot := &w.slots[i]
+		if epoch := atomic.LoadInt64(&slot.epoch); epoch >= oldest && epoch <= newest {
+			sum += math.Float64frombits(atomic.LoadUint64(&slot.sum))
+			count += atomic.LoadUint64(&slot.count)
+		}
+	}
+
+	return sum, count, nil
+}
+
+// Avg 返回滚动窗口中的值的平均值和数量。
+// Avg returns the average and the count of the values in the rolling window.
+func (w *AtomicWindow) Avg() (float64, uint64, error) {
+	sum, count, err := w.Sum()
+	if err != nil {
+		return 0, 0, err
+	}
+	return sum / float64(count), count, nil
+}
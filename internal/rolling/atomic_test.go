@@ -0,0 +1,114 @@
+package rolling
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/tripwiretest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomicWindow_Sum(t *testing.T) {
+	// Create a new atomic window.
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	aw := NewAtomicWindow(2, clock)
+	defer aw.Stop()
+	assert.Equal(t, 4, len(aw.slots), "Size mismatch")
+
+	// Add values concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.NoError(t, aw.Add(0.5), "Unexpected error")
+			}
+		}()
+	}
+	wg.Wait()
+
+	// All values are counted.
+	sum, count, err := aw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 400.0, sum, "Sum mismatch")
+	assert.Equal(t, uint64(800), count, "Count mismatch")
+	avg, _, err := aw.Avg()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0.5, avg, "Average mismatch")
+
+	// Reset the window.
+	aw.Reset()
+	_, count, err = aw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, uint64(0), count, "Count mismatch")
+
+	// Stop the window.
+	aw.Stop()
+	assert.ErrorIs(t, aw.Add(1), com.ErrorRollingWindowStopped, "Unexpected error")
+	_, _, err = aw.Sum()
+	assert.ErrorIs(t, err, com.ErrorRollingWindowStopped, "Unexpected error")
+}
+
+func TestAtomicWindow_Expire(t *testing.T) {
+	// Create a 200ms atomic window with 50ms slots.
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	aw, err := NewAtomicWindowWithInterval(200*time.Millisecond, 50*time.Millisecond, clock)
+	assert.NoError(t, err, "Unexpected error")
+	defer aw.Stop()
+
+	// Add a value to every slot.
+	for i := 1; i <= 4; i++ {
+		assert.NoError(t, aw.Add(float64(i)), "Unexpected error")
+		clock.Advance(50 * time.Millisecond)
+	}
+
+	// The first value expires.
+	sum, count, err := aw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 9.0, sum, "Sum mismatch")
+	assert.Equal(t, uint64(3), count, "Count mismatch")
+
+	// A slot is reused for a new time slice.
+	assert.NoError(t, aw.Add(10), "Unexpected error")
+	sum, count, err = aw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 19.0, sum, "Sum mismatch")
+	assert.Equal(t, uint64(4), count, "Count mismatch")
+
+	// The whole window expires.
+	clock.Advance(200 * time.Millisecond)
+	_, count, err = aw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, uint64(0), count, "Count mismatch")
+
+	// Invalid intervals are rejected.
+	_, err = NewAtomicWindowWithInterval(time.Second, 0, nil)
+	assert.ErrorIs(t, err, com.ErrorInvalidSlotInterval, "Unexpected error")
+}
+
+func BenchmarkRollingWindow_AddSum(b *testing.B) {
+	rw := NewRollingWindow(DefaultRollingWindowSize)
+	defer rw.Stop()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = rw.Add(1)
+			_, _, _ = rw.Sum()
+		}
+	})
+}
+
+func BenchmarkAtomicWindow_AddSum(b *testing.B) {
+	aw := NewAtomicWindow(DefaultRollingWindowSize, nil)
+	defer aw.Stop()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = aw.Add(1)
+			_, _, _ = aw.Sum()
+		}
+	})
+}
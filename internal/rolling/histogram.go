@@ -0,0 +1,220 @@
+package rolling
+
+import (
+	"math"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+const (
+	// 每个 2 的幂区间内线性划分的子桶数量，决定了分位数的相对误差 (约 1/16)。
+	// The number of linear sub-buckets in every power-of-two range, it decides the relative error of quantiles (about 1/16).
+	histogramSubBuckets = 16
+
+	// 可以区分的最小值为 2^-20，以秒为单位约为 1 微秒。
+	// The smallest distinguishable value is 2^-20, about 1 microsecond in seconds.
+	histogramMinExp = -20
+
+	// 可以区分的最大值为 2^20，以秒为单位约为 12 天。
+	// The largest distinguishable value is 2^20, about 12 days in seconds.
+	histogramMaxExp = 20
+
+	// 直方图的桶数量，第一个桶记录所有不大于最小值的值。
+	// The number of buckets of the histogram, the first bucket records all values not greater than the smallest value.
+	histogramBuckets = (histogramMaxExp-histogramMinExp)*histogramSubBuckets + 1
+)
+
+// windowBucket 是滚动窗口中插槽的接口，Bucket 和 Histogram 都实现了该接口。
+// windowBucket is the interface of the slots in a rolling window, both Bucket and Histogram implement it.
+type windowBucket interface {
+	Add(value float64)
+	Reset()
+	Sum() float64
+	Count() uint64
+}
+
+var (
+	_ windowBucket = (*Bucket)(nil)
+	_ windowBucket = (*Histogram)(nil)
+)
+
+// Histogram 是使用固定的对数线性桶记录值分布的插槽，可以在多个插槽之间合并并查询分位数。
+// 值必须是非负数，负数按 0 记录。
+// Histogram is a slot recording the distribution of values with fixed log-linear buckets, it can be merged across slots and queried for quantiles.
+// Values must be non-negative, negative values are recorded as 0.
+type Histogram struct {
+	counts []uint64 // 第一次添加值时分配 Allocated when the first value is added
+	sum    float64
+	count  uint64
+	min    float64
+	max    float64
+}
+
+// NewHistogram 返回一个新的直方图。
+// NewHistogram returns a new histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// histogramIndex 返回值所在桶的索引。
+// histogramIndex returns the index of the bucket holding the value.
+func histogramIndex(value float64) int {
+	if value <= math.Ldexp(1, histogramMinExp) {
+		return 0
+	}
+	if value >= math.Ldexp(1, histogramMaxExp) {
+		return histogramBuckets - 1
+	}
+
+	// value = frac * 2^exp，frac 在 [0.5, 1) 之间。
+	// value = frac * 2^exp, frac is in [0.5, 1).
+	frac, exp := math.Frexp(value)
+	sub := int((frac*2 - 1) * histogramSubBuckets)
+	return (exp-1-histogramMinExp)*histogramSubBuckets + sub + 1
+}
+
+// histogramValue 返回桶的中点值。
+// histogramValue returns the midpoint value of the bucket.
+func histogramValue(index int) float64 {
+	if index == 0 {
+		return 0
+	}
+	index--
+	exp := index/histogramSubBuckets + histogramMinExp
+	sub := index % histogramSubBuckets
+	return math.Ldexp(1+(float64(sub)+0.5)/histogramSubBuckets, exp)
+}
+
+// Add 向直方图添加一个值。
+// Add adds a value to the histogram.
+func (h *Histogram) Add(value float64) {
+	if value < 0 || math.IsNaN(value) {
+		value = 0
+	}
+	if h.counts == nil {
+		h.counts = make([]uint64, histogramBuckets)
+	}
+	if h.count == 0 || value < h.min {
+		h.min = value
+	}
+	if h.count == 0 || value > h.max {
+		h.max = value
+	}
+	h.counts[histogramIndex(value)]++
+	h.sum += value
+	h.count++
+}
+
+// Reset 清空直方图。
+// Reset clears the histogram.
+func (h *Histogram) Reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.sum = 0
+	h.count = 0
+	h.min = 0
+	h.max = 0
+}
+
+// Sum 返回直方图中的值的总和。
+// Sum returns the sum of the values in the histogram.
+func (h *Histogram) Sum() float64 {
+	return h.sum
+}
+
+// Count 返回直方图中的值的数量。
+// Count returns the number of values in the histogram.
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Merge 把另一个直方图合并到当前直方图。
+// Merge merges another histogram into the current histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	if h.counts == nil {
+		h.counts = make([]uint64, histogramBuckets)
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if h.count == 0 || other.max > h.max {
+		h.max = other.max
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.sum += other.sum
+	h.count += other.count
+}
+
+// Quantile 返回分位数 q 的近似值，q 的取值范围为 [0, 1]。直方图为空时返回 0。
+// Quantile returns the approximate value of the quantile q, q is in [0, 1]. Returns 0 if the histogram is empty.
+func (h *Histogram) Quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.min
+	}
+	if q >= 1 {
+		return h.max
+	}
+
+	// 找到累计数量达到排名的桶，结果不会超出观察到的最小值和最大值。
+	// Find the bucket whose cumulative count reaches the rank, the result never exceeds the observed minimum and maximum.
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			return math.Min(math.Max(histogramValue(i), h.min), h.max)
+		}
+	}
+	return h.max
+}
+
+// HistogramWindow 是插槽为直方图的滚动窗口，可以查询整个窗口内的值的分位数。
+// HistogramWindow is a rolling window whose slots are histograms, the quantiles of the values in the whole window can be queried.
+type HistogramWindow struct {
+	*RollingWindow
+}
+
+// NewHistogramWindow 返回一个指定窗口长度和插槽间隔的新直方图滚动窗口，clock 为 nil 时使用系统时钟。
+// 窗口长度或插槽间隔无效时返回错误。
+// NewHistogramWindow returns a new histogram rolling window with the given window length and slot interval, the system clock is used if clock is nil.
+// Returns an error if the window length or the slot interval is invalid.
+func NewHistogramWindow(window, interval time.Duration, clock com.Clock) (*HistogramWindow, error) {
+	if err := ValidateWindow(window, interval); err != nil {
+		return nil, err
+	}
+	rwin := newRollingWindowWithBucket(int(window/interval), interval, clock, func() windowBucket { return NewHistogram() })
+	return &HistogramWindow{RollingWindow: rwin}, nil
+}
+
+// Snapshot 返回合并了窗口内所有插槽的直方图。
+// Snapshot returns a histogram merging all slots in the window.
+func (w *HistogramWindow) Snapshot() (*Histogram, error) {
+	merged := NewHistogram()
+	err := w.each(func(b windowBucket) {
+		merged.Merge(b.(*Histogram))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// Quantile 返回窗口内的值的分位数 q 的近似值，窗口为空时返回 0。
+// Quantile returns the approximate value of the quantile q of the values in the window, returns 0 if the window is empty.
+func (w *HistogramWindow) Quantile(q float64) (float64, error) {
+	h, err := w.Snapshot()
+	if err != nil {
+		return 0, err
+	}
+	return h.Quantile(q), nil
+}
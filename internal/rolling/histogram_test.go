@@ -0,0 +1,98 @@
+package rolling
+
+import (
+	"testing"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/tripwiretest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram_Quantile(t *testing.T) {
+	h := NewHistogram()
+
+	// An empty histogram returns 0.
+	assert.Equal(t, 0.0, h.Quantile(0.5), "Quantile mismatch")
+
+	// Add 1ms to 1000ms.
+	for i := 1; i <= 1000; i++ {
+		h.Add(float64(i) / 1000)
+	}
+	assert.Equal(t, uint64(1000), h.Count(), "Count mismatch")
+	assert.InDelta(t, 500.5, h.Sum(), 1e-9, "Sum mismatch")
+
+	// The quantiles are within the relative error of the buckets.
+	assert.InEpsilon(t, 0.5, h.Quantile(0.5), 1.0/histogramSubBuckets, "Quantile mismatch")
+	assert.InEpsilon(t, 0.9, h.Quantile(0.9), 1.0/histogramSubBuckets, "Quantile mismatch")
+	assert.InEpsilon(t, 0.99, h.Quantile(0.99), 1.0/histogramSubBuckets, "Quantile mismatch")
+
+	// The extremes are exact.
+	assert.Equal(t, 0.001, h.Quantile(0), "Quantile mismatch")
+	assert.Equal(t, 1.0, h.Quantile(1), "Quantile mismatch")
+
+	// Values out of range are clamped into the first and the last bucket.
+	h.Reset()
+	h.Add(-1)
+	h.Add(1e9)
+	assert.Equal(t, 0.0, h.Quantile(0.5), "Quantile mismatch")
+	assert.Equal(t, 1e9, h.Quantile(1), "Quantile mismatch")
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	for i := 0; i < 90; i++ {
+		a.Add(0.01)
+	}
+	for i := 0; i < 10; i++ {
+		b.Add(1)
+	}
+
+	// Merge two histograms.
+	merged := NewHistogram()
+	merged.Merge(a)
+	merged.Merge(b)
+	merged.Merge(NewHistogram())
+	assert.Equal(t, uint64(100), merged.Count(), "Count mismatch")
+	assert.InEpsilon(t, 0.01, merged.Quantile(0.5), 1.0/histogramSubBuckets, "Quantile mismatch")
+	assert.InEpsilon(t, 1.0, merged.Quantile(0.95), 1.0/histogramSubBuckets, "Quantile mismatch")
+}
+
+func TestHistogramWindow_Quantile(t *testing.T) {
+	// Create a 2s histogram window with 500ms slots.
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	hw, err := NewHistogramWindow(2*time.Second, 500*time.Millisecond, clock)
+	assert.NoError(t, err, "Unexpected error")
+	defer hw.Stop()
+
+	// Slow values in the first slot, fast values in the next slot.
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, hw.Add(1), "Unexpected error")
+	}
+	clock.Advance(500 * time.Millisecond)
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, hw.Add(0.01), "Unexpected error")
+	}
+
+	// The quantiles are aggregated across the slots.
+	p99, err := hw.Quantile(0.99)
+	assert.NoError(t, err, "Unexpected error")
+	assert.InEpsilon(t, 1.0, p99, 1.0/histogramSubBuckets, "Quantile mismatch")
+	sum, count, err := hw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.InDelta(t, 50.5, sum, 1e-9, "Sum mismatch")
+	assert.Equal(t, uint64(100), count, "Count mismatch")
+
+	// The slow values expire.
+	clock.Advance(1600 * time.Millisecond)
+	p99, err = hw.Quantile(0.99)
+	assert.NoError(t, err, "Unexpected error")
+	assert.InEpsilon(t, 0.01, p99, 1.0/histogramSubBuckets, "Quantile mismatch")
+
+	// Invalid intervals and stopped windows return errors.
+	_, err = NewHistogramWindow(time.Second, 0, nil)
+	assert.ErrorIs(t, err, com.ErrorInvalidSlotInterval, "Unexpected error")
+	hw.Stop()
+	_, err = hw.Quantile(0.5)
+	assert.ErrorIs(t, err, com.ErrorRollingWindowStopped, "Unexpected error")
+}
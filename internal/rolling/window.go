@@ -1,10 +1,12 @@
 package rolling
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
 	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/internal/utils"
 )
 
 const (
@@ -25,6 +27,43 @@ var (
 	// 最大滚动窗口大小为600个插槽，即10分钟。
 	// The maximum rolling window size is 600 slots, i.e., 10 minutes.
 	maxRollingWindowSize = 10 * 60
+
+	// 按时长创建的滚动窗口最少有2个插槽。
+	// A rolling window created by duration has at least 2 slots.
+	minRollingWindowSlots = 2
+
+	// 按时长创建的滚动窗口最多有65536个插槽。
+	// A rolling window created by duration has at most 65536 slots.
+	maxRollingWindowSlots = 1 << 16
+)
+
+// Window 是滚动窗口的接口，RollingWindow 和 AtomicWindow 都实现了该接口。
+// Window is the interface of rolling windows, both RollingWindow and AtomicWindow implement it.
+type Window interface {
+	// Add 向窗口添加一个值。
+	// Add adds a value to the window.
+	Add(value float64) error
+
+	// Sum 返回窗口中的值的总和和数量。
+	// Sum returns the sum and the count of the values in the window.
+	Sum() (float64, uint64, error)
+
+	// Avg 返回窗口中的值的平均值和数量。
+	// Avg returns the average and the count of the values in the window.
+	Avg() (float64, uint64, error)
+
+	// Reset 清空窗口中的统计数据。
+	// Reset clears the statistics of the window.
+	Reset()
+
+	// Stop 停止窗口。
+	// Stop stops the window.
+	Stop()
+}
+
+var (
+	_ Window = (*RollingWindow)(nil)
+	_ Window = (*AtomicWindow)(nil)
 )
 
 // RollingWindow 是一个滚动窗口。
@@ -46,6 +85,10 @@ type RollingWindow struct {
 	// The duration of each slot.
 	interval time.Duration
 
+	// 提供当前时间的时钟。
+	// The clock that provides the current time.
+	clock com.Clock
+
 	// 滚动窗口插槽最后更新的时间。
 	// The time when the rolling window slot was last updated.
 	updateAt time.Time
@@ -66,6 +109,12 @@ type RollingWindow struct {
 // NewRollingWindow 返回一个具有指定大小和插槽持续时间的新滚动窗口。
 // NewRollingWindow returns a new rolling window with the specified size and slot duration.
 func NewRollingWindow(size int) *RollingWindow {
+	return NewRollingWindowWithClock(size, utils.SystemClock{})
+}
+
+// NewRollingWindowWithClock 返回一个使用指定时钟的新滚动窗口，clock 为 nil 时使用系统时钟。
+// NewRollingWindowWithClock returns a new rolling window using the given clock, the system clock is used if clock is nil.
+func NewRollingWindowWithClock(size int, clock com.Clock) *RollingWindow {
 	// 如果大小小于最小大小或大于最大大小，则使用默认大小。
 	// If the size is less than the minimum size or greater than the maximum size, use the default size.
 	if size < minRollingWindowSize || size > maxRollingWindowSize {
@@ -76,22 +125,70 @@ func NewRollingWindow(size int) *RollingWindow {
 	// Calculate the number of slots in the rolling window.
 	slotCount := size * int(time.Second/DefaultRollingWindowSlotInterval)
 
+	return newRollingWindow(slotCount, DefaultRollingWindowSlotInterval, clock)
+}
+
+// ValidateWindow 检查滚动窗口的长度和插槽间隔是否有效。
+// 窗口长度必须是插槽间隔的整数倍，并且插槽数量在 2 到 65536 之间。
+// ValidateWindow checks if the length and the slot interval of the rolling window are valid.
+// The window length must be a multiple of the slot interval, and the number of slots must be between 2 and 65536.
+func ValidateWindow(window, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("%w: %v must be positive", com.ErrorInvalidSlotInterval, interval)
+	}
+	if window <= 0 {
+		return fmt.Errorf("%w: %v must be positive", com.ErrorInvalidWindow, window)
+	}
+	if window%interval != 0 {
+		return fmt.Errorf("%w: %v is not a multiple of the slot interval %v", com.ErrorInvalidWindow, window, interval)
+	}
+	if slots := int64(window / interval); slots < int64(minRollingWindowSlots) || slots > int64(maxRollingWindowSlots) {
+		return fmt.Errorf("%w: %v with slot interval %v has %d slots, must be between %d and %d", com.ErrorInvalidWindow, window, interval, slots, minRollingWindowSlots, maxRollingWindowSlots)
+	}
+	return nil
+}
+
+// NewRollingWindowWithInterval 返回一个指定窗口长度和插槽间隔的新滚动窗口，clock 为 nil 时使用系统时钟。
+// 窗口长度或插槽间隔无效时返回错误。
+// NewRollingWindowWithInterval returns a new rolling window with the given window length and slot interval, the system clock is used if clock is nil.
+// Returns an error if the window length or the slot interval is invalid.
+func NewRollingWindowWithInterval(window, interval time.Duration, clock com.Clock) (*RollingWindow, error) {
+	if err := ValidateWindow(window, interval); err != nil {
+		return nil, err
+	}
+	return newRollingWindow(int(window/interval), interval, clock), nil
+}
+
+// newRollingWindow 返回一个具有指定插槽数量和插槽间隔的新滚动窗口。
+// newRollingWindow returns a new rolling window with the given number of slots and slot interval.
+func newRollingWindow(slotCount int, interval time.Duration, clock com.Clock) *RollingWindow {
+	return newRollingWindowWithBucket(slotCount, interval, clock, func() windowBucket { return NewBucket() })
+}
+
+// newRollingWindowWithBucket 返回一个插槽由 newBucket 创建的新滚动窗口。
+// newRollingWindowWithBucket returns a new rolling window whose slots are created by newBucket.
+func newRollingWindowWithBucket(slotCount int, interval time.Duration, clock com.Clock, newBucket func() windowBucket) *RollingWindow {
+	if clock == nil {
+		clock = utils.SystemClock{}
+	}
+
 	// 创建并返回滚动窗口。
 	// Create and return the rolling window.
 	rw := RollingWindow{
 		ring:     NewRing(slotCount),
 		size:     slotCount,
-		interval: DefaultRollingWindowSlotInterval,
+		interval: interval,
 		lock:     sync.Mutex{},
 		runing:   true,
 		once:     sync.Once{},
-		updateAt: time.Now(),
+		clock:    clock,
+		updateAt: clock.Now(),
 	}
 
 	// 初始化滚动窗口。
 	// Initialize the rolling window.
 	for i := 0; i < rw.ring.Cap(); i++ {
-		rw.ring.Push(NewBucket())
+		rw.ring.Push(newBucket())
 	}
 
 	// 返回滚动窗口。
@@ -110,10 +207,34 @@ func (w *RollingWindow) Stop() {
 	})
 }
 
+// Reset 清空滚动窗口中所有插槽的统计数据。
+// Reset clears the statistics of all slots in the rolling window.
+func (w *RollingWindow) Reset() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	// 如果滚动窗口没有运行，直接返回。
+	// If the rolling window is not running, return directly.
+	if !w.runing {
+		return
+	}
+
+	// 重置所有插槽。
+	// Reset all slots.
+	for i := 0; i < w.size; i++ {
+		w.ring.At(i).(windowBucket).Reset()
+	}
+
+	// 重置偏移量和最后更新时间。
+	// Reset the offset and the time of the last update.
+	w.offset = 0
+	w.updateAt = w.clock.Now()
+}
+
 // span 返回自滚动窗口最后更新以来经过的插槽数量。
 // span returns the number of slots that have elapsed since the rolling window was last updated.
 func (w *RollingWindow) span() int {
-	offset := int(time.Since(w.updateAt) / w.interval)
+	offset := int(w.clock.Since(w.updateAt) / w.interval)
 	if offset >= 0 && offset < w.size {
 		return offset
 	}
@@ -140,7 +261,7 @@ func (w *RollingWindow) updateOffset() {
 	// 如果滚动窗口已经向前移动，重置已经过去的插槽。
 	// If the rolling window has been moved forward, reset the slots that have elapsed.
 	for i := 1; i <= n; i++ {
-		bucket := w.ring.At((offset + i) % w.size).(*Bucket)
+		bucket := w.ring.At((offset + i) % w.size).(windowBucket)
 		bucket.Reset()
 	}
 
@@ -150,7 +271,7 @@ func (w *RollingWindow) updateOffset() {
 
 	// 更新滚动窗口插槽最后更新的时间。
 	// Update the time when the rolling window slot was last updated.
-	now := time.Now().UnixNano()
+	now := w.clock.Now().UnixNano()
 	w.updateAt = time.Unix(0, now-(now%int64(w.interval)))
 }
 
@@ -172,7 +293,7 @@ func (w *RollingWindow) Add(value float64) error {
 
 	// 将值添加到当前插槽。
 	// Add the value to the current slot.
-	bucket := w.ring.At(w.offset % w.size).(*Bucket)
+	bucket := w.ring.At(w.offset % w.size).(windowBucket)
 	bucket.Add(value)
 
 	// 添加成功。
@@ -201,7 +322,7 @@ func (w *RollingWindow) calculateStats() (float64, uint64, error) {
 	var sum float64
 	var count uint64
 	for i := 0; i < w.size; i++ {
-		bucket := w.ring.At(i).(*Bucket)
+		bucket := w.ring.At(i).(windowBucket)
 		sum += bucket.Sum()
 		count += bucket.Count()
 	}
@@ -209,6 +330,29 @@ func (w *RollingWindow) calculateStats() (float64, uint64, error) {
 	return sum, count, nil
 }
 
+// each 在持有锁的情况下，对滚动窗口中的每个插槽调用 fn。
+// each calls fn on every slot in the rolling window while holding the lock.
+func (w *RollingWindow) each(fn func(b windowBucket)) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	// 如果滚动窗口没有运行，返回一个错误。
+	// If the rolling window is not running, return an error.
+	if !w.runing {
+		return com.ErrorRollingWindowStopped
+	}
+
+	// 更新滚动窗口。
+	// Update the rolling window.
+	w.updateOffset()
+
+	for i := 0; i < w.size; i++ {
+		fn(w.ring.At(i).(windowBucket))
+	}
+
+	return nil
+}
+
 // Avg 返回滚动窗口中的值的平均值。
 // Avg returns the average of the values in the rolling window.
 func (w *RollingWindow) Avg() (float64, uint64, error) {
@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/tripwiretest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -269,3 +271,122 @@ func TestRollingWindow_AvgWithSmallWindow(t *testing.T) {
 	// Check if the average matches the expected average.
 	assert.Equal(t, expectedAvg, avg, "Average mismatch")
 }
+
+func TestRollingWindow_Reset(t *testing.T) {
+	// rolling window size.
+	rwSize := 5
+
+	// Create a new rolling rw.
+	rw := NewRollingWindow(rwSize)
+	defer rw.Stop()
+
+	// Add some values to the rolling window.
+	var err error
+	for i := 1; i <= rwSize; i++ {
+		err = rw.Add(float64(i))
+		assert.NoError(t, err, "Unexpected error")
+	}
+
+	// Reset the rolling window.
+	rw.Reset()
+
+	// Check if the window is empty.
+	sum, count, err := rw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0.0, sum, "Sum mismatch")
+	assert.Equal(t, uint64(0), count, "Count mismatch")
+
+	// The window still works after reset.
+	err = rw.Add(1)
+	assert.NoError(t, err, "Unexpected error")
+	sum, count, err = rw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 1.0, sum, "Sum mismatch")
+	assert.Equal(t, uint64(1), count, "Count mismatch")
+}
+
+func TestRollingWindow_WithClock(t *testing.T) {
+	// rolling window size.
+	rwSize := 2
+
+	// Create a new rolling rw with a fake clock.
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	rw := NewRollingWindowWithClock(rwSize, clock)
+	defer rw.Stop()
+
+	// Add some values to the rolling window.
+	err := rw.Add(1)
+	assert.NoError(t, err, "Unexpected error")
+
+	// Half of the window elapsed, the value is still in the window.
+	clock.Advance(time.Second)
+	err = rw.Add(2)
+	assert.NoError(t, err, "Unexpected error")
+	sum, count, err := rw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 3.0, sum, "Sum mismatch")
+	assert.Equal(t, uint64(2), count, "Count mismatch")
+
+	// The first value expires.
+	clock.Advance(time.Second)
+	sum, count, err = rw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 2.0, sum, "Sum mismatch")
+	assert.Equal(t, uint64(1), count, "Count mismatch")
+
+	// The whole window expires.
+	clock.Advance(time.Duration(rwSize) * time.Second)
+	sum, count, err = rw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0.0, sum, "Sum mismatch")
+	assert.Equal(t, uint64(0), count, "Count mismatch")
+}
+
+func TestRollingWindow_WithInterval(t *testing.T) {
+	// Create a 200ms rolling window with 50ms slots.
+	clock := tripwiretest.NewFakeClock(time.Unix(0, 0))
+	rw, err := NewRollingWindowWithInterval(200*time.Millisecond, 50*time.Millisecond, clock)
+	assert.NoError(t, err, "Unexpected error")
+	defer rw.Stop()
+	assert.Equal(t, 4, rw.size, "Size mismatch")
+
+	// Add a value to every slot.
+	for i := 1; i <= 4; i++ {
+		err = rw.Add(float64(i))
+		assert.NoError(t, err, "Unexpected error")
+		clock.Advance(50 * time.Millisecond)
+	}
+
+	// The first value expires.
+	sum, count, err := rw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 9.0, sum, "Sum mismatch")
+	assert.Equal(t, uint64(3), count, "Count mismatch")
+
+	// The whole window expires.
+	clock.Advance(200 * time.Millisecond)
+	sum, count, err = rw.Sum()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0.0, sum, "Sum mismatch")
+	assert.Equal(t, uint64(0), count, "Count mismatch")
+}
+
+func TestValidateWindow(t *testing.T) {
+	assert.NoError(t, ValidateWindow(2*time.Second, 50*time.Millisecond), "Unexpected error")
+	assert.NoError(t, ValidateWindow(30*time.Minute, 10*time.Second), "Unexpected error")
+
+	// Invalid slot intervals
+	assert.ErrorIs(t, ValidateWindow(time.Second, 0), com.ErrorInvalidSlotInterval, "Unexpected error")
+	assert.ErrorIs(t, ValidateWindow(time.Second, -time.Millisecond), com.ErrorInvalidSlotInterval, "Unexpected error")
+
+	// Invalid windows
+	assert.ErrorIs(t, ValidateWindow(0, time.Second), com.ErrorInvalidWindow, "Unexpected error")
+	assert.ErrorIs(t, ValidateWindow(time.Second, 300*time.Millisecond), com.ErrorInvalidWindow, "Unexpected error")
+	assert.ErrorIs(t, ValidateWindow(time.Second, time.Second), com.ErrorInvalidWindow, "Unexpected error")
+	assert.ErrorIs(t, ValidateWindow(time.Hour, time.Millisecond), com.ErrorInvalidWindow, "Unexpected error")
+
+	// The constructor returns the validation error
+	rw, err := NewRollingWindowWithInterval(time.Second, 0, nil)
+	assert.Nil(t, rw, "Unexpected rolling window")
+	assert.ErrorIs(t, err, com.ErrorInvalidSlotInterval, "Unexpected error")
+}
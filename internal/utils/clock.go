@@ -0,0 +1,15 @@
+package utils
+
+import "time"
+
+// SystemClock 是使用系统时间的时钟。
+// SystemClock is a clock that uses the system time.
+type SystemClock struct{}
+
+// Now 返回系统的当前时间。
+// Now returns the current system time.
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// Since 返回自 t 以来经过的系统时间。
+// Since returns the system time elapsed since t.
+func (SystemClock) Since(t time.Time) time.Duration { return time.Since(t) }
@@ -0,0 +1,52 @@
+package metrics
+
+import "regexp"
+
+// DefaultNamespace 是指标名称前缀的默认值。
+// DefaultNamespace is the default value of the metric name prefix.
+const DefaultNamespace = "tripwire"
+
+// namespacePattern 是 Prometheus 指标名称允许的格式。
+// namespacePattern is the format allowed for Prometheus metric names.
+var namespacePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Config 是指标导出器的配置。
+// Config is the configuration for the metrics exporter.
+type Config struct {
+	namespace string
+}
+
+// NewConfig 返回指标导出器的新配置。
+// NewConfig returns a new configuration for the metrics exporter.
+func NewConfig() *Config {
+	return &Config{
+		namespace: DefaultNamespace,
+	}
+}
+
+// DefaultConfig 返回指标导出器的默认配置。
+// DefaultConfig returns the default configuration for the metrics exporter.
+func DefaultConfig() *Config {
+	return NewConfig()
+}
+
+// WithNamespace 设置指标名称的前缀，不是有效的 Prometheus 指标名称时使用默认值。
+// WithNamespace sets the prefix of the metric names, the default value is used if it is not a valid Prometheus metric name.
+func (c *Config) WithNamespace(namespace string) *Config {
+	c.namespace = namespace
+	return c
+}
+
+// isConfigValid 检查配置是否有效。
+// isConfigValid checks if the configuration is valid.
+func isConfigValid(conf *Config) *Config {
+	if conf != nil {
+		if !namespacePattern.MatchString(conf.namespace) {
+			conf.namespace = DefaultNamespace
+		}
+	} else {
+		conf = DefaultConfig()
+	}
+
+	return conf
+}
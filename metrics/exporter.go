@@ -0,0 +1,334 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+)
+
+// contentType 是 Prometheus 文本格式的 Content-Type。
+// contentType is the Content-Type of the Prometheus text format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// latencyQuantiles 是导出的延迟分位数。
+// latencyQuantiles are the exported latency quantiles.
+var latencyQuantiles = []float64{0.5, 0.9, 0.99}
+
+// LatencySource 是可以查询延迟分位数和累计总和、数量的对象，例如启用了延迟直方图的 GoogleBreaker。
+// LatencySource is an object whose latency quantiles and cumulative sum and count can be queried, e.g. a GoogleBreaker with the latency histogram enabled.
+type LatencySource interface {
+	LatencyQuantile(q float64) (time.Duration, error)
+	LatencyTotal() (time.Duration, uint64)
+}
+
+// LimitSource 是可以查询并发限制的对象，例如自适应的并发限制器。
+// LimitSource is an object whose concurrency limit can be queried, e.g. the adaptive concurrency limiter.
+type LimitSource interface {
+	Limit() int
+	InFlight() int
+}
+
+// Collector 实现了熔断器的 Callback 接口，收集一个熔断器的指标。
+// Collector implements the Callback interface of the breaker and collects the metrics of one breaker.
+type Collector struct {
+	name      string
+	next      cb.Callback
+	accepted  uint64
+	rejected  uint64
+	successes uint64
+	failures  uint64
+	changes   uint64
+	fuse      uint64 // float64 的位表示 Bits of a float64
+	failure   uint64 // float64 的位表示 Bits of a float64
+	state     int32
+	latency   atomic.Value // LatencySource
+	limit     atomic.Value // LimitSource
+}
+
+// latencyHolder 包装 LatencySource，使 atomic.Value 始终存储相同的具体类型。
+// latencyHolder wraps the LatencySource, so atomic.Value always stores the same concrete type.
+type latencyHolder struct {
+	source LatencySource
+}
+
+// SetLatencySource 设置延迟分位数的来源，导出器会导出它的延迟分位数。
+// SetLatencySource sets the source of latency quantiles, the exporter exports its latency quantiles.
+func (c *Collector) SetLatencySource(source LatencySource) {
+	c.latency.Store(latencyHolder{source: source})
+}
+
+// latencySource 返回延迟分位数的来源，没有设置时返回 nil。
+// latencySource returns the source of latency quantiles, returns nil if not set.
+func (c *Collector) latencySource() LatencySource {
+	if h, ok := c.latency.Load().(latencyHolder); ok {
+		return h.source
+	}
+	return nil
+}
+
+// limitHolder 包装 LimitSource，使 atomic.Value 始终存储相同的具体类型。
+// limitHolder wraps the LimitSource, so atomic.Value always stores the same concrete type.
+type limitHolder struct {
+	source LimitSource
+}
+
+// SetLimitSource 设置并发限制的来源，导出器会导出它的并发限制和正在执行的数量。
+// SetLimitSource sets the source of the concurrency limit, the exporter exports its concurrency limit and the number of executions in flight.
+func (c *Collector) SetLimitSource(source LimitSource) {
+	c.limit.Store(limitHolder{source: source})
+}
+
+// limitSource 返回并发限制的来源，没有设置时返回 nil。
+// limitSource returns the source of the concurrency limit, returns nil if not set.
+func (c *Collector) limitSource() LimitSource {
+	if h, ok := c.limit.Load().(limitHolder); ok {
+		return h.source
+	}
+	return nil
+}
+
+// Name 返回熔断器的名称。
+// Name returns the name of the breaker.
+func (c *Collector) Name() string {
+	return c.name
+}
+
+// OnSuccess 记录一次成功的执行。
+// OnSuccess records a successful execution.
+func (c *Collector) OnSuccess(opterr error) {
+	atomic.AddUint64(&c.successes, 1)
+	c.next.OnSuccess(opterr)
+}
+
+// OnFailure 记录一次失败的执行。
+// OnFailure records a failed execution.
+func (c *Collector) OnFailure(opterr, reason error) {
+	atomic.AddUint64(&c.failures, 1)
+	c.next.OnFailure(opterr, reason)
+}
+
+// OnAccept 记录一次接受或拒绝，以及当前的熔断比率和失败比率。
+// OnAccept records an acceptance or rejection, and the current fuse ratio and failure ratio.
+func (c *Collector) OnAccept(reason error, fuse, failure float64) {
+	if reason == nil {
+		atomic.AddUint64(&c.accepted, 1)
+	} else {
+		atomic.AddUint64(&c.rejected, 1)
+	}
+	atomic.StoreUint64(&c.fuse, math.Float64bits(fuse))
+	atomic.StoreUint64(&c.failure, math.Float64bits(failure))
+	c.next.OnAccept(reason, fuse, failure)
+}
+
+// OnStateChange 记录一次状态变化，下一个回调实现了 StateChangeCallback 时转发给它。
+// OnStateChange records a state change, and forwards it to the next callback if it implements StateChangeCallback.
+func (c *Collector) OnStateChange(from, to cb.State) {
+	atomic.AddUint64(&c.changes, 1)
+	atomic.StoreInt32(&c.state, int32(to))
+	if next, ok := c.next.(cb.StateChangeCallback); ok {
+		next.OnStateChange(from, to)
+	}
+}
+
+// metric 是一个指标的描述。
+// metric is the description of a metric.
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	value func(c *Collector) string
+}
+
+// formatUint 格式化原子读取的计数器。
+// formatUint formats a counter read atomically.
+func formatUint(addr *uint64) string {
+	return strconv.FormatUint(atomic.LoadUint64(addr), 10)
+}
+
+// formatFloat 格式化原子读取的浮点数。
+// formatFloat formats a float64 read atomically.
+func formatFloat(addr *uint64) string {
+	return strconv.FormatFloat(math.Float64frombits(atomic.LoadUint64(addr)), 'g', -1, 64)
+}
+
+// metrics 是导出的指标列表。
+// metrics is the list of exported metrics.
+var metrics = []metric{
+	{"accepted_total", "Total number of executions accepted by the breaker.", "counter", func(c *Collector) string { return formatUint(&c.accepted) }},
+	{"rejected_total", "Total number of executions rejected by the breaker.", "counter", func(c *Collector) string { return formatUint(&c.rejected) }},
+	{"successes_total", "Total number of successful executions.", "counter", func(c *Collector) string { return formatUint(&c.successes) }},
+	{"failures_total", "Total number of failed executions.", "counter", func(c *Collector) string { return formatUint(&c.failures) }},
+	{"state_changes_total", "Total number of breaker state changes.", "counter", func(c *Collector) string { return formatUint(&c.changes) }},
+	{"fuse_ratio", "Current fuse ratio of the breaker.", "gauge", func(c *Collector) string { return formatFloat(&c.fuse) }},
+	{"failure_ratio", "Current failure ratio of the breaker.", "gauge", func(c *Collector) string { return formatFloat(&c.failure) }},
+	{"state", "Current state of the breaker.", "gauge", func(c *Collector) string { return strconv.Itoa(int(atomic.LoadInt32(&c.state))) }},
+}
+
+// limitMetrics 是设置了并发限制来源的收集器导出的指标列表。
+// limitMetrics is the list of metrics exported by the collectors with a concurrency limit source.
+var limitMetrics = []struct {
+	name  string
+	help  string
+	value func(source LimitSource) int
+}{
+	{"concurrency_limit", "Current concurrency limit of the adaptive limiter.", LimitSource.Limit},
+	{"in_flight", "Number of executions in flight.", LimitSource.InFlight},
+}
+
+// labelEscaper 转义标签值中的特殊字符。
+// labelEscaper escapes the special characters in label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Exporter 以 Prometheus 文本格式导出熔断器的指标。
+// Exporter exports the metrics of breakers in the Prometheus text format.
+type Exporter struct {
+	config     *Config
+	lock       sync.RWMutex
+	collectors map[string]*Collector
+}
+
+// NewExporter 返回一个新的指标导出器。
+// NewExporter returns a new metrics exporter.
+func NewExporter(conf *Config) *Exporter {
+	return &Exporter{
+		config:     isConfigValid(conf),
+		collectors: make(map[string]*Collector),
+	}
+}
+
+// Callback 返回指定名称的熔断器的指标收集器，如果不存在则创建。
+// Callback returns the metrics collector of the breaker with the given name, and creates it if not exists.
+func (e *Exporter) Callback(name string) *Collector {
+	return e.Wrap(name, nil)
+}
+
+// Wrap 返回指定名称的熔断器的指标收集器，收集器会把回调转发给 next。
+// 如果收集器已经存在，直接返回已有的收集器。
+// Wrap returns the metrics collector of the breaker with the given name, the collector forwards the callbacks to next.
+// If the collector already exists, the existing collector is returned.
+func (e *Exporter) Wrap(name string, next cb.Callback) *Collector {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if c, ok := e.collectors[name]; ok {
+		return c
+	}
+
+	if next == nil {
+		next = cb.NewEmptyCallback()
+	}
+	c := &Collector{name: name, next: next}
+	e.collectors[name] = c
+	return c
+}
+
+// Remove 移除指定名称的熔断器的指标收集器。
+// Remove removes the metrics collector of the breaker with the given name.
+func (e *Exporter) Remove(name string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.collectors, name)
+}
+
+// snapshot 返回按名称排序的收集器列表。
+// snapshot returns the list of collectors sorted by name.
+func (e *Exporter) snapshot() []*Collector {
+	e.lock.RLock()
+	collectors := make([]*Collector, 0, len(e.collectors))
+	for _, c := range e.collectors {
+		collectors = append(collectors, c)
+	}
+	e.lock.RUnlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name < collectors[j].name })
+	return collectors
+}
+
+// WriteTo 以 Prometheus 文本格式写出所有指标。
+// WriteTo writes all metrics in the Prometheus text format.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	collectors := e.snapshot()
+	bw := bufio.NewWriter(w)
+
+	var n int64
+	for _, m := range metrics {
+		name := e.config.namespace + "_" + m.name
+
+		k, _ := fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, m.help, name, m.kind)
+		n += int64(k)
+
+		for _, c := range collectors {
+			k, _ = fmt.Fprintf(bw, "%s{breaker=\"%s\"} %s\n", name, labelEscaper.Replace(c.name), m.value(c))
+			n += int64(k)
+		}
+	}
+
+	// 导出设置了来源的收集器的延迟分位数。
+	// Export the latency quantiles of the collectors with a source.
+	name := e.config.namespace + "_latency_seconds"
+	header := false
+	for _, c := range collectors {
+		source := c.latencySource()
+		if source == nil {
+			continue
+		}
+		if !header {
+			k, _ := fmt.Fprintf(bw, "# HELP %s Latency quantiles of the executions in the rolling window, with the cumulative sum and count.\n# TYPE %s summary\n", name, name)
+			n += int64(k)
+			header = true
+		}
+		for _, q := range latencyQuantiles {
+			latency, err := source.LatencyQuantile(q)
+			if err != nil {
+				continue
+			}
+			k, _ := fmt.Fprintf(bw, "%s{breaker=\"%s\",quantile=\"%s\"} %s\n", name, labelEscaper.Replace(c.name), strconv.FormatFloat(q, 'g', -1, 64), strconv.FormatFloat(latency.Seconds(), 'g', -1, 64))
+			n += int64(k)
+		}
+
+		// 分位数来自滚动窗口，总和与数量是累计值，与 Prometheus 客户端的 summary 一致。
+		// The quantiles come from the rolling window, the sum and the count are cumulative, like the summary of the Prometheus client.
+		sum, count := source.LatencyTotal()
+		k, _ := fmt.Fprintf(bw, "%s_sum{breaker=\"%s\"} %s\n%s_count{breaker=\"%s\"} %d\n", name, labelEscaper.Replace(c.name), strconv.FormatFloat(sum.Seconds(), 'g', -1, 64), name, labelEscaper.Replace(c.name), count)
+		n += int64(k)
+	}
+
+	// 导出设置了来源的收集器的并发限制。
+	// Export the concurrency limits of the collectors with a source.
+	for _, m := range limitMetrics {
+		name := e.config.namespace + "_" + m.name
+		header := false
+		for _, c := range collectors {
+			source := c.limitSource()
+			if source == nil {
+				continue
+			}
+			if !header {
+				k, _ := fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", name, m.help, name)
+				n += int64(k)
+				header = true
+			}
+			k, _ := fmt.Fprintf(bw, "%s{breaker=\"%s\"} %d\n", name, labelEscaper.Replace(c.name), m.value(source))
+			n += int64(k)
+		}
+	}
+
+	return n, bw.Flush()
+}
+
+// ServeHTTP 以 Prometheus 文本格式响应指标请求。
+// ServeHTTP responds to the metrics request in the Prometheus text format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = e.WriteTo(w)
+}
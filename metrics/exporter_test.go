@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shengyanli1982/tripwire/adaptive"
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type countCallback struct {
+	success int
+}
+
+func (c *countCallback) OnSuccess(opterr error)                       { c.success++ }
+func (c *countCallback) OnFailure(opterr, reason error)               {}
+func (c *countCallback) OnAccept(reason error, fuse, failure float64) {}
+
+func TestExporter_Collector(t *testing.T) {
+	exporter := NewExporter(nil)
+
+	// The same name returns the same collector
+	c := exporter.Callback("upstream")
+	assert.Same(t, c, exporter.Callback("upstream"), "Expected the same collector")
+	assert.Equal(t, "upstream", c.Name(), "Unexpected name")
+
+	c.OnAccept(nil, 0, 0)
+	c.OnAccept(nil, 0.1, 0.2)
+	c.OnAccept(com.ErrorServiceUnavailable, 0.25, 0.5)
+	c.OnSuccess(nil)
+	c.OnFailure(nil, errors.New("execution error"))
+	c.OnFailure(nil, errors.New("execution error"))
+	c.OnStateChange(cb.StateClosed, cb.StateOpen)
+
+	out := &strings.Builder{}
+	_, err := exporter.WriteTo(out)
+	assert.NoError(t, err, "Unexpected error")
+
+	text := out.String()
+	assert.Contains(t, text, "# TYPE tripwire_accepted_total counter\n", "Missing type")
+	assert.Contains(t, text, "tripwire_accepted_total{breaker=\"upstream\"} 2\n", "Unexpected accepted")
+	assert.Contains(t, text, "tripwire_rejected_total{breaker=\"upstream\"} 1\n", "Unexpected rejected")
+	assert.Contains(t, text, "tripwire_successes_total{breaker=\"upstream\"} 1\n", "Unexpected successes")
+	assert.Contains(t, text, "tripwire_failures_total{breaker=\"upstream\"} 2\n", "Unexpected failures")
+	assert.Contains(t, text, "tripwire_state_changes_total{breaker=\"upstream\"} 1\n", "Unexpected state changes")
+	assert.Contains(t, text, "# TYPE tripwire_fuse_ratio gauge\n", "Missing type")
+	assert.Contains(t, text, "tripwire_fuse_ratio{breaker=\"upstream\"} 0.25\n", "Unexpected fuse ratio")
+	assert.Contains(t, text, "tripwire_failure_ratio{breaker=\"upstream\"} 0.5\n", "Unexpected failure ratio")
+	assert.Contains(t, text, "tripwire_state{breaker=\"upstream\"} 1\n", "Unexpected state")
+
+	// The collector receives the state changes of a breaker
+	breaker := cb.NewThreeStateBreaker(cb.NewConfig().WithCallback(exporter.Callback("three")).WithMinRequests(1))
+	defer breaker.Stop()
+	_ = breaker.Do(func() error { return errors.New("execution error") })
+	out.Reset()
+	_, err = exporter.WriteTo(out)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Contains(t, out.String(), "tripwire_state_changes_total{breaker=\"three\"} 1\n", "Unexpected state changes")
+}
+
+func TestExporter_Wrap(t *testing.T) {
+	exporter := NewExporter(NewConfig().WithNamespace("svc"))
+
+	// The collector forwards the callbacks
+	next := &countCallback{}
+	c := exporter.Wrap("a\"b", next)
+	c.OnSuccess(nil)
+	assert.Equal(t, 1, next.success, "Unexpected forwarded success")
+
+	// Label values are escaped
+	out := &strings.Builder{}
+	_, err := exporter.WriteTo(out)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Contains(t, out.String(), "svc_successes_total{breaker=\"a\\\"b\"} 1\n", "Unexpected successes")
+
+	// Remove the collector
+	exporter.Remove("a\"b")
+	out.Reset()
+	_, _ = exporter.WriteTo(out)
+	assert.NotContains(t, out.String(), "breaker=", "Unexpected collector")
+}
+
+func TestExporter_Namespace(t *testing.T) {
+	assert.Equal(t, "svc:api_v1", isConfigValid(NewConfig().WithNamespace("svc:api_v1")).namespace, "Unexpected namespace")
+
+	// Invalid metric names fall back to the default namespace
+	for _, namespace := range []string{"", "1svc", "svc-api", "svc api", "svc.api"} {
+		assert.Equal(t, DefaultNamespace, isConfigValid(NewConfig().WithNamespace(namespace)).namespace, "Unexpected namespace")
+	}
+}
+
+func TestExporter_ServeHTTP(t *testing.T) {
+	exporter := NewExporter(nil)
+
+	// Use the collector as the callback of a breaker
+	breaker := cb.NewGoogleBreaker(cb.NewConfig().WithCallback(exporter.Callback("google")))
+	defer breaker.Stop()
+	for i := 0; i < 3; i++ {
+		_ = breaker.Do(func() error { return nil })
+	}
+
+	server := httptest.NewServer(exporter)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err, "Unexpected error")
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, contentType, resp.Header.Get("Content-Type"), "Unexpected content type")
+	assert.Contains(t, string(body), "tripwire_accepted_total{breaker=\"google\"} 3\n", "Unexpected accepted")
+	assert.Contains(t, string(body), "tripwire_successes_total{breaker=\"google\"} 3\n", "Unexpected successes")
+}
+
+type fixedLatency map[float64]time.Duration
+
+func (f fixedLatency) LatencyQuantile(q float64) (time.Duration, error) { return f[q], nil }
+
+func (f fixedLatency) LatencyTotal() (time.Duration, uint64) { return 1500 * time.Millisecond, 3 }
+
+func TestExporter_Latency(t *testing.T) {
+	exporter := NewExporter(nil)
+	exporter.Callback("plain")
+
+	// No latency metric without a source
+	out := &strings.Builder{}
+	_, _ = exporter.WriteTo(out)
+	assert.NotContains(t, out.String(), "tripwire_latency_seconds", "Unexpected latency")
+
+	// The latency quantiles of the source are exported
+	exporter.Callback("timed").SetLatencySource(fixedLatency{0.5: 10 * time.Millisecond, 0.9: 50 * time.Millisecond, 0.99: time.Second})
+	out.Reset()
+	_, err := exporter.WriteTo(out)
+	assert.NoError(t, err, "Unexpected error")
+	text := out.String()
+	assert.Contains(t, text, "# TYPE tripwire_latency_seconds summary\n", "Missing type")
+	assert.Contains(t, text, "tripwire_latency_seconds{breaker=\"timed\",quantile=\"0.5\"} 0.01\n", "Unexpected p50")
+	assert.Contains(t, text, "tripwire_latency_seconds{breaker=\"timed\",quantile=\"0.99\"} 1\n", "Unexpected p99")
+	assert.Contains(t, text, "tripwire_latency_seconds_sum{breaker=\"timed\"} 1.5\n", "Unexpected sum")
+	assert.Contains(t, text, "tripwire_latency_seconds_count{breaker=\"timed\"} 3\n", "Unexpected count")
+	assert.NotContains(t, text, "tripwire_latency_seconds{breaker=\"plain\"", "Unexpected latency")
+
+	// A GoogleBreaker is a latency source
+	var _ LatencySource = (*cb.GoogleBreaker)(nil)
+}
+
+func TestExporter_Limit(t *testing.T) {
+	exporter := NewExporter(nil)
+	exporter.Callback("plain")
+
+	// No limit metric without a source
+	out := &strings.Builder{}
+	_, _ = exporter.WriteTo(out)
+	assert.NotContains(t, out.String(), "tripwire_concurrency_limit", "Unexpected limit")
+
+	// The limit and the in flight count of the source are exported
+	limiter := adaptive.NewLimiter(adaptive.NewConfig().WithInitialLimit(7))
+	defer limiter.Stop()
+	notifier, err := limiter.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	defer notifier.MarkSuccess()
+
+	exporter.Callback("adaptive").SetLimitSource(limiter)
+	out.Reset()
+	_, err = exporter.WriteTo(out)
+	assert.NoError(t, err, "Unexpected error")
+	text := out.String()
+	assert.Contains(t, text, "# TYPE tripwire_concurrency_limit gauge\n", "Missing type")
+	assert.Contains(t, text, "tripwire_concurrency_limit{breaker=\"adaptive\"} 7\n", "Unexpected limit")
+	assert.Contains(t, text, "tripwire_in_flight{breaker=\"adaptive\"} 1\n", "Unexpected in flight count")
+	assert.NotContains(t, text, "tripwire_concurrency_limit{breaker=\"plain\"", "Unexpected limit")
+}
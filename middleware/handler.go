@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// DefaultRetryAfter 是拒绝请求时 Retry-After 头的默认值。
+// DefaultRetryAfter is the default value of the Retry-After header when a request is rejected.
+const DefaultRetryAfter = time.Second
+
+// StatusClassifierFunc 判断处理函数写出的状态码是否为失败。
+// StatusClassifierFunc decides whether the status code written by the handler is a failure.
+type StatusClassifierFunc = func(status int) bool
+
+// RejectFunc 在熔断器拒绝请求时写出响应。
+// RejectFunc writes the response when the breaker rejects the request.
+type RejectFunc = func(w http.ResponseWriter, r *http.Request, err error)
+
+// DefaultStatusClassifierFunc 是默认的状态码分类函数，5xx 视为失败。
+// DefaultStatusClassifierFunc is the default status classifier function, 5xx are failures.
+func DefaultStatusClassifierFunc(status int) bool {
+	return status >= http.StatusInternalServerError
+}
+
+// DefaultRejectFunc 是默认的拒绝函数，返回 503 响应。
+// DefaultRejectFunc is the default reject function, it returns a 503 response.
+func DefaultRejectFunc(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+// HandlerConfig 是服务端中间件的配置。
+// HandlerConfig is the configuration for the server middleware.
+type HandlerConfig struct {
+	breaker    com.Breaker
+	retryAfter time.Duration
+	classifier StatusClassifierFunc
+	reject     RejectFunc
+}
+
+// NewHandlerConfig 返回服务端中间件的新配置。
+// NewHandlerConfig returns a new configuration for the server middleware.
+func NewHandlerConfig() *HandlerConfig {
+	return &HandlerConfig{
+		retryAfter: DefaultRetryAfter,
+		classifier: DefaultStatusClassifierFunc,
+		reject:     DefaultRejectFunc,
+	}
+}
+
+// DefaultHandlerConfig 返回服务端中间件的默认配置。
+// DefaultHandlerConfig returns the default configuration for the server middleware.
+func DefaultHandlerConfig() *HandlerConfig {
+	return NewHandlerConfig()
+}
+
+// WithBreaker 设置保护处理函数的熔断器。
+// WithBreaker sets the breaker which protects the handler.
+func (c *HandlerConfig) WithBreaker(breaker com.Breaker) *HandlerConfig {
+	c.breaker = breaker
+	return c
+}
+
+// WithRetryAfter 设置拒绝请求时 Retry-After 头的值，0 表示不设置。
+// WithRetryAfter sets the value of the Retry-After header when a request is rejected, 0 means not set.
+func (c *HandlerConfig) WithRetryAfter(retryAfter time.Duration) *HandlerConfig {
+	c.retryAfter = retryAfter
+	return c
+}
+
+// WithStatusClassifier 设置判断状态码是否为失败的函数。
+// WithStatusClassifier sets the function which decides whether a status code is a failure.
+func (c *HandlerConfig) WithStatusClassifier(classifier StatusClassifierFunc) *HandlerConfig {
+	c.classifier = classifier
+	return c
+}
+
+// WithRejectHandler 设置熔断器拒绝请求时写出响应的函数。
+// WithRejectHandler sets the function which writes the response when the breaker rejects the request.
+func (c *HandlerConfig) WithRejectHandler(reject RejectFunc) *HandlerConfig {
+	c.reject = reject
+	return c
+}
+
+// isHandlerConfigValid 检查配置是否有效，如果无效则使用默认值。
+// isHandlerConfigValid checks if the configuration is valid, uses default values if invalid.
+func isHandlerConfigValid(conf *HandlerConfig) *HandlerConfig {
+	if conf == nil {
+		conf = DefaultHandlerConfig()
+	}
+	if conf.breaker == nil {
+		conf.breaker = cb.NewGoogleBreaker(cb.DefaultConfig())
+	}
+	if conf.retryAfter < 0 {
+		conf.retryAfter = DefaultRetryAfter
+	}
+	if conf.classifier == nil {
+		conf.classifier = DefaultStatusClassifierFunc
+	}
+	if conf.reject == nil {
+		conf.reject = DefaultRejectFunc
+	}
+
+	return conf
+}
+
+// statusRecorder 记录处理函数写出的状态码。
+// statusRecorder records the status code written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader 记录并写出状态码。
+// WriteHeader records and writes the status code.
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write 写出响应体，如果没有写出状态码则视为 200。
+// Write writes the response body, the status code is 200 if not written.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.status = http.StatusOK
+		r.wroteHeader = true
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush 如果底层 ResponseWriter 支持，刷新缓冲的数据。
+// Flush flushes the buffered data if the underlying ResponseWriter supports it.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		if !r.wroteHeader {
+			r.status = http.StatusOK
+			r.wroteHeader = true
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap 返回底层的 ResponseWriter。
+// Unwrap returns the underlying ResponseWriter.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Handler 是使用熔断器保护处理函数的服务端中间件，用于入站请求的负载削减。
+// Handler is a server middleware which protects the handler with a breaker, for inbound load shedding.
+type Handler struct {
+	config *HandlerConfig
+	next   http.Handler
+}
+
+// NewHandler 返回一个新的服务端中间件。
+// NewHandler returns a new server middleware.
+func NewHandler(next http.Handler, conf *HandlerConfig) *Handler {
+	return &Handler{config: isHandlerConfigValid(conf), next: next}
+}
+
+// ServeHTTP 使用熔断器处理请求。
+// ServeHTTP handles the request with the breaker.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 熔断器拒绝请求，返回 503 和 Retry-After 头。
+	// The breaker rejects the request, return 503 with the Retry-After header.
+	notifier, err := h.config.breaker.Allow()
+	if err != nil {
+		if h.config.retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(h.config.retryAfter.Seconds()))))
+		}
+		h.config.reject(w, r, err)
+		return
+	}
+
+	rec := &statusRecorder{ResponseWriter: w}
+
+	// 处理函数 panic 时记录失败，然后继续 panic。http.ErrAbortHandler 是中止响应的正常方式，只释放资源，不记录失败。
+	// Record a failure when the handler panics, then keep panicking. http.ErrAbortHandler is the normal way to abort a response, only the resources are released, no failure is recorded.
+	defer func() {
+		if v := recover(); v != nil {
+			if v == http.ErrAbortHandler {
+				if releaser, ok := notifier.(com.Releaser); ok {
+					releaser.Release()
+				}
+			} else {
+				notifier.MarkFailure(fmt.Errorf("handler panic: %v", v))
+			}
+			panic(v)
+		}
+	}()
+
+	h.next.ServeHTTP(rec, r)
+
+	// 没有写出状态码时视为 200。
+	// The status code is 200 if not written.
+	status := rec.status
+	if !rec.wroteHeader {
+		status = http.StatusOK
+	}
+
+	if h.config.classifier(status) {
+		notifier.MarkFailure(&StatusError{StatusCode: status})
+	} else {
+		notifier.MarkSuccess()
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shengyanli1982/tripwire/bulkhead"
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type countCallback struct {
+	success, failure int
+	reasons          []error
+}
+
+func (c *countCallback) OnSuccess(opterr error) { c.success++ }
+
+func (c *countCallback) OnFailure(opterr, reason error) {
+	c.failure++
+	c.reasons = append(c.reasons, reason)
+}
+
+func (c *countCallback) OnAccept(reason error, fuse, failure float64) {}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	callback := &countCallback{}
+	breaker := cb.NewGoogleBreaker(cb.NewConfig().WithCallback(callback))
+	defer breaker.Stop()
+
+	status := http.StatusOK
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	handler := NewHandler(next, NewHandlerConfig().WithBreaker(breaker))
+
+	// Test case 1: Successful request
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "Unexpected status code")
+	assert.Equal(t, 1, callback.success, "Unexpected success count")
+
+	// Test case 2: 4xx is not a failure
+	status = http.StatusNotFound
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "Unexpected status code")
+	assert.Equal(t, 2, callback.success, "Unexpected success count")
+
+	// Test case 3: 5xx is a failure
+	status = http.StatusInternalServerError
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code, "Unexpected status code")
+	assert.Equal(t, 1, callback.failure, "Unexpected failure count")
+	var statusErr *StatusError
+	assert.ErrorAs(t, callback.reasons[0], &statusErr, "Unexpected reason")
+	assert.Equal(t, http.StatusInternalServerError, statusErr.StatusCode, "Unexpected status code")
+}
+
+func TestHandler_Reject(t *testing.T) {
+	breaker := cb.NewGoogleBreaker(nil)
+	defer breaker.Stop()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	// Test case 1: Default rejection response
+	handler := NewHandler(next, NewHandlerConfig().WithBreaker(breaker).WithRetryAfter(1500*time.Millisecond))
+	rejected := 0
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code == http.StatusServiceUnavailable {
+			assert.Equal(t, "2", rec.Header().Get("Retry-After"), "Unexpected Retry-After")
+			rejected++
+		}
+	}
+	assert.Greater(t, rejected, 0, "Expected at least one rejection")
+
+	// Test case 2: Custom rejection response
+	var rejectErr error
+	handler = NewHandler(next, NewHandlerConfig().WithBreaker(breaker).WithRejectHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		rejectErr = err
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	rejected = 0
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code == http.StatusTooManyRequests {
+			assert.Equal(t, "1", rec.Header().Get("Retry-After"), "Unexpected Retry-After")
+			rejected++
+		}
+	}
+	assert.Greater(t, rejected, 0, "Expected at least one rejection")
+	assert.ErrorIs(t, rejectErr, com.ErrorServiceUnavailable, "Unexpected error")
+}
+
+func TestHandler_Panic(t *testing.T) {
+	callback := &countCallback{}
+	breaker := cb.NewGoogleBreaker(cb.NewConfig().WithCallback(callback))
+	defer breaker.Stop()
+
+	panicErr := errors.New("boom")
+	handler := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(panicErr)
+	}), NewHandlerConfig().WithBreaker(breaker))
+
+	// Test case 1: The panic is recorded as a failure and propagated
+	assert.PanicsWithValue(t, panicErr, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+	assert.Equal(t, 1, callback.failure, "Unexpected failure count")
+
+	// Test case 2: An aborted response is propagated without recording a failure, and the slot is released
+	limiter := bulkhead.NewBulkhead(bulkhead.NewConfig().WithMaxConcurrent(1).WithBreaker(breaker))
+	defer limiter.Stop()
+	handler = NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}), NewHandlerConfig().WithBreaker(limiter))
+	for i := 0; i < 2; i++ {
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	}
+	assert.Equal(t, 1, callback.failure, "Unexpected failure count")
+	assert.Equal(t, 0, callback.success, "Unexpected success count")
+}
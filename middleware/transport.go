@@ -0,0 +1,368 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	tp "github.com/shengyanli1982/tripwire"
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// 定义传输层的错误
+// Define the errors of the transport
+var (
+	// 重试时请求体无法重新读取的错误。
+	// Error when the request body cannot be rewound for a retry.
+	ErrorBodyNotRewindable = errors.New("request body is not rewindable")
+)
+
+// ClassifierFunc 判断一次往返的结果是否为失败。
+// ClassifierFunc decides whether the result of a round trip is a failure.
+type ClassifierFunc = func(resp *http.Response, err error) bool
+
+// KeyFunc 返回请求对应的熔断器键。
+// KeyFunc returns the breaker key of the request.
+type KeyFunc = func(req *http.Request) string
+
+// DefaultClassifierFunc 是默认的分类函数，传输错误、5xx 和 429 视为失败。
+// DefaultClassifierFunc is the default classifier function, transport errors, 5xx and 429 are failures.
+func DefaultClassifierFunc(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// HostKeyFunc 使用请求的主机作为熔断器键。
+// HostKeyFunc uses the host of the request as the breaker key.
+func HostKeyFunc(req *http.Request) string { return req.URL.Host }
+
+// StatusError 是响应被分类为失败时记录到熔断器的错误。
+// StatusError is the error recorded to the breaker when a response is classified as a failure.
+type StatusError struct {
+	StatusCode int
+}
+
+// Error 返回错误信息。
+// Error returns the error message.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// RejectedError 是熔断器拒绝请求时返回的错误。
+// RejectedError is the error returned when the breaker rejects the request.
+type RejectedError struct {
+	Key string // 熔断器键 The breaker key
+	Err error  // 熔断器返回的错误 The error returned by the breaker
+}
+
+// Error 返回错误信息。
+// Error returns the error message.
+func (e *RejectedError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("request rejected by breaker: %v", e.Err)
+	}
+	return fmt.Sprintf("request to %q rejected by breaker: %v", e.Key, e.Err)
+}
+
+// Unwrap 返回熔断器返回的错误。
+// Unwrap returns the error returned by the breaker.
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// TransportConfig 是传输层中间件的配置。
+// TransportConfig is the configuration for the transport middleware.
+type TransportConfig struct {
+	base           http.RoundTripper
+	breaker        *tp.CircuitBreaker
+	group          *tp.Group
+	keyFunc        KeyFunc
+	classifier     ClassifierFunc
+	rejectResponse bool
+}
+
+// NewTransportConfig 返回传输层中间件的新配置。
+// NewTransportConfig returns a new configuration for the transport middleware.
+func NewTransportConfig() *TransportConfig {
+	return &TransportConfig{
+		base:       http.DefaultTransport,
+		keyFunc:    HostKeyFunc,
+		classifier: DefaultClassifierFunc,
+	}
+}
+
+// DefaultTransportConfig 返回传输层中间件的默认配置。
+// DefaultTransportConfig returns the default configuration for the transport middleware.
+func DefaultTransportConfig() *TransportConfig {
+	return NewTransportConfig()
+}
+
+// WithBase 设置实际执行请求的底层 RoundTripper。
+// WithBase sets the underlying RoundTripper which actually executes the requests.
+func (c *TransportConfig) WithBase(base http.RoundTripper) *TransportConfig {
+	c.base = base
+	return c
+}
+
+// WithBreaker 设置所有请求共用的熔断器。
+// WithBreaker sets the breaker shared by all requests.
+func (c *TransportConfig) WithBreaker(breaker *tp.CircuitBreaker) *TransportConfig {
+	c.breaker = breaker
+	return c
+}
+
+// WithGroup 设置熔断器组和键函数，每个键使用独立的熔断器，优先于 WithBreaker。
+// WithGroup sets the breaker group and the key function, every key uses its own breaker, takes precedence over WithBreaker.
+func (c *TransportConfig) WithGroup(group *tp.Group, keyFunc KeyFunc) *TransportConfig {
+	c.group = group
+	c.keyFunc = keyFunc
+	return c
+}
+
+// WithClassifier 设置判断往返结果是否为失败的函数。
+// WithClassifier sets the function which decides whether the result of a round trip is a failure.
+func (c *TransportConfig) WithClassifier(classifier ClassifierFunc) *TransportConfig {
+	c.classifier = classifier
+	return c
+}
+
+// WithRejectResponse 设置熔断器拒绝请求时是否返回合成的 503 响应，而不是 RejectedError。
+// WithRejectResponse sets whether to return a synthetic 503 response instead of RejectedError when the breaker rejects the request.
+func (c *TransportConfig) WithRejectResponse(enable bool) *TransportConfig {
+	c.rejectResponse = enable
+	return c
+}
+
+// isTransportConfigValid 检查配置是否有效，如果无效则使用默认值。
+// isTransportConfigValid checks if the configuration is valid, uses default values if invalid.
+func isTransportConfigValid(conf *TransportConfig) *TransportConfig {
+	if conf != nil {
+		if conf.base == nil {
+			conf.base = http.DefaultTransport
+		}
+		if conf.keyFunc == nil {
+			conf.keyFunc = HostKeyFunc
+		}
+		if conf.classifier == nil {
+			conf.classifier = DefaultClassifierFunc
+		}
+		if conf.breaker == nil && conf.group == nil {
+			conf.breaker = tp.New(nil)
+		}
+	} else {
+		conf = DefaultTransportConfig()
+		conf.breaker = tp.New(nil)
+	}
+
+	return conf
+}
+
+// Transport 是使用熔断器保护每个请求的 http.RoundTripper。
+// Transport is an http.RoundTripper which protects every request with a breaker.
+type Transport struct {
+	config *TransportConfig
+}
+
+// NewTransport 返回一个新的传输层中间件。
+// NewTransport returns a new transport middleware.
+func NewTransport(conf *TransportConfig) *Transport {
+	return &Transport{config: isTransportConfigValid(conf)}
+}
+
+// drain 读取并关闭响应体，使底层连接可以被复用。
+// drain reads and closes the response body, so the underlying connection can be reused.
+func drain(resp *http.Response) {
+	if resp != nil && resp.Body != nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+	}
+}
+
+// rewind 返回可以再次发送的请求副本。
+// rewind returns a copy of the request which can be sent again.
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, ErrorBodyNotRewindable
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+// cancelBody 是在关闭时取消请求上下文的响应体。
+// cancelBody is a response body which cancels the request context when closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// roundTripCtx 执行一次往返，上下文在往返返回前结束时取消请求。
+// 请求不直接使用上下文，因为尝试返回后上下文也会被取消，那样会中断调用方读取响应体，请求的上下文改为在响应体关闭时释放。
+// roundTripCtx executes a round trip, and cancels the request if the context ends before the round trip returns.
+// The request does not use the context directly, because the context is also canceled after the attempt returns, which would break the caller reading the response body, the request context is released when the response body is closed instead.
+func roundTripCtx(ctx context.Context, base http.RoundTripper, req *http.Request) (*http.Response, error) {
+	reqCtx, cancel := context.WithCancel(req.Context())
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+
+	res, err := base.RoundTrip(req.WithContext(reqCtx))
+
+	// 等待监视协程退出，之后尝试的上下文被取消不会再影响响应体。
+	// Wait for the watching goroutine to exit, after that the cancellation of the attempt context does not affect the response body.
+	close(stop)
+	<-stopped
+	if res != nil && res.Body != nil {
+		res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	} else {
+		cancel()
+	}
+	return res, err
+}
+
+// isRejected 检查错误是否是请求被拒绝的错误。
+// isRejected checks if the error is an error of a rejected request.
+func isRejected(err error) bool {
+	return errors.Is(err, com.ErrorServiceUnavailable) || errors.Is(err, com.ErrorBulkheadFull) ||
+		errors.Is(err, com.ErrorRateLimited) || errors.Is(err, com.ErrorLimitExceeded)
+}
+
+// rejected 返回熔断器拒绝请求时的结果。
+// rejected returns the result when the breaker rejects the request.
+func (t *Transport) rejected(req *http.Request, key string, err error) (*http.Response, error) {
+	if !t.config.rejectResponse {
+		return nil, &RejectedError{Key: key, Err: err}
+	}
+
+	// 返回合成的 503 响应。
+	// Return a synthetic 503 response.
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable)),
+		StatusCode: http.StatusServiceUnavailable,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+// RoundTrip 使用熔断器执行一次 HTTP 往返。
+// RoundTrip executes a single HTTP round trip with the breaker.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// 设置了超时时间时，每次尝试都在独立的协程中执行，超时后它的请求被取消，但协程可能稍后才返回，所以共享的状态由锁保护。
+	// With a timeout set, every attempt runs in a separate goroutine, its request is canceled after the timeout but the goroutine may return a bit later, so the shared state is guarded by the lock.
+	var (
+		lock    sync.Mutex
+		resp    *http.Response
+		attempt int
+		key     string
+	)
+
+	// 每次尝试都会执行的往返函数。
+	// The round trip function executed on every attempt.
+	fn := func(ctx context.Context) error {
+		lock.Lock()
+
+		// 重试前关闭上一次尝试的响应体。
+		// Close the response body of the previous attempt before retrying.
+		if resp != nil {
+			drain(resp)
+			resp = nil
+		}
+
+		// 重试时重新读取请求体。
+		// Rewind the request body when retrying.
+		r := req
+		if attempt > 0 {
+			var err error
+			if r, err = rewind(req); err != nil {
+				lock.Unlock()
+				return err
+			}
+		}
+		attempt++
+		lock.Unlock()
+
+		// 执行请求，尝试被放弃时取消请求。
+		// Execute the request, and cancel it when the attempt is abandoned.
+		res, err := roundTripCtx(ctx, t.config.base, r)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		// 上下文已经结束，这次尝试已被放弃，在这里关闭迟到的响应体。
+		// The context is done, this attempt has been abandoned, close the late response body here.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			drain(res)
+			if err != nil {
+				return err
+			}
+			return ctxErr
+		}
+
+		// 对结果分类。
+		// Classify the result.
+		resp = res
+		if t.config.classifier(res, err) && err == nil {
+			return &StatusError{StatusCode: res.StatusCode}
+		}
+		return err
+	}
+
+	// 使用熔断器或熔断器组执行请求。
+	// Execute the request with the breaker or the breaker group.
+	var err error
+	if t.config.group != nil {
+		key = t.config.keyFunc(req)
+		err = t.config.group.DoCtx(req.Context(), key, fn)
+	} else {
+		err = t.config.breaker.DoCtx(req.Context(), fn)
+	}
+
+	// 执行已经结束，之后迟到的尝试不会再写入 resp。
+	// The execution is over, late attempts do not write resp any more.
+	lock.Lock()
+	defer lock.Unlock()
+
+	// 执行成功，或者响应被分类为失败，把响应交给调用方。
+	// The execution succeeded, or the response was classified as a failure, hand the response to the caller.
+	var statusErr *StatusError
+	if err == nil || (errors.As(err, &statusErr) && resp != nil) {
+		return resp, nil
+	}
+
+	// 其他情况下调用方拿不到响应，关闭响应体。
+	// In other cases the caller does not get the response, close the response body.
+	drain(resp)
+
+	// 熔断器、隔舱、限流器或并发限制器拒绝了请求。
+	// The breaker, the bulkhead, the rate limiter or the concurrency limiter rejected the request.
+	if isRejected(err) {
+		return t.rejected(req, key, err)
+	}
+
+	return nil, err
+}
@@ -0,0 +1,308 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tp "github.com/shengyanli1982/tripwire"
+	"github.com/shengyanli1982/tripwire/bulkhead"
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type trackingBody struct {
+	io.Reader
+	closed *int32
+}
+
+func (b *trackingBody) Close() error {
+	atomic.AddInt32(b.closed, 1)
+	return nil
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	var status int32 = http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	breaker := tp.New(nil)
+	defer breaker.Stop()
+
+	client := &http.Client{Transport: NewTransport(NewTransportConfig().WithBreaker(breaker))}
+
+	// Test case 1: Successful request
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err, "Unexpected error")
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Unexpected status code")
+	assert.Equal(t, "hello", string(body), "Unexpected body")
+
+	// Test case 2: 5xx responses are handed to the caller
+	atomic.StoreInt32(&status, http.StatusBadGateway)
+	resp, err = client.Get(server.URL)
+	assert.NoError(t, err, "Unexpected error")
+	body, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode, "Unexpected status code")
+	assert.Equal(t, "hello", string(body), "Unexpected body")
+
+	// Test case 3: Many failures make the breaker reject requests
+	rejected := 0
+	for i := 0; i < 100; i++ {
+		resp, err = client.Get(server.URL)
+		if err != nil {
+			var rejectedErr *RejectedError
+			assert.ErrorAs(t, err, &rejectedErr, "Unexpected error")
+			assert.ErrorIs(t, err, com.ErrorServiceUnavailable, "Unexpected error")
+			rejected++
+			continue
+		}
+		_ = resp.Body.Close()
+	}
+	assert.Greater(t, rejected, 0, "Expected at least one rejection")
+}
+
+func TestTransport_RejectResponse(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	breaker := tp.New(nil)
+	defer breaker.Stop()
+
+	transport := NewTransport(NewTransportConfig().WithBase(base).WithBreaker(breaker).WithRejectResponse(true))
+
+	// Transport errors are failures, until the breaker returns synthetic 503 responses
+	synthetic := 0
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://upstream.local/", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			assert.EqualError(t, err, "connection refused", "Unexpected error")
+			continue
+		}
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "Unexpected status code")
+		assert.Same(t, req, resp.Request, "Unexpected request")
+		synthetic++
+	}
+	assert.Greater(t, synthetic, 0, "Expected at least one synthetic response")
+}
+
+func TestTransport_Group(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status := http.StatusOK
+		if req.URL.Host == "bad.local" {
+			status = http.StatusServiceUnavailable
+		}
+		return &http.Response{StatusCode: status, Body: http.NoBody, Request: req}, nil
+	})
+
+	group := tp.NewGroup(nil)
+	defer group.Stop()
+
+	transport := NewTransport(NewTransportConfig().WithBase(base).WithGroup(group, HostKeyFunc))
+
+	// Break the bad host
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://bad.local/", nil)
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}
+
+	// The good host is not affected
+	for i := 0; i < 10; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://good.local/", nil)
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "Unexpected status code")
+	}
+
+	assert.Equal(t, []string{"bad.local", "good.local"}, group.Keys(), "Unexpected keys")
+}
+
+func TestTransport_Classifier(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody, Request: req}, nil
+	})
+
+	breaker := tp.New(nil)
+	defer breaker.Stop()
+
+	// 404 is a failure for this classifier
+	classifier := func(resp *http.Response, err error) bool {
+		return err != nil || resp.StatusCode >= http.StatusBadRequest
+	}
+	transport := NewTransport(NewTransportConfig().WithBase(base).WithBreaker(breaker).WithClassifier(classifier))
+
+	rejected := 0
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://upstream.local/", nil)
+		if _, err := transport.RoundTrip(req); err != nil {
+			rejected++
+		}
+	}
+	assert.Greater(t, rejected, 0, "Expected at least one rejection")
+}
+
+func TestTransport_RetryClosesBody(t *testing.T) {
+	var closed int32
+	var calls int32
+	var bodies []string
+
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		n := atomic.AddInt32(&calls, 1)
+		b, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		status := http.StatusInternalServerError
+		if n == 3 {
+			status = http.StatusOK
+		}
+		return &http.Response{StatusCode: status, Body: &trackingBody{Reader: strings.NewReader("x"), closed: &closed}, Request: req}, nil
+	})
+
+	retry := tp.NewBackoffRetry(tp.NewRetryConfig().WithAttempts(3).WithInitialInterval(time.Millisecond))
+	breaker := tp.New(tp.NewConfig().WithRetry(retry))
+	defer breaker.Stop()
+
+	transport := NewTransport(NewTransportConfig().WithBase(base).WithBreaker(breaker))
+
+	req, _ := http.NewRequest(http.MethodPost, "http://upstream.local/", strings.NewReader("payload"))
+	resp, err := transport.RoundTrip(req)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Unexpected status code")
+
+	// The bodies of the 2 failed attempts are closed, the request body is rewound
+	assert.Equal(t, int32(2), atomic.LoadInt32(&closed), "Unexpected closed bodies")
+	assert.Equal(t, []string{"payload", "payload", "payload"}, bodies, "Unexpected request bodies")
+	_ = resp.Body.Close()
+}
+
+func TestTransport_BulkheadFull(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		close(started)
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+
+	breaker := tp.New(tp.NewConfig().WithBreaker(bulkhead.NewBulkhead(bulkhead.NewConfig().WithMaxConcurrent(1))))
+	defer breaker.Stop()
+
+	transport := NewTransport(NewTransportConfig().WithBase(base).WithBreaker(breaker))
+
+	// The first request occupies the only slot
+	done := make(chan error, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, "http://upstream.local/", nil)
+		_, err := transport.RoundTrip(req)
+		done <- err
+	}()
+	<-started
+
+	// The second request is rejected by the bulkhead
+	req, _ := http.NewRequest(http.MethodGet, "http://upstream.local/", nil)
+	_, err := transport.RoundTrip(req)
+	var rejectedErr *RejectedError
+	assert.True(t, errors.As(err, &rejectedErr), "Expected rejected error")
+	assert.ErrorIs(t, err, com.ErrorBulkheadFull, "Unexpected error")
+
+	close(release)
+	assert.NoError(t, <-done, "Unexpected error")
+}
+
+func TestTransport_TimeoutDrainsLateResponse(t *testing.T) {
+	var closed int32
+	returned := make(chan struct{})
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		defer close(returned)
+		time.Sleep(50 * time.Millisecond)
+		return &http.Response{StatusCode: http.StatusOK, Body: &trackingBody{Reader: strings.NewReader("x"), closed: &closed}, Request: req}, nil
+	})
+
+	breaker := tp.New(tp.NewConfig().WithTimeout(10 * time.Millisecond))
+	defer breaker.Stop()
+
+	transport := NewTransport(NewTransportConfig().WithBase(base).WithBreaker(breaker))
+
+	// The request times out before the base round tripper returns
+	req, _ := http.NewRequest(http.MethodGet, "http://upstream.local/", nil)
+	resp, err := transport.RoundTrip(req)
+	assert.Nil(t, resp, "Unexpected response")
+	assert.True(t, com.IsTimeout(err), "Unexpected error")
+
+	// The late response is closed by the abandoned attempt
+	<-returned
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&closed) == 1 }, time.Second, time.Millisecond, "Late response not closed")
+}
+
+func TestTransport_TimeoutCancelsRequest(t *testing.T) {
+	canceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(canceled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	breaker := tp.New(tp.NewConfig().WithTimeout(20 * time.Millisecond))
+	defer breaker.Stop()
+
+	transport := NewTransport(NewTransportConfig().WithBreaker(breaker))
+	client := &http.Client{Transport: transport}
+
+	// The request times out while the server hangs
+	resp, err := client.Get(server.URL)
+	assert.Nil(t, resp, "Unexpected response")
+	assert.True(t, com.IsTimeout(err), "Unexpected error")
+
+	// The server sees the request canceled
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("Request not canceled")
+	}
+}
+
+func TestTransport_TimeoutKeepsBodyReadable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		time.Sleep(10 * time.Millisecond)
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer server.Close()
+
+	breaker := tp.New(tp.NewConfig().WithTimeout(time.Second))
+	defer breaker.Stop()
+
+	transport := NewTransport(NewTransportConfig().WithBreaker(breaker))
+	client := &http.Client{Transport: transport}
+
+	// The body is read after the attempt returned
+	resp, err := client.Get(server.URL)
+	assert.Nil(t, err, "Unexpected error")
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, "hello", string(body), "Unexpected body")
+}
@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// limiter 是限流算法，调用方负责加锁。
+// limiter is a rate limiting algorithm, the caller is responsible for locking.
+type limiter interface {
+	// reserve 预留一个令牌，返回需要等待的时间。需要等待超过 maxWait 时不预留，返回 false。
+	// reserve reserves a token and returns the time to wait. If the wait exceeds maxWait, nothing is reserved and false is returned.
+	reserve(now time.Time, maxWait time.Duration) (time.Duration, bool)
+
+	// cancel 归还一个预留的令牌。
+	// cancel gives back a reserved token.
+	cancel()
+}
+
+// newLimiter 返回配置的限流算法。
+// newLimiter returns the configured rate limiting algorithm.
+func newLimiter(conf *Config, now time.Time) limiter {
+	if conf.algorithm == AlgorithmGCRA {
+		interval := time.Duration(float64(time.Second) / conf.rate)
+		return &gcra{interval: interval, tolerance: interval * time.Duration(conf.burst-1)}
+	}
+	return &tokenBucket{rate: conf.rate, burst: float64(conf.burst), tokens: float64(conf.burst), last: now}
+}
+
+// tokenBucket 是令牌桶算法，令牌数可以为负数，表示已经预留了未来的令牌。
+// tokenBucket is the token bucket algorithm, the number of tokens can be negative, which means future tokens are reserved.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve 预留一个令牌。
+// reserve reserves a token.
+func (b *tokenBucket) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	// 按经过的时间补充令牌，最多 burst 个。
+	// Refill the tokens by the elapsed time, at most burst tokens.
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+
+	tokens := b.tokens - 1
+	if tokens >= 0 {
+		b.tokens = tokens
+		return 0, true
+	}
+
+	// 令牌不足，计算补充到 0 需要的时间。
+	// Not enough tokens, calculate the time to refill to 0.
+	wait := time.Duration(-tokens / b.rate * float64(time.Second))
+	if wait > maxWait {
+		return 0, false
+	}
+	b.tokens = tokens
+	return wait, true
+}
+
+// cancel 归还一个令牌。
+// cancel gives back a token.
+func (b *tokenBucket) cancel() {
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// gcra 是通用信元速率算法，tat 是下一次执行的理论到达时间。
+// gcra is the generic cell rate algorithm, tat is the theoretical arrival time of the next execution.
+type gcra struct {
+	interval  time.Duration
+	tolerance time.Duration
+	tat       time.Time
+}
+
+// reserve 预留一个令牌。
+// reserve reserves a token.
+func (g *gcra) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+
+	// 理论到达时间超出容忍范围的部分需要等待。
+	// The part of the theoretical arrival time beyond the tolerance has to be waited.
+	wait := tat.Sub(now) - g.tolerance
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxWait {
+		return 0, false
+	}
+	g.tat = tat.Add(g.interval)
+	return wait, true
+}
+
+// cancel 归还一个令牌。
+// cancel gives back a token.
+func (g *gcra) cancel() {
+	g.tat = g.tat.Add(-g.interval)
+}
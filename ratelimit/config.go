@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/internal/utils"
+)
+
+// Algorithm 是限流的算法。
+// Algorithm is the algorithm of rate limiting.
+type Algorithm int
+
+const (
+	// AlgorithmTokenBucket 使用令牌桶，令牌按速率补充，最多积累 burst 个。
+	// AlgorithmTokenBucket uses a token bucket, tokens are refilled at the rate and at most burst tokens are accumulated.
+	AlgorithmTokenBucket Algorithm = iota
+
+	// AlgorithmGCRA 使用通用信元速率算法，只记录理论到达时间，允许的突发与令牌桶相同。
+	// AlgorithmGCRA uses the generic cell rate algorithm, it only keeps the theoretical arrival time and allows the same burst as the token bucket.
+	AlgorithmGCRA
+)
+
+const (
+	// DefaultRate 是每秒补充的令牌数的默认值。
+	// DefaultRate is the default number of tokens refilled per second.
+	DefaultRate = 100.0
+
+	// DefaultBurst 是突发大小的默认值，即最多可以立即执行的数量。
+	// DefaultBurst is the default burst size, i.e. the maximum number of executions allowed at once.
+	DefaultBurst = 100
+
+	// DefaultMaxWait 是等待令牌的最长时间的默认值，0 表示不等待，令牌用完时立即拒绝。
+	// DefaultMaxWait is the default maximum time waiting for a token, 0 means no waiting, executions are rejected immediately when the tokens run out.
+	DefaultMaxWait = time.Duration(0)
+)
+
+// Config 是限流器的配置。
+// Config is the configuration for the rate limiter.
+type Config struct {
+	rate      float64
+	burst     int
+	maxWait   time.Duration
+	algorithm Algorithm
+	clock     com.Clock
+}
+
+// NewConfig 返回限流器的新配置。
+// NewConfig returns a new configuration for the rate limiter.
+func NewConfig() *Config {
+	return &Config{
+		rate:      DefaultRate,
+		burst:     DefaultBurst,
+		maxWait:   DefaultMaxWait,
+		algorithm: AlgorithmTokenBucket,
+		clock:     utils.SystemClock{},
+	}
+}
+
+// DefaultConfig 返回限流器的默认配置。
+// DefaultConfig returns the default configuration for the rate limiter.
+func DefaultConfig() *Config {
+	return NewConfig()
+}
+
+// WithRate 设置每秒补充的令牌数。
+// WithRate sets the number of tokens refilled per second.
+func (c *Config) WithRate(rate float64) *Config {
+	c.rate = rate
+	return c
+}
+
+// WithBurst 设置突发大小。
+// WithBurst sets the burst size.
+func (c *Config) WithBurst(burst int) *Config {
+	c.burst = burst
+	return c
+}
+
+// WithMaxWait 设置 Allow 和 Do 等待令牌的最长时间，需要等待更久时立即拒绝。
+// WithMaxWait sets the maximum time Allow and Do wait for a token, executions which need to wait longer are rejected immediately.
+func (c *Config) WithMaxWait(wait time.Duration) *Config {
+	c.maxWait = wait
+	return c
+}
+
+// WithAlgorithm 设置限流的算法。
+// WithAlgorithm sets the algorithm of rate limiting.
+func (c *Config) WithAlgorithm(algorithm Algorithm) *Config {
+	c.algorithm = algorithm
+	return c
+}
+
+// WithClock 设置限流器计算令牌使用的时钟。时钟只影响令牌的计算，等待令牌和上下文的截止时间总是使用真实时间。
+// WithClock sets the clock used by the rate limiter to account the tokens. The clock only affects the token accounting, waiting for a token and the deadline of the context always use the real time.
+func (c *Config) WithClock(clock com.Clock) *Config {
+	c.clock = clock
+	return c
+}
+
+// isConfigValid 检查配置是否有效。
+// isConfigValid checks if the configuration is valid.
+func isConfigValid(conf *Config) *Config {
+	if conf != nil {
+		if conf.rate <= 0 {
+			conf.rate = DefaultRate
+		}
+		if conf.burst <= 0 {
+			conf.burst = DefaultBurst
+		}
+		if conf.maxWait < 0 {
+			conf.maxWait = DefaultMaxWait
+		}
+		if conf.algorithm != AlgorithmTokenBucket && conf.algorithm != AlgorithmGCRA {
+			conf.algorithm = AlgorithmTokenBucket
+		}
+		if conf.clock == nil {
+			conf.clock = utils.SystemClock{}
+		}
+	} else {
+		conf = DefaultConfig()
+	}
+
+	return conf
+}
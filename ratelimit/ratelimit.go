@@ -0,0 +1,217 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	cb "github.com/shengyanli1982/tripwire/circuitbreaker"
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// emptyNotifier 是限流器的结果通知器，限流器不关心执行结果。
+// emptyNotifier is the result notifier of the rate limiter, the rate limiter does not care about the result.
+type emptyNotifier struct{}
+
+// MarkSuccess 是空操作。
+// MarkSuccess is a nop.
+func (emptyNotifier) MarkSuccess() {}
+
+// MarkFailure 是空操作。
+// MarkFailure is a nop.
+func (emptyNotifier) MarkFailure(reason error) {}
+
+// RateLimiter 是客户端的限流器，在令牌用完时拒绝执行并返回 ErrorRateLimited，用于遵守上游公布的 QPS 配额。
+// RateLimiter is a client side rate limiter, it rejects executions with ErrorRateLimited when the tokens run out, used to respect the QPS quotas published by upstreams.
+type RateLimiter struct {
+	config  *Config       // 限流器的配置 Config of the rate limiter
+	lock    sync.Mutex    // 保护限流算法的锁 Lock protecting the algorithm
+	limiter limiter       // 限流算法 Rate limiting algorithm
+	stopCh  chan struct{} // 停止信号 Stop signal
+	once    sync.Once     // 用于确保只停止一次 The sync.Once to ensure stopping only once
+}
+
+// NewRateLimiter 返回一个新的限流器，令牌桶在开始时是满的。
+// NewRateLimiter returns a new rate limiter, the token bucket is full at the start.
+func NewRateLimiter(conf *Config) *RateLimiter {
+	conf = isConfigValid(conf)
+	return &RateLimiter{
+		config:  conf,
+		limiter: newLimiter(conf, conf.clock.Now()),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Stop 停止限流器，正在等待令牌的执行被拒绝。
+// Stop stops the rate limiter, the executions waiting for a token are rejected.
+func (r *RateLimiter) Stop() {
+	r.once.Do(func() {
+		close(r.stopCh)
+	})
+}
+
+// wait 预留一个令牌并等待它可用，需要等待超过 maxWait 时返回 ErrorRateLimited。
+// 等待时长由配置的时钟计算，但等待本身使用真实的定时器，与上下文的截止时间一致。
+// wait reserves a token and waits until it is available, returns ErrorRateLimited if the wait exceeds maxWait.
+// The wait duration is computed with the configured clock, but the wait itself uses a real timer, consistent with the deadline of the context.
+func (r *RateLimiter) wait(ctx context.Context, maxWait time.Duration) error {
+	select {
+	case <-r.stopCh:
+		return com.ErrorRateLimiterStopped
+	default:
+	}
+
+	// 上下文的截止时间之前拿不到令牌，不必等待。截止时间是真实时间，不能和配置的时钟比较。
+	// No token before the deadline of the context, no need to wait. The deadline is in real time, it can not be compared with the configured clock.
+	if deadline, ok := ctx.Deadline(); ok {
+		if until := time.Until(deadline); until < maxWait {
+			maxWait = until
+		}
+		if maxWait < 0 {
+			maxWait = 0
+		}
+	}
+
+	r.lock.Lock()
+	delay, ok := r.limiter.reserve(r.config.clock.Now(), maxWait)
+	r.lock.Unlock()
+	if !ok {
+		return com.ErrorRateLimited
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		return ctx.Err()
+	case <-r.stopCh:
+		r.cancel()
+		return com.ErrorRateLimiterStopped
+	}
+}
+
+// cancel 归还一个预留的令牌。
+// cancel gives back a reserved token.
+func (r *RateLimiter) cancel() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.limiter.cancel()
+}
+
+// Wait 阻塞直到拿到一个令牌、上下文结束或限流器停止。
+// 如果上下文的截止时间之前拿不到令牌，立即返回 ErrorRateLimited。
+// Wait blocks until a token is acquired, the context is done or the rate limiter is stopped.
+// If no token is available before the deadline of the context, ErrorRateLimited is returned immediately.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.wait(ctx, math.MaxInt64)
+}
+
+// Allow 获取一个令牌，最多等待配置的最长等待时间，令牌用完时返回 ErrorRateLimited。
+// Allow acquires a token, waits at most the configured maximum wait time, and returns ErrorRateLimited when the tokens run out.
+func (r *RateLimiter) Allow() (com.Notifier, error) {
+	if err := r.wait(context.Background(), r.config.maxWait); err != nil {
+		return nil, err
+	}
+	return emptyNotifier{}, nil
+}
+
+// do 获取令牌后执行函数。
+// do executes the function after acquiring a token.
+func (r *RateLimiter) do(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 限流器拒绝执行，执行回退函数或返回错误。
+	// The rate limiter rejects the execution, execute the fallback function or return the error.
+	if err := r.wait(context.Background(), r.config.maxWait); err != nil {
+		if fallback != nil {
+			return fallback(err)
+		}
+		return err
+	}
+
+	if err := fn(); !acceptable(err) {
+		return err
+	}
+	return nil
+}
+
+// Do 执行函数并返回错误。
+// Do executes the function and returns the error.
+func (r *RateLimiter) Do(fn com.HandleFunc) error {
+	return r.do(fn, nil, cb.DefaultAcceptableFunc)
+}
+
+// DoWithAcceptable 使用给定的可接受函数执行函数并返回错误。
+// DoWithAcceptable executes the function with the given acceptable function and returns the error.
+func (r *RateLimiter) DoWithAcceptable(fn com.HandleFunc, acceptable com.AcceptableFunc) error {
+	return r.do(fn, nil, acceptable)
+}
+
+// DoWithFallback 使用给定的回退函数执行函数并返回错误。
+// DoWithFallback executes the function with the given fallback function and returns the error.
+func (r *RateLimiter) DoWithFallback(fn com.HandleFunc, fallback com.FallbackFunc) error {
+	return r.do(fn, fallback, cb.DefaultAcceptableFunc)
+}
+
+// DoWithFallbackAcceptable 使用给定的回退和可接受函数执行函数并返回错误。
+// DoWithFallbackAcceptable executes the function with the given fallback and acceptable functions and returns the error.
+func (r *RateLimiter) DoWithFallbackAcceptable(fn com.HandleFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return r.do(fn, fallback, acceptable)
+}
+
+// doCtx 获取令牌后使用上下文执行函数，等待令牌时上下文结束会直接返回上下文的错误。
+// doCtx executes the function with the context after acquiring a token, the error of the context is returned directly if it is done while waiting for a token.
+func (r *RateLimiter) doCtx(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	// 如果上下文已经结束，快速失败。
+	// If the context is already done, fail fast.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 限流器拒绝执行，执行回退函数或返回错误。
+	// The rate limiter rejects the execution, execute the fallback function or return the error.
+	if err := r.wait(ctx, r.config.maxWait); err != nil {
+		if fallback != nil && (err == com.ErrorRateLimited || err == com.ErrorRateLimiterStopped) {
+			return fallback(err)
+		}
+		return err
+	}
+
+	if err := fn(ctx); !acceptable(err) {
+		return err
+	}
+	return nil
+}
+
+// DoCtx 使用上下文执行函数并返回错误。
+// DoCtx executes the function with the context and returns the error.
+func (r *RateLimiter) DoCtx(ctx context.Context, fn com.HandleCtxFunc) error {
+	return r.doCtx(ctx, fn, nil, cb.DefaultAcceptableFunc)
+}
+
+// DoCtxWithAcceptable 使用上下文和给定的可接受函数执行函数并返回错误。
+// DoCtxWithAcceptable executes the function with the context and the given acceptable function and returns the error.
+func (r *RateLimiter) DoCtxWithAcceptable(ctx context.Context, fn com.HandleCtxFunc, acceptable com.AcceptableFunc) error {
+	return r.doCtx(ctx, fn, nil, acceptable)
+}
+
+// DoCtxWithFallback 使用上下文和给定的回退函数执行函数并返回错误。
+// DoCtxWithFallback executes the function with the context and the given fallback function and returns the error.
+func (r *RateLimiter) DoCtxWithFallback(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc) error {
+	return r.doCtx(ctx, fn, fallback, cb.DefaultAcceptableFunc)
+}
+
+// DoCtxWithFallbackAcceptable 使用上下文和给定的回退和可接受函数执行函数并返回错误。
+// DoCtxWithFallbackAcceptable executes the function with the context and the given fallback and acceptable functions and returns the error.
+func (r *RateLimiter) DoCtxWithFallbackAcceptable(ctx context.Context, fn com.HandleCtxFunc, fallback com.FallbackFunc, acceptable com.AcceptableFunc) error {
+	return r.doCtx(ctx, fn, fallback, acceptable)
+}
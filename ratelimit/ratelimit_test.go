@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+	"github.com/shengyanli1982/tripwire/tripwiretest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_Burst(t *testing.T) {
+	for _, algorithm := range []Algorithm{AlgorithmTokenBucket, AlgorithmGCRA} {
+		clock := tripwiretest.NewFakeClock(time.Time{})
+		limiter := NewRateLimiter(NewConfig().WithRate(10).WithBurst(3).WithAlgorithm(algorithm).WithClock(clock))
+
+		// Test case 1: The burst is allowed at once
+		for i := 0; i < 3; i++ {
+			_, err := limiter.Allow()
+			assert.NoError(t, err, "Unexpected error")
+		}
+
+		// Test case 2: The tokens run out
+		_, err := limiter.Allow()
+		assert.ErrorIs(t, err, com.ErrorRateLimited, "Unexpected error")
+
+		// Test case 3: One token is refilled after 100ms
+		clock.Advance(100 * time.Millisecond)
+		_, err = limiter.Allow()
+		assert.NoError(t, err, "Unexpected error")
+		_, err = limiter.Allow()
+		assert.ErrorIs(t, err, com.ErrorRateLimited, "Unexpected error")
+
+		// Test case 4: The bucket never holds more than the burst
+		clock.Advance(time.Minute)
+		for i := 0; i < 3; i++ {
+			_, err = limiter.Allow()
+			assert.NoError(t, err, "Unexpected error")
+		}
+		_, err = limiter.Allow()
+		assert.ErrorIs(t, err, com.ErrorRateLimited, "Unexpected error")
+
+		limiter.Stop()
+	}
+}
+
+func TestRateLimiter_Do(t *testing.T) {
+	var execError = errors.New("execution error")
+
+	clock := tripwiretest.NewFakeClock(time.Time{})
+	limiter := NewRateLimiter(NewConfig().WithRate(1).WithBurst(1).WithClock(clock))
+	defer limiter.Stop()
+
+	// Test case 1: Failed execution returns the error
+	err := limiter.Do(func() error { return execError })
+	assert.ErrorIs(t, err, execError, "Unexpected error")
+
+	// Test case 2: Fallback receives the rate limited error
+	err = limiter.DoCtxWithFallback(context.Background(), func(ctx context.Context) error { return nil }, func(err error) error {
+		assert.ErrorIs(t, err, com.ErrorRateLimited, "Unexpected error")
+		return nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	limiter := NewRateLimiter(NewConfig().WithRate(50).WithBurst(1))
+	defer limiter.Stop()
+
+	// Test case 1: Wait blocks until the next token
+	assert.NoError(t, limiter.Wait(context.Background()), "Unexpected error")
+	start := time.Now()
+	assert.NoError(t, limiter.Wait(context.Background()), "Unexpected error")
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond, "Wait did not block")
+
+	// Test case 2: No token before the deadline, rejected immediately
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, limiter.Wait(ctx), com.ErrorRateLimited, "Unexpected error")
+
+	// Test case 3: The rejected wait does not consume a token
+	time.Sleep(25 * time.Millisecond)
+	_, err := limiter.Allow()
+	assert.NoError(t, err, "Unexpected error")
+}
+
+func TestRateLimiter_MaxWait(t *testing.T) {
+	limiter := NewRateLimiter(NewConfig().WithRate(50).WithBurst(1).WithMaxWait(time.Second))
+
+	// Allow waits for the next token
+	_, err := limiter.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	_, err = limiter.Allow()
+	assert.NoError(t, err, "Unexpected error")
+
+	// Stop rejects the waiting execution
+	done := make(chan error, 1)
+	go func() {
+		done <- limiter.Do(func() error { return nil })
+	}()
+	time.Sleep(5 * time.Millisecond)
+	limiter.Stop()
+	assert.ErrorIs(t, <-done, com.ErrorRateLimiterStopped, "Unexpected error")
+}
+
+func TestRateLimiter_MaxWaitClock(t *testing.T) {
+	clock := tripwiretest.NewFakeClock(time.Time{})
+	limiter := NewRateLimiter(NewConfig().WithRate(50).WithBurst(1).WithMaxWait(time.Second).WithClock(clock))
+	defer limiter.Stop()
+
+	// Test case 1: The delay is computed with the clock and waited in real time
+	_, err := limiter.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	start := time.Now()
+	_, err = limiter.Allow()
+	assert.NoError(t, err, "Unexpected error")
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond, "Allow did not block")
+
+	// Test case 2: The deadline of the context is compared in real time
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, limiter.Wait(ctx), com.ErrorRateLimited, "Unexpected error")
+
+	// Test case 3: The tokens are refilled by the clock
+	clock.Advance(time.Second)
+	_, err = limiter.Allow()
+	assert.NoError(t, err, "Unexpected error")
+}
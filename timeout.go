@@ -0,0 +1,122 @@
+package tripwire
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// callResult 是在独立协程中执行的函数的结果。
+// callResult is the result of the function executed in a separate goroutine.
+type callResult[T any] struct {
+	value T
+	err   error
+	panic *com.PanicError
+}
+
+// runWithTimeout 在独立协程中执行函数，超过超时时间后放弃等待并返回 TimeoutError。
+// 被放弃的函数会在后台继续运行，直到它观察到上下文被取消并返回，它的返回值会被丢弃。
+// 函数的结果只通过通道交给调用方，函数不应该写入调用方的变量。
+// runWithTimeout executes the function in a separate goroutine, and stops waiting and returns a TimeoutError after the timeout.
+// The abandoned function keeps running in the background until it observes the canceled context and returns, its returned value is dropped.
+// The result of the function is handed to the caller only through the channel, the function should not write the variables of the caller.
+func runWithTimeout[T any](parent context.Context, timeout time.Duration, fn func(context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	// 调用方和协程通过 claimed 决定结果的归属：协程先声明时把结果发送给调用方，调用方先声明时结果被放弃。
+	// 通道带缓冲，协程发送结果时不会阻塞。
+	// The caller and the goroutine decide the owner of the result through claimed: the result is sent to the caller if the goroutine claims first, and abandoned if the caller claims first.
+	// The channel is buffered, so the goroutine does not block when sending the result.
+	var claimed atomic.Bool
+	done := make(chan callResult[T], 1)
+	go func() {
+		var res callResult[T]
+		defer func() {
+			// 把 panic 连同堆栈转交给调用方的协程，被放弃后发生的 panic 会被丢弃。
+			// Hand the panic with its stack over to the goroutine of the caller, the panic after abandonment is dropped.
+			if v := recover(); v != nil {
+				res = callResult[T]{panic: com.NewPanicError(v)}
+			}
+			if claimed.CompareAndSwap(false, true) {
+				done <- res
+			}
+		}()
+		res.value, res.err = fn(ctx)
+	}()
+
+	var (
+		res  callResult[T]
+		zero T
+	)
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		if claimed.CompareAndSwap(false, true) {
+			if isDeadline(parent, ctx) {
+				return zero, &com.TimeoutError{Timeout: timeout}
+			}
+			// 调用方的上下文结束了，返回调用方上下文的错误。
+			// The caller's context ended, return the error of the caller's context.
+			return zero, parent.Err()
+		}
+		// 协程已经声明了结果，结果马上就会到达。
+		// The goroutine has claimed the result, it arrives at once.
+		res = <-done
+	}
+
+	if res.panic != nil {
+		panic(res.panic)
+	}
+	// 函数自己返回了超时上下文的错误，同样视为超时。
+	// The function returned the error of the timed out context by itself, which is also a timeout.
+	if res.err != nil && isDeadline(parent, ctx) {
+		return res.value, &com.TimeoutError{Timeout: timeout}
+	}
+	return res.value, res.err
+}
+
+// isDeadline 检查上下文是否因为本次调用的超时而结束，而不是调用方的上下文结束。
+// isDeadline checks if the context ended because of the timeout of this call, rather than the end of the caller's context.
+func isDeadline(parent, ctx context.Context) bool {
+	return parent.Err() == nil && ctx.Err() == context.DeadlineExceeded
+}
+
+// withTimeout 返回受超时保护的函数，超时时间不大于 0 时直接返回原函数。
+// withTimeout returns the function guarded by the timeout, returns the function itself if the timeout is not greater than 0.
+func withTimeout(fn com.HandleFunc, timeout time.Duration) com.HandleFunc {
+	if timeout <= 0 || fn == nil {
+		return fn
+	}
+	return func() error {
+		_, err := runWithTimeout(context.Background(), timeout, func(context.Context) (struct{}, error) { return struct{}{}, fn() })
+		return err
+	}
+}
+
+// withTimeoutCtx 返回受超时保护的上下文函数，函数收到的上下文会在超时后被取消。
+// withTimeoutCtx returns the context function guarded by the timeout, the context received by the function is canceled after the timeout.
+func withTimeoutCtx(fn com.HandleCtxFunc, timeout time.Duration) com.HandleCtxFunc {
+	if timeout <= 0 || fn == nil {
+		return fn
+	}
+	return func(ctx context.Context) error {
+		_, err := runWithTimeout(ctx, timeout, func(ctx context.Context) (struct{}, error) { return struct{}{}, fn(ctx) })
+		return err
+	}
+}
+
+// withTimeoutValue 返回受超时保护的返回值的函数，超时时间不大于 0 时直接返回原函数。
+// 返回值只通过结果通道交给调用方，超时后到达的返回值会被丢弃。
+// withTimeoutValue returns the value-returning function guarded by the timeout, returns the function itself if the timeout is not greater than 0.
+// The value is handed to the caller only through the result channel, a value arriving after the timeout is dropped.
+func withTimeoutValue[T any](fn func(context.Context) (T, error), timeout time.Duration) func(context.Context) (T, error) {
+	if timeout <= 0 {
+		return fn
+	}
+	return func(ctx context.Context) (T, error) {
+		return runWithTimeout(ctx, timeout, fn)
+	}
+}
@@ -0,0 +1,58 @@
+// Package tripwiretest 提供测试熔断器时使用的工具。
+// Package tripwiretest provides utilities for testing breakers.
+package tripwiretest
+
+import (
+	"sync"
+	"time"
+
+	com "github.com/shengyanli1982/tripwire/common"
+)
+
+// FakeClock 是一个只在手动推进时才会变化的时钟，用于确定性地测试窗口过期和熔断器恢复。
+// FakeClock is a clock that only changes when advanced manually, used to test window expiry and breaker recovery deterministically.
+type FakeClock struct {
+	lock sync.RWMutex
+	now  time.Time
+}
+
+var _ com.Clock = (*FakeClock)(nil)
+
+// NewFakeClock 返回一个从指定时间开始的新时钟，start 为零值时从当前时间开始。
+// NewFakeClock returns a new clock starting at the given time, it starts at the current time if start is zero.
+func NewFakeClock(start time.Time) *FakeClock {
+	if start.IsZero() {
+		start = time.Now()
+	}
+	return &FakeClock{now: start}
+}
+
+// Now 返回时钟的当前时间。
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.now
+}
+
+// Since 返回自 t 以来在时钟上经过的时间。
+// Since returns the time elapsed on the clock since t.
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Advance 把时钟向前推进 d。
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set 把时钟设置为指定时间。
+// Set sets the clock to the given time.
+func (c *FakeClock) Set(t time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = t
+}
@@ -0,0 +1,29 @@
+package tripwiretest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	// The clock does not move by itself
+	assert.Equal(t, start, clock.Now(), "Unexpected time")
+	assert.Equal(t, time.Duration(0), clock.Since(start), "Unexpected elapsed time")
+
+	// Advance the clock
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, start.Add(5*time.Second), clock.Now(), "Unexpected time")
+	assert.Equal(t, 5*time.Second, clock.Since(start), "Unexpected elapsed time")
+
+	// Set the clock
+	clock.Set(start.Add(time.Hour))
+	assert.Equal(t, time.Hour, clock.Since(start), "Unexpected elapsed time")
+
+	// A zero start time starts at the current time
+	assert.False(t, NewFakeClock(time.Time{}).Now().IsZero(), "Unexpected time")
+}